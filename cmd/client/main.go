@@ -37,12 +37,18 @@ func main() {
 
 	types.SetTheme("default")
 
-	api.NewAPIClient(ctx, cli)
-	prog := tea.NewProgram(ui.New(ctx, cli), tea.WithAltScreen(), tea.WithMouseCellMotion())
+	client := api.NewAPIClient(ctx, cli)
+	prog := tea.NewProgram(ui.New(ctx, cli, client), tea.WithAltScreen(), tea.WithMouseCellMotion())
 
-	go api.Manager.HandleMsg(prog.Send)
+	go client.HandleMsg(prog.Send)
 
-	if _, err := prog.Run(); err != nil {
+	_, err := prog.Run()
+
+	// Cancel any requests still in flight, and wait for the workers to stop,
+	// before exiting.
+	client.Close()
+
+	if err != nil {
 		logger.WithError(err).Fatal("failed to start hangar-ui")
 	}
 }
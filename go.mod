@@ -7,6 +7,7 @@ require (
 	github.com/charmbracelet/bubbles v0.14.0
 	github.com/charmbracelet/bubbletea v0.23.1
 	github.com/charmbracelet/lipgloss v0.6.0
+	github.com/dustin/go-humanize v1.0.0
 	github.com/knipferrc/teacup v0.2.0
 	github.com/lrstanley/bubblezone v0.0.0-20221029233222-b3469cc5a659
 	github.com/lrstanley/clix v0.0.0-20220704215932-712836d7df85
@@ -14,18 +15,19 @@ require (
 )
 
 require (
-	github.com/aryann/difflib v0.0.0-20210328193216-ff5ff6dc229b // indirect
+	github.com/aryann/difflib v0.0.0-20210328193216-ff5ff6dc229b
 	github.com/atotto/clipboard v0.1.4 // indirect
 	github.com/bmizerany/pat v0.0.0-20210406213842-e4b6760bdd6f // indirect
 	github.com/concourse/concourse v1.6.1-0.20220407194753-e6ad875114f6
 	github.com/containerd/console v1.0.3 // indirect
 	github.com/evertras/bubble-table v0.14.6
+	github.com/fsnotify/fsnotify v1.5.1
 	github.com/go-logfmt/logfmt v0.5.1 // indirect
 	github.com/google/jsonapi v1.0.0 // indirect
 	github.com/gookit/color v1.5.1 // indirect
 	github.com/hashicorp/errwrap v1.1.0 // indirect
 	github.com/hashicorp/go-multierror v1.1.1 // indirect
-	github.com/jessevdk/go-flags v1.5.0 // indirect
+	github.com/jessevdk/go-flags v1.5.0
 	github.com/joho/godotenv v1.4.0 // indirect
 	github.com/lucasb-eyer/go-colorful v1.2.0 // indirect
 	github.com/mattn/go-colorable v0.1.12 // indirect
@@ -39,8 +41,8 @@ require (
 	github.com/peterhellberg/link v1.1.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
 	github.com/rivo/uniseg v0.4.3 // indirect
-	github.com/tedsuo/rata v1.0.1-0.20170830210128-07d200713958 // indirect
-	github.com/vito/go-sse v1.0.0 // indirect
+	github.com/tedsuo/rata v1.0.1-0.20170830210128-07d200713958
+	github.com/vito/go-sse v1.0.0
 	github.com/xo/terminfo v0.0.0-20210125001918-ca9a967f8778 // indirect
 	golang.org/x/crypto v0.0.0-20220622213112-05595931fe9d // indirect
 	golang.org/x/net v0.0.0-20220722155237-a158d28d115b // indirect
@@ -48,10 +50,10 @@ require (
 	golang.org/x/term v0.2.0 // indirect
 	golang.org/x/text v0.4.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	sigs.k8s.io/yaml v1.3.0 // indirect
+	sigs.k8s.io/yaml v1.3.0
 )
 
-require golang.org/x/oauth2 v0.0.0-20220630143837-2104d58473e0 // indirect
+require golang.org/x/oauth2 v0.0.0-20220630143837-2104d58473e0
 
 require (
 	github.com/golang/protobuf v1.5.2 // indirect
@@ -62,7 +64,6 @@ require (
 require (
 	github.com/aymanbagabas/go-osc52 v1.2.1 // indirect
 	github.com/cppforlife/go-semi-semantic v0.0.0-20160921010311-576b6af77ae4 // indirect
-	github.com/dustin/go-humanize v1.0.0 // indirect
 	github.com/fatih/color v1.13.0 // indirect
 	github.com/kr/pretty v0.3.0 // indirect
 	github.com/mattn/go-localereader v0.0.1 // indirect
@@ -0,0 +1,267 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// archiveFiles returns the list of files (relative to dir) that should be
+// uploaded as an input. Similar to fly, if dir is a git repository, files
+// ignored through .gitignore are excluded, unless includeIgnored is true.
+func archiveFiles(dir string, includeIgnored bool) []string {
+	if includeIgnored {
+		return []string{"."}
+	}
+
+	tracked, err := gitLS(dir)
+	if err != nil {
+		return []string{"."}
+	}
+
+	deleted, err := gitLS(dir, "--deleted")
+	if err != nil {
+		return []string{"."}
+	}
+
+	untracked, err := gitLS(dir, "--others", "--exclude-standard")
+	if err != nil {
+		return []string{"."}
+	}
+
+	isDeleted := make(map[string]bool, len(deleted))
+	for _, f := range deleted {
+		isDeleted[f] = true
+	}
+
+	files := make([]string, 0, len(tracked)+len(untracked))
+	for _, f := range tracked {
+		if !isDeleted[f] {
+			files = append(files, f)
+		}
+	}
+
+	return append(files, untracked...)
+}
+
+func gitLS(dir string, flags ...string) ([]string, error) {
+	cmd := exec.Command("git", append([]string{"ls-files", "-z"}, flags...)...)
+	cmd.Dir = dir
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, f := range bytes.Split(out, []byte{0}) {
+		if len(f) > 0 {
+			files = append(files, string(f))
+		}
+	}
+
+	return files, nil
+}
+
+// compressDir writes a gzipped tarball of the provided files (relative to dir)
+// to w. Directories are walked recursively.
+func compressDir(w io.Writer, dir string, files []string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for _, file := range files {
+		err := filepath.WalkDir(filepath.Join(dir, file), func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(dir, path)
+			if err != nil || rel == "." {
+				return err
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			var link string
+			if info.Mode()&fs.ModeSymlink != 0 {
+				if link, err = os.Readlink(path); err != nil {
+					return err
+				}
+			}
+
+			hdr, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+
+			hdr.Name = filepath.ToSlash(rel)
+			if d.IsDir() {
+				hdr.Name += "/"
+			}
+
+			if err = tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			_, err = io.Copy(tw, f)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+// extractArchive extracts a gzipped tarball from r into dir.
+func extractArchive(r io.Reader, dir string) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gr.Close()
+
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(gr)
+
+	var hdr *tar.Header
+	for {
+		hdr, err = tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if !withinDir(dir, path) {
+			return fmt.Errorf("archive entry %q escapes destination", hdr.Name)
+		}
+
+		// Never write through symlinks (from earlier entries, or which already
+		// existed) leading outside of the destination.
+		var parent string
+
+		parent, err = realParent(path)
+		if err != nil {
+			return err
+		}
+
+		if !withinDir(realDir, parent) {
+			return fmt.Errorf("archive entry %q escapes destination through a symlink", hdr.Name)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(path, hdr.FileInfo().Mode().Perm()|0o700)
+		case tar.TypeSymlink:
+			target := hdr.Linkname
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(path), target)
+			}
+
+			if !withinDir(dir, filepath.Clean(target)) {
+				return fmt.Errorf("archive entry %q links outside of destination", hdr.Name)
+			}
+
+			_ = os.Remove(path)
+			err = os.Symlink(hdr.Linkname, path)
+		case tar.TypeReg:
+			err = extractFile(tr, path, hdr.FileInfo().Mode().Perm())
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// withinDir returns true if path is dir, or is inside of it.
+func withinDir(dir, path string) bool {
+	dir = filepath.Clean(dir)
+	return path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator))
+}
+
+// realParent returns the nearest existing parent directory of path, with all
+// symlinks resolved.
+func realParent(path string) (string, error) {
+	dir := filepath.Dir(path)
+
+	for {
+		real, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return real, nil
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) || dir == filepath.Dir(dir) {
+			return "", err
+		}
+
+		dir = filepath.Dir(dir)
+	}
+}
+
+func extractFile(r io.Reader, path string, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Replace existing symlinks, rather than writing to their target.
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+		if err = os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
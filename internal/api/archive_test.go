@@ -0,0 +1,159 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testArchive returns a gzipped tarball of the provided headers. Regular files
+// contain their name.
+func testArchive(t *testing.T, headers ...tar.Header) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	for _, hdr := range headers {
+		hdr := hdr
+
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(hdr.Name))
+		}
+
+		if hdr.Mode == 0 {
+			hdr.Mode = 0o644
+		}
+
+		if err := tw.WriteHeader(&hdr); err != nil {
+			t.Fatal(err)
+		}
+
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(hdr.Name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &buf
+}
+
+func TestExtractArchive(t *testing.T) {
+	dir := t.TempDir()
+
+	err := extractArchive(testArchive(t,
+		tar.Header{Name: "sub/", Typeflag: tar.TypeDir, Mode: 0o755},
+		tar.Header{Name: "sub/file", Typeflag: tar.TypeReg},
+		tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "sub"},
+		tar.Header{Name: "link/other", Typeflag: tar.TypeReg},
+	), dir)
+	if err != nil {
+		t.Fatalf("failed to extract: %v", err)
+	}
+
+	for _, name := range []string{"sub/file", "sub/other"} {
+		if _, err = os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s to be extracted: %v", name, err)
+		}
+	}
+}
+
+func TestExtractArchiveEscapes(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []tar.Header
+	}{
+		{
+			name:    "relative path",
+			headers: []tar.Header{{Name: "../escaped", Typeflag: tar.TypeReg}},
+		},
+		{
+			name: "absolute symlink",
+			headers: []tar.Header{
+				{Name: "out", Typeflag: tar.TypeSymlink, Linkname: "{outside}"},
+				{Name: "out/escaped", Typeflag: tar.TypeReg},
+			},
+		},
+		{
+			name: "relative symlink",
+			headers: []tar.Header{
+				{Name: "sub/out", Typeflag: tar.TypeSymlink, Linkname: "../../outside"},
+				{Name: "sub/out/escaped", Typeflag: tar.TypeReg},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "dest")
+			outside := filepath.Join(root, "outside")
+
+			if err := os.Mkdir(outside, 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			for i := range tt.headers {
+				if tt.headers[i].Linkname == "{outside}" {
+					tt.headers[i].Linkname = outside
+				}
+			}
+
+			if err := extractArchive(testArchive(t, tt.headers...), dir); err == nil {
+				t.Error("expected an error")
+			}
+
+			for _, path := range []string{filepath.Join(root, "escaped"), filepath.Join(outside, "escaped")} {
+				if _, err := os.Stat(path); err == nil {
+					t.Errorf("%s was written outside of the destination", path)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractArchiveExistingSymlink(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "dest")
+	outside := filepath.Join(root, "outside")
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Mkdir(outside, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Left behind by a previous download, or created by the user.
+	if err := os.Symlink(outside, filepath.Join(dir, "out")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := extractArchive(testArchive(t, tar.Header{Name: "out/escaped", Typeflag: tar.TypeReg}), dir)
+	if err == nil {
+		t.Error("expected an error")
+	}
+
+	if _, err = os.Stat(filepath.Join(outside, "escaped")); err == nil {
+		t.Error("file was written through an existing symlink")
+	}
+}
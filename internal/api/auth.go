@@ -0,0 +1,151 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/apex/log"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/concourse/concourse/fly/rc"
+	"github.com/concourse/concourse/go-concourse/concourse"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+// LoginMsg is returned once a login to a target has completed. Retry re-runs
+// the queries that failed while the target needed login.
+type LoginMsg struct {
+	Target string
+	Retry  tea.Cmd
+	Error  error
+}
+
+// NeedsLoginError is returned by queries that failed because the token of the
+// target was rejected (e.g. it expired).
+type NeedsLoginError struct {
+	Target string
+}
+
+func (e NeedsLoginError) Error() string {
+	return fmt.Sprintf("target %q needs login", e.Target)
+}
+
+// NeedsLogin returns true if the token of the provided target was rejected by
+// the ATC, and hasn't been refreshed since.
+func (c *apiManager) NeedsLogin(target string) bool {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	return c.needsLogin[target]
+}
+
+func (c *apiManager) setNeedsLogin(target string, needsLogin bool) {
+	c.authMu.Lock()
+
+	if c.needsLogin[target] == needsLogin {
+		c.authMu.Unlock()
+		return
+	}
+
+	c.needsLogin[target] = needsLogin
+	if !needsLogin {
+		delete(c.needsLogin, target)
+	}
+	c.authMu.Unlock()
+
+	c.logger.WithFields(log.Fields{
+		"target":      target,
+		"needs_login": needsLogin,
+	}).Info("target auth state changed")
+
+	c.bus.Publish(types.TopicState, types.FlyAuthUpdated)
+}
+
+// checkAuth checks if err was caused by the token of target being rejected. If
+// so, retry is stored to be re-run once logged in, and a NeedsLoginError is
+// returned. key is used to de-duplicate retries of the same query.
+func (c *apiManager) checkAuth(target, key string, err error, retry tea.Cmd) error {
+	if !errors.Is(err, concourse.ErrUnauthorized) {
+		return err
+	}
+
+	c.setNeedsLogin(target, true)
+
+	c.authMu.Lock()
+	if c.retries[target] == nil {
+		c.retries[target] = map[string]tea.Cmd{}
+	}
+	c.retries[target][key] = retry
+	c.authMu.Unlock()
+
+	return NeedsLoginError{Target: target}
+}
+
+// saveLogin saves token to the flyrc for the provided target (in the same
+// format as fly, so both keep working), and completes the login.
+func (c *apiManager) saveLogin(target string, token *rc.TargetToken) tea.Msg {
+	err := c.editFlyrc(func(targets map[string]map[string]any) error {
+		props, ok := targets[target]
+		if !ok {
+			return rc.UnknownTargetError{TargetName: rc.TargetName(target)}
+		}
+
+		props["token"] = map[string]any{
+			"type":  token.Type,
+			"value": token.Value,
+		}
+
+		return nil
+	})
+	if err != nil {
+		return LoginMsg{Target: target, Error: fmt.Errorf("failed to save token: %w", err)}
+	}
+
+	return c.completeLogin(target)
+}
+
+// completeLogin reloads the token of the provided target from the flyrc
+// (after it was updated by a login), verifies it, and returns a LoginMsg with
+// the queries that need to be retried.
+func (c *apiManager) completeLogin(target string) tea.Msg {
+	c.unloadTargets(target)
+
+	t, err := c.loadTarget(rc.TargetName(target))
+	if err == nil {
+		_, err = t.Client().UserInfo()
+	}
+
+	c.logger.WithFields(log.Fields{
+		"target": target,
+		"error":  err,
+	}).Debug("completed login")
+
+	if errors.Is(err, concourse.ErrUnauthorized) {
+		return LoginMsg{Target: target, Error: NeedsLoginError{Target: target}}
+	}
+
+	if err != nil {
+		return LoginMsg{Target: target, Error: err}
+	}
+
+	if target == c.ActiveName() {
+		c.currentTarget.Store(t)
+	}
+
+	// Responses may differ for the new user.
+	c.cache.invalidate(target)
+	c.setNeedsLogin(target, false)
+
+	c.authMu.Lock()
+	retries := make([]tea.Cmd, 0, len(c.retries[target]))
+	for _, cmd := range c.retries[target] {
+		retries = append(retries, cmd)
+	}
+	delete(c.retries, target)
+	c.authMu.Unlock()
+
+	return LoginMsg{Target: target, Retry: tea.Batch(retries...)}
+}
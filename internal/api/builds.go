@@ -0,0 +1,164 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/apex/log"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/concourse/concourse/atc"
+	"github.com/concourse/concourse/atc/event"
+	"github.com/concourse/concourse/fly/rc"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+// BuildStartedMsg is sent when a build has been created, and its events are
+// about to be streamed. It's both returned, and published along with the
+// events of the build (before any of them), see buildStarted.
+type BuildStartedMsg struct {
+	Build atc.Build
+	Error error
+}
+
+// buildStarted publishes msg through the event bus, so subscribers of the
+// build events receive it before any of them, and returns it.
+func (c *apiManager) buildStarted(msg BuildStartedMsg) tea.Msg {
+	c.bus.Publish(types.TopicBuilds, msg)
+	return msg
+}
+
+// BuildEventMsg contains output from a build that is being streamed.
+type BuildEventMsg struct {
+	BuildID int
+	Text    string
+}
+
+// BuildFinishedMsg is sent when a streamed build has completed, and all
+// post-build work (e.g. downloading outputs) is done.
+type BuildFinishedMsg struct {
+	BuildID int
+	Status  atc.BuildStatus
+	Error   error
+}
+
+type BuildAbortMsg struct {
+	BuildID int
+	Error   error
+}
+
+// AbortBuild aborts the build with the provided ID on the active target.
+func (c *apiManager) AbortBuild(id int) tea.Cmd {
+	return func() tea.Msg {
+		defer c.Loading("aborting build")()
+
+		err := c.Client().AbortBuild(strconv.Itoa(id))
+
+		c.logger.WithFields(log.Fields{
+			"build": id,
+			"error": err,
+		}).Debug("aborted build")
+
+		return BuildAbortMsg{BuildID: id, Error: err}
+	}
+}
+
+// streamBuild streams the events of the provided build through the event bus,
+// calling onFinish (if provided) once the build has completed. The build is
+// followed until it completes (or the client is closed), even if the build
+// view is left, but its events are only forwarded while the build view is
+// shown. It should be invoked as a goroutine, with the waitgroup already
+// incremented.
+func (c *apiManager) streamBuild(target rc.Target, build atc.Build, onFinish func(status atc.BuildStatus) error) {
+	defer c.wg.Done()
+
+	status, err := c.readBuildEvents(c.ctx, c.viewContextOf(types.ViewBuild), target, build)
+
+	if err == nil && onFinish != nil {
+		err = onFinish(status)
+	}
+
+	c.logger.WithFields(log.Fields{
+		"build":  build.ID,
+		"status": status,
+		"error":  err,
+	}).Debug("build finished")
+
+	c.bus.Publish(types.TopicBuilds, BuildFinishedMsg{BuildID: build.ID, Status: status, Error: err})
+}
+
+// readBuildEvents reads the events of the provided build until it completes,
+// or ctx is done, and returns its final status. Events are published until
+// view (the context of the build view) is done.
+func (c *apiManager) readBuildEvents(ctx, view context.Context, target rc.Target, build atc.Build) (status atc.BuildStatus, err error) {
+	events, err := streamClient(ctx, target.Client()).BuildEvents(strconv.Itoa(build.ID))
+	if err != nil {
+		if ctx.Err() != nil {
+			return status, nil
+		}
+
+		return status, err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+
+		events.Close()
+	}()
+
+	publish := func(text string) {
+		if view.Err() == nil {
+			c.bus.Publish(types.TopicBuilds, BuildEventMsg{BuildID: build.ID, Text: text})
+		}
+	}
+
+	var ev atc.Event
+	for {
+		ev, err = events.NextEvent()
+		if err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return status, nil
+			}
+
+			return status, err
+		}
+
+		var text string
+
+		switch e := ev.(type) {
+		case event.Log:
+			text = e.Payload
+		case event.Error:
+			text = e.Message + "\n"
+		case event.InitializeTask:
+			text = "initializing\n"
+		case event.SelectedWorker:
+			text = fmt.Sprintf("selected worker: %s\n", e.WorkerName)
+		case event.FinishTask:
+			text = fmt.Sprintf("exit status: %d\n", e.ExitStatus)
+		case event.Status:
+			status = e.Status
+
+			if status != atc.StatusStarted && status != atc.StatusPending {
+				publish(string(status) + "\n")
+				return status, nil
+			}
+		}
+
+		if text != "" {
+			publish(text)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/concourse/concourse/fly/rc"
+)
+
+const (
+	// maxCacheEntries is the max number of responses kept by a responseCache.
+	maxCacheEntries = 1000
+
+	// maxCacheSize is the max total size of the response bodies kept by a
+	// responseCache. Larger responses aren't cached at all.
+	maxCacheSize = 64 << 20
+)
+
+// responseCache stores the last response of GET requests, per target and key
+// (the URL, along with the identity of the user, see cacheTransport). Identical
+// concurrent requests are coalesced into one, and cached responses
+// are revalidated with conditional requests (ETag/Last-Modified), where the
+// ATC supports them, so unchanged responses only cost a 304. Once full (see
+// maxCacheEntries and maxCacheSize), the least recently used responses are
+// evicted.
+type responseCache struct {
+	mu       sync.Mutex
+	entries  map[string]map[string]*list.Element // target -> key -> entry.
+	lru      *list.List                          // Most recently used first.
+	size     int
+	inflight map[string]*cacheCall
+}
+
+type cacheEntry struct {
+	target string
+	key    string
+
+	status int
+	header http.Header
+	body   []byte
+}
+
+// cacheCall is an in-flight request, which identical requests wait for.
+type cacheCall struct {
+	done  chan struct{}
+	entry *cacheEntry // nil if the response couldn't be cached.
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{
+		entries:  map[string]map[string]*list.Element{},
+		lru:      list.New(),
+		inflight: map[string]*cacheCall{},
+	}
+}
+
+// invalidate removes all cached responses of the provided target.
+func (c *responseCache) invalidate(target string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, elem := range c.entries[target] {
+		c.lru.Remove(elem)
+		c.size -= len(elem.Value.(*cacheEntry).body)
+	}
+
+	delete(c.entries, target)
+}
+
+// store stores entry, replacing the previous response of its target and key,
+// and evicts the least recently used entries if the cache is full. c.mu must
+// be held.
+func (c *responseCache) store(entry *cacheEntry) {
+	if len(entry.body) > maxCacheSize {
+		return
+	}
+
+	if elem, ok := c.entries[entry.target][entry.key]; ok {
+		c.remove(elem)
+	}
+
+	if c.entries[entry.target] == nil {
+		c.entries[entry.target] = map[string]*list.Element{}
+	}
+
+	c.entries[entry.target][entry.key] = c.lru.PushFront(entry)
+	c.size += len(entry.body)
+
+	for c.lru.Len() > maxCacheEntries || c.size > maxCacheSize {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove removes a single cached entry. c.mu must be held.
+func (c *responseCache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	c.size -= len(entry.body)
+
+	delete(c.entries[entry.target], entry.key)
+	if len(c.entries[entry.target]) == 0 {
+		delete(c.entries, entry.target)
+	}
+}
+
+// begin returns the cached entry for the provided target and key (if any),
+// and the in-flight call for it. If leader is true, the caller must make the
+// request, and call finish once done.
+func (c *responseCache) begin(target, key string) (entry *cacheEntry, call *cacheCall, leader bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if call, ok := c.inflight[target+" "+key]; ok {
+		return nil, call, false
+	}
+
+	call = &cacheCall{done: make(chan struct{})}
+	c.inflight[target+" "+key] = call
+
+	if elem, ok := c.entries[target][key]; ok {
+		c.lru.MoveToFront(elem)
+		entry = elem.Value.(*cacheEntry)
+	}
+
+	return entry, call, true
+}
+
+// finish stores the result of the in-flight call (if cacheable), and releases
+// all requests waiting for it.
+func (c *responseCache) finish(target, key string, call *cacheCall) {
+	c.mu.Lock()
+	delete(c.inflight, target+" "+key)
+
+	if call.entry != nil {
+		c.store(call.entry)
+	}
+	c.mu.Unlock()
+
+	close(call.done)
+}
+
+// response returns a new response for r, from the cached entry.
+func (e *cacheEntry) response(r *http.Request) *http.Response {
+	return &http.Response{
+		Status:        strconv.Itoa(e.status) + " " + http.StatusText(e.status),
+		StatusCode:    e.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       r,
+	}
+}
+
+// cacheTransport caches the responses of GET requests to a target in a
+// responseCache. Other requests invalidate the cached responses of the target,
+// as they may have changed its state. Responses are only shared between
+// requests made with the same token (see identity), as they depend on the
+// user.
+type cacheTransport struct {
+	target   string
+	identity string
+	base     http.RoundTripper
+	cache    *responseCache
+}
+
+// tokenIdentity returns the identity of the user of a token, as used by
+// cacheTransport. The token itself isn't kept.
+func tokenIdentity(token *rc.TargetToken) string {
+	if token == nil {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(token.Type + " " + token.Value))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (t *cacheTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Method != http.MethodGet {
+		if r.Method != http.MethodHead {
+			t.cache.invalidate(t.target)
+		}
+
+		return t.base.RoundTrip(r)
+	}
+
+	key := t.identity + " " + r.URL.String()
+
+	entry, call, leader := t.cache.begin(t.target, key)
+	if !leader {
+		select {
+		case <-call.done:
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+
+		if call.entry != nil {
+			return call.entry.response(r), nil
+		}
+
+		// The response of the other request couldn't be shared (e.g. it
+		// failed, or was a stream), so make our own.
+		return t.base.RoundTrip(r)
+	}
+	defer t.cache.finish(t.target, key, call)
+
+	req := r
+	if entry != nil {
+		etag, modified := entry.header.Get("ETag"), entry.header.Get("Last-Modified")
+
+		if etag != "" || modified != "" {
+			req = r.Clone(r.Context())
+
+			if etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+
+			if modified != "" {
+				req.Header.Set("If-Modified-Since", modified)
+			}
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+
+		call.entry = entry
+		return entry.response(r), nil
+	}
+
+	if resp.StatusCode != http.StatusOK || !isJSON(resp.Header.Get("Content-Type")) {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	call.entry = &cacheEntry{
+		target: t.target,
+		key:    key,
+		status: resp.StatusCode,
+		header: resp.Header.Clone(),
+		body:   body,
+	}
+
+	return call.entry.response(r), nil
+}
+
+// isJSON returns true if the provided content type is JSON. Only JSON responses
+// are cached, so streams (e.g. build events) and downloads are passed through.
+func isJSON(contentType string) bool {
+	typ, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (typ == "application/json" || strings.HasSuffix(typ, "+json"))
+}
@@ -0,0 +1,183 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/concourse/concourse/fly/rc"
+)
+
+// cached returns the cached entry of target and url, marking it as used.
+func cached(c *responseCache, target, url string) *cacheEntry {
+	entry, call, _ := c.begin(target, url)
+	c.finish(target, url, call)
+
+	return entry
+}
+
+func fill(c *responseCache, target string, n, size int) {
+	for i := 0; i < n; i++ {
+		url := "/" + strconv.Itoa(i)
+
+		_, call, _ := c.begin(target, url)
+		call.entry = &cacheEntry{target: target, key: url, status: 200, body: make([]byte, size)}
+		c.finish(target, url, call)
+	}
+}
+
+func TestResponseCacheEvictsLRU(t *testing.T) {
+	c := newResponseCache()
+
+	fill(c, "a", maxCacheEntries, 1)
+
+	// Use the oldest entry, so the next oldest is evicted instead.
+	if cached(c, "a", "/0") == nil {
+		t.Fatal("expected /0 to be cached")
+	}
+
+	fill(c, "b", 1, 1)
+
+	if c.lru.Len() != maxCacheEntries {
+		t.Errorf("expected %d entries, got %d", maxCacheEntries, c.lru.Len())
+	}
+
+	if cached(c, "a", "/0") == nil {
+		t.Error("expected recently used /0 to be kept")
+	}
+
+	if cached(c, "a", "/1") != nil {
+		t.Error("expected least recently used /1 to be evicted")
+	}
+}
+
+func TestResponseCacheSize(t *testing.T) {
+	c := newResponseCache()
+
+	fill(c, "a", 4, maxCacheSize/3)
+
+	if c.size > maxCacheSize {
+		t.Errorf("expected at most %d bytes, got %d", maxCacheSize, c.size)
+	}
+
+	if c.lru.Len() != 3 {
+		t.Errorf("expected 3 entries, got %d", c.lru.Len())
+	}
+
+	c.invalidate("a")
+
+	if c.size != 0 || c.lru.Len() != 0 || len(c.entries) != 0 {
+		t.Errorf("expected empty cache, got %d entries of %d bytes", c.lru.Len(), c.size)
+	}
+
+	fill(c, "a", 1, maxCacheSize+1)
+
+	if c.lru.Len() != 0 {
+		t.Error("expected a response larger than the cache not to be cached")
+	}
+}
+
+// roundTripFunc implements http.RoundTripper with a function.
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestCacheTransportIdentity(t *testing.T) {
+	cache := newResponseCache()
+
+	entered := make(chan string, 10)
+	release := make(chan struct{})
+
+	base := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		user := r.Header.Get("X-User")
+		entered <- user
+		<-release
+
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`"` + user + `"`)),
+			Request:    r,
+		}, nil
+	})
+
+	transport := func(token string) *cacheTransport {
+		return &cacheTransport{
+			target:   "target",
+			identity: tokenIdentity(&rc.TargetToken{Type: "bearer", Value: token}),
+			base:     base,
+			cache:    cache,
+		}
+	}
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 3)
+
+	get := func(i int, tr *cacheTransport, user string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req, _ := http.NewRequest(http.MethodGet, "http://ci.example.com/api/v1/teams", http.NoBody)
+			req.Header.Set("X-User", user)
+
+			resp, err := tr.RoundTrip(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer resp.Body.Close()
+
+			b, _ := io.ReadAll(resp.Body)
+			bodies[i] = string(b)
+		}()
+	}
+
+	wait := func(want bool) {
+		t.Helper()
+
+		timeout := 5 * time.Second
+		if !want {
+			timeout = 100 * time.Millisecond
+		}
+
+		select {
+		case user := <-entered:
+			if !want {
+				t.Errorf("expected the request of %q to be coalesced", user)
+			}
+		case <-time.After(timeout):
+			if want {
+				t.Error("expected a request to be made")
+			}
+		}
+	}
+
+	get(0, transport("a"), "a")
+	wait(true)
+
+	// A different user doesn't share the in-flight request.
+	get(1, transport("b"), "b")
+	wait(true)
+
+	// The same user does.
+	get(2, transport("a"), "a2")
+	wait(false)
+
+	close(release)
+	wg.Wait()
+
+	if want := []string{`"a"`, `"b"`, `"a"`}; strings.Join(bodies, ",") != strings.Join(want, ",") {
+		t.Errorf("expected bodies %v, got %v", want, bodies)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"github.com/apex/log"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/concourse/concourse/atc"
+)
+
+// CachesClearedMsg is returned when caches have been cleared, containing the
+// number of caches that were removed.
+type CachesClearedMsg struct {
+	// Name is a human readable description of what was cleared.
+	Name    string
+	Removed int64
+	Error   error
+}
+
+// ClearTaskCache clears the task cache of a job step, the equivalent of
+// `fly clear-task-cache`. If cachePath is empty, all caches for the step are
+// removed.
+func (c *apiManager) ClearTaskCache(pipeline atc.PipelineRef, job, step, cachePath string) tea.Cmd {
+	return func() tea.Msg {
+		defer c.Loading("clearing task cache")()
+
+		removed, err := c.Active().Team().ClearTaskCache(pipeline, job, step, cachePath)
+
+		c.logger.WithFields(log.Fields{
+			"pipeline": pipeline.String(),
+			"job":      job,
+			"step":     step,
+			"path":     cachePath,
+			"removed":  removed,
+			"error":    err,
+		}).Debug("cleared task cache")
+
+		return CachesClearedMsg{
+			Name:    "task cache of " + pipeline.String() + "/" + job + "/" + step,
+			Removed: removed,
+			Error:   err,
+		}
+	}
+}
+
+// ClearResourceCache clears the caches of a resource's versions, the equivalent
+// of `fly clear-resource-cache`. If version is nil, caches for all versions are
+// removed.
+func (c *apiManager) ClearResourceCache(pipeline atc.PipelineRef, resource string, version atc.Version) tea.Cmd {
+	return func() tea.Msg {
+		if err := c.Supports(c.ActiveName(), FeatureClearResourceCache); err != nil {
+			return CachesClearedMsg{Name: "resource cache of " + pipeline.String() + "/" + resource, Error: err}
+		}
+
+		defer c.Loading("clearing resource cache")()
+
+		removed, err := c.Active().Team().ClearResourceCache(pipeline, resource, version)
+
+		c.logger.WithFields(log.Fields{
+			"pipeline": pipeline.String(),
+			"resource": resource,
+			"version":  version,
+			"removed":  removed,
+			"error":    err,
+		}).Debug("cleared resource cache")
+
+		return CachesClearedMsg{
+			Name:    "resource cache of " + pipeline.String() + "/" + resource,
+			Removed: removed,
+			Error:   err,
+		}
+	}
+}
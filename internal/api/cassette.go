@@ -0,0 +1,506 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/concourse/concourse/fly/rc"
+)
+
+// ErrReplaying is returned by actions which aren't available while replaying a
+// cassette (e.g. anything that writes to the flyrc).
+var ErrReplaying = errors.New("not available while replaying a recorded session")
+
+// ErrNotRecorded is returned while replaying a cassette, for requests which
+// weren't recorded, or were made more often than while recording.
+var ErrNotRecorded = errors.New("not recorded")
+
+// cassetteInteraction is a single request made to an ATC and its response, as
+// stored in a cassette (one per line, in the order they completed).
+// Credentials are redacted.
+type cassetteInteraction struct {
+	Target string    `json:"target"`
+	API    string    `json:"api"`
+	Team   string    `json:"team"`
+	Time   time.Time `json:"time"`
+
+	Method        string       `json:"method"`
+	URI           string       `json:"uri"`
+	RequestHeader http.Header  `json:"request_header,omitempty"`
+	RequestBody   cassetteBody `json:"request_body,omitempty"`
+
+	Status         int          `json:"status,omitempty"`
+	ResponseHeader http.Header  `json:"response_header,omitempty"`
+	ResponseBody   cassetteBody `json:"response_body,omitempty"`
+
+	// Incomplete is true if the response was still being received when the
+	// recording stopped (e.g. build events which were still streaming).
+	Incomplete bool `json:"incomplete,omitempty"`
+
+	// Omitted is true if the response body wasn't recorded, as it's neither
+	// JSON nor text (e.g. artifacts, or fly binaries).
+	Omitted bool `json:"omitted,omitempty"`
+
+	// Error is the error of the request, if no response was received.
+	Error string `json:"error,omitempty"`
+}
+
+// key returns the key a replayed request has to match.
+func (i *cassetteInteraction) key() string {
+	return i.Target + " " + i.Method + " " + i.URI
+}
+
+// cassetteBody is a body in a cassette. Text bodies are stored as a string,
+// so cassettes remain readable, and binary bodies as {"base64": "..."}.
+type cassetteBody []byte
+
+func (b cassetteBody) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *cassetteBody) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+
+		*b = cassetteBody(s)
+		return nil
+	}
+
+	var encoded struct {
+		Base64 []byte `json:"base64"`
+	}
+
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	*b = encoded.Base64
+	return nil
+}
+
+// cassetteRecorder writes all interactions with the ATCs to a cassette file.
+type cassetteRecorder struct {
+	mu      sync.Mutex
+	file    *os.File
+	enc     *json.Encoder
+	pending map[*cassetteInteraction]*bytes.Buffer
+}
+
+func newCassetteRecorder(path string) (*cassetteRecorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cassette: %w", err)
+	}
+
+	return &cassetteRecorder{
+		file:    f,
+		enc:     json.NewEncoder(f),
+		pending: map[*cassetteInteraction]*bytes.Buffer{},
+	}, nil
+}
+
+// write writes the interaction to the cassette. Each interaction is written
+// as soon as it completes, so a crash doesn't lose the whole cassette.
+func (r *cassetteRecorder) write(i *cassetteInteraction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.writeLocked(i)
+}
+
+func (r *cassetteRecorder) writeLocked(i *cassetteInteraction) {
+	if r.file == nil {
+		return
+	}
+
+	_ = r.enc.Encode(i)
+}
+
+// finish writes an interaction once its response body was read. incomplete is
+// true if the body was closed before it was fully read.
+func (r *cassetteRecorder) finish(i *cassetteInteraction, incomplete bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	buf, ok := r.pending[i]
+	if !ok {
+		return
+	}
+
+	delete(r.pending, i)
+
+	i.ResponseBody = buf.Bytes()
+	i.Incomplete = incomplete
+	r.writeLocked(i)
+}
+
+// close writes all interactions still being received, and closes the
+// cassette.
+func (r *cassetteRecorder) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	for i, buf := range r.pending {
+		i.ResponseBody = buf.Bytes()
+		i.Incomplete = true
+		r.writeLocked(i)
+	}
+
+	r.pending = nil
+
+	err := r.file.Close()
+	r.file = nil
+
+	return err
+}
+
+// recordTransport records all requests made to a target, and their responses,
+// in a cassette. It wraps all other transports (only contextTransport wraps it),
+// so it records the responses the client actually sees (e.g. after cached
+// responses are revalidated).
+type recordTransport struct {
+	target string
+	props  rc.TargetProps
+	base   http.RoundTripper
+	rec    *cassetteRecorder
+}
+
+func (t *recordTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	sensitive := r.URL.Path == tokenPath
+
+	i := &cassetteInteraction{
+		Target:        t.target,
+		API:           t.props.API,
+		Team:          t.props.TeamName,
+		Time:          time.Now(),
+		Method:        r.Method,
+		URI:           r.URL.RequestURI(),
+		RequestHeader: redactHeader(r.Header),
+	}
+
+	switch {
+	case sensitive:
+		i.RequestBody = cassetteBody(redacted)
+	case r.Body != nil && r.Body != http.NoBody:
+		// Request bodies aren't needed to replay, so only record the start of
+		// them (e.g. not the inputs uploaded by execute).
+		r = r.Clone(r.Context())
+		r.Body = &teeBody{ReadCloser: r.Body, limit: maxRecordedBody, buf: (*[]byte)(&i.RequestBody)}
+	}
+
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		i.Error = err.Error()
+		t.rec.write(i)
+		return resp, err
+	}
+
+	i.Status = resp.StatusCode
+	i.ResponseHeader = redactHeader(resp.Header)
+
+	if sensitive {
+		i.ResponseBody = cassetteBody(redacted)
+		t.rec.write(i)
+		return resp, nil
+	}
+
+	if !isRecordable(resp.Header.Get("Content-Type")) {
+		i.Omitted = true
+		t.rec.write(i)
+		return resp, nil
+	}
+
+	buf := &bytes.Buffer{}
+
+	t.rec.mu.Lock()
+	if t.rec.pending != nil {
+		t.rec.pending[i] = buf
+	}
+	t.rec.mu.Unlock()
+
+	resp.Body = &cassetteBodyReader{
+		ReadCloser:  resp.Body,
+		rec:         t.rec,
+		interaction: i,
+		buf:         buf,
+		stream:      isEventStream(resp.Header.Get("Content-Type")),
+	}
+	return resp, nil
+}
+
+// teeBody copies up to limit bytes of a body into buf, as it's read.
+type teeBody struct {
+	io.ReadCloser
+	limit int
+	buf   *[]byte
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	if room := b.limit - len(*b.buf); room > 0 {
+		if room > n {
+			room = n
+		}
+
+		*b.buf = append(*b.buf, p[:room]...)
+	}
+
+	return n, err
+}
+
+// cassetteBodyReader records a response body as it's read, and writes the
+// interaction once the body has been fully read or closed. Event streams
+// closed before they ended (e.g. leaving a build which is still running) are
+// recorded as incomplete, the rest of other bodies is read when closed, so
+// they can be replayed in full.
+type cassetteBodyReader struct {
+	io.ReadCloser
+	rec         *cassetteRecorder
+	interaction *cassetteInteraction
+	buf         *bytes.Buffer
+	stream      bool
+	done        bool
+}
+
+func (b *cassetteBodyReader) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	b.rec.mu.Lock()
+	b.buf.Write(p[:n])
+	b.rec.mu.Unlock()
+
+	if err != nil && !b.done {
+		b.done = true
+		b.rec.finish(b.interaction, false)
+	}
+
+	return n, err
+}
+
+func (b *cassetteBodyReader) Close() error {
+	if !b.done {
+		b.done = true
+
+		if !b.stream {
+			_, _ = io.Copy(io.Discard, b)
+		}
+
+		b.rec.finish(b.interaction, b.stream)
+	}
+
+	return b.ReadCloser.Close()
+}
+
+// isRecordable returns true if response bodies of the provided content type are
+// recorded. Binary bodies are potentially large (e.g. artifacts, or fly
+// binaries), and aren't needed to replay the UI.
+func isRecordable(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	typ, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return typ == "application/json" || strings.HasSuffix(typ, "+json") || strings.HasPrefix(typ, "text/")
+}
+
+// isEventStream returns true if the provided content type is a server-sent
+// event stream (e.g. build events).
+func isEventStream(contentType string) bool {
+	typ, _, err := mime.ParseMediaType(contentType)
+	return err == nil && typ == "text/event-stream"
+}
+
+// cassettePlayer serves requests from a cassette, instead of the network.
+type cassettePlayer struct {
+	mu      sync.Mutex
+	targets rc.Targets
+	queues  map[string][]*cassetteInteraction
+	served  map[string]int
+}
+
+// loadCassette loads the cassette at path. The targets are the ones used while
+// recording, without their tokens.
+func loadCassette(path string) (*cassettePlayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cassette: %w", err)
+	}
+	defer f.Close()
+
+	p := &cassettePlayer{
+		targets: rc.Targets{},
+		queues:  map[string][]*cassetteInteraction{},
+		served:  map[string]int{},
+	}
+
+	dec := json.NewDecoder(f)
+
+	for n := 1; ; n++ {
+		i := &cassetteInteraction{}
+
+		err = dec.Decode(i)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("in the cassette '%s': interaction %d: %w", path, n, err)
+		}
+
+		p.targets[rc.TargetName(i.Target)] = rc.TargetProps{
+			API:      i.API,
+			TeamName: i.Team,
+			Token:    &rc.TargetToken{Type: "bearer", Value: redacted},
+		}
+
+		p.queues[i.key()] = append(p.queues[i.key()], i)
+	}
+
+	return p, nil
+}
+
+// next returns the next recorded interaction for the provided request, in the
+// order they were recorded. Returns ErrNotRecorded if the request wasn't
+// recorded, or all recorded interactions for it were already served (e.g. a
+// query polled for longer than while recording).
+func (p *cassettePlayer) next(target string, r *http.Request) (*cassetteInteraction, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := target + " " + r.Method + " " + r.URL.RequestURI()
+
+	queue := p.queues[key]
+	if len(queue) == 0 {
+		if served := p.served[key]; served > 0 {
+			return nil, fmt.Errorf("%s %s was only recorded %d time(s): %w", r.Method, r.URL.RequestURI(), served, ErrNotRecorded)
+		}
+
+		return nil, fmt.Errorf("%s %s: %w", r.Method, r.URL.RequestURI(), ErrNotRecorded)
+	}
+
+	p.queues[key] = queue[1:]
+	p.served[key]++
+
+	return queue[0], nil
+}
+
+// replayTransport serves all requests made to a target from a cassette. It
+// replaces the network, so the rest of the transports (e.g. the cache) behave
+// the same as while recording.
+type replayTransport struct {
+	target string
+	player *cassettePlayer
+}
+
+func (t *replayTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Body != nil {
+		_, _ = io.Copy(io.Discard, r.Body)
+		r.Body.Close()
+	}
+
+	i, err := t.player.next(t.target, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if i.Error != "" {
+		return nil, errors.New(i.Error)
+	}
+
+	if i.Omitted {
+		return nil, fmt.Errorf("the response of %s %s wasn't recorded", r.Method, r.URL.RequestURI())
+	}
+
+	var body io.ReadCloser = io.NopCloser(bytes.NewReader(i.ResponseBody))
+	if i.Incomplete {
+		// The stream was still open when the recording stopped, so keep it
+		// open until the client is done with it.
+		body = newOpenBody(r.Context(), i.ResponseBody)
+	}
+
+	header := i.ResponseHeader.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+
+	return &http.Response{
+		Status:        strconv.Itoa(i.Status) + " " + http.StatusText(i.Status),
+		StatusCode:    i.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          body,
+		ContentLength: -1,
+		Request:       r,
+	}, nil
+}
+
+// openBody is a body which blocks once its data has been read, until it's
+// closed or the request is canceled, like a stream with no further events.
+type openBody struct {
+	io.Reader
+	ctx    context.Context
+	once   sync.Once
+	closed chan struct{}
+}
+
+func newOpenBody(ctx context.Context, data []byte) *openBody {
+	return &openBody{Reader: bytes.NewReader(data), ctx: ctx, closed: make(chan struct{})}
+}
+
+func (b *openBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if !errors.Is(err, io.EOF) {
+		return n, err
+	}
+
+	if n > 0 {
+		return n, nil
+	}
+
+	select {
+	case <-b.closed:
+		return 0, io.EOF
+	case <-b.ctx.Done():
+		return 0, b.ctx.Err()
+	}
+}
+
+func (b *openBody) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	return nil
+}
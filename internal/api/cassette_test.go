@@ -0,0 +1,293 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/concourse/concourse/atc"
+	"github.com/concourse/concourse/fly/rc"
+	"github.com/lrstanley/clix"
+	"github.com/lrstanley/hangar-ui/internal/fakeatc"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+// record makes a request through a recordTransport, with base responding,
+// reads the full response, and returns the response body along with the
+// recorded interactions.
+func record(t *testing.T, r *http.Request, base roundTripFunc) (body []byte, interactions []cassetteInteraction) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+
+	rec, err := newCassetteRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transport := &recordTransport{
+		target: "target",
+		props:  rc.TargetProps{API: "http://ci.example.com", TeamName: "main"},
+		base:   base,
+		rec:    rec,
+	}
+
+	resp, err := transport.RoundTrip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body, err = io.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if err = rec.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for dec.More() {
+		var i cassetteInteraction
+		if err = dec.Decode(&i); err != nil {
+			t.Fatal(err)
+		}
+
+		interactions = append(interactions, i)
+	}
+
+	return body, interactions
+}
+
+func TestRecord(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodPut, "http://ci.example.com/api/v1/teams/main/pipelines/app/pause?x=1", strings.NewReader("{}"))
+	r.Header.Set("Authorization", "Bearer secret-token")
+
+	var sent string
+
+	body, interactions := record(t, r, func(r *http.Request) (*http.Response, error) {
+		sent = r.Header.Get("Authorization")
+		_, _ = io.Copy(io.Discard, r.Body)
+
+		return respond(r, http.Header{"Content-Type": []string{"application/json"}}, []byte(`{"ok":true}`)), nil
+	})
+
+	if len(interactions) != 1 {
+		t.Fatalf("expected 1 interaction, got %d", len(interactions))
+	}
+
+	i := interactions[0]
+
+	if i.Target != "target" || i.API != "http://ci.example.com" || i.Team != "main" {
+		t.Errorf("expected the target to be recorded, got %+v", i)
+	}
+
+	if i.Method != http.MethodPut || i.URI != "/api/v1/teams/main/pipelines/app/pause?x=1" || i.Status != http.StatusOK {
+		t.Errorf("expected the request to be recorded, got %+v", i)
+	}
+
+	if string(i.RequestBody) != "{}" || string(i.ResponseBody) != `{"ok":true}` || string(body) != `{"ok":true}` {
+		t.Errorf("expected the bodies to be recorded, got %q and %q", i.RequestBody, i.ResponseBody)
+	}
+
+	if got := i.RequestHeader.Get("Authorization"); got != "Bearer "+redacted {
+		t.Errorf("expected the token to be redacted, got %q", got)
+	}
+
+	if sent != "Bearer secret-token" {
+		t.Errorf("expected the request to be sent unmodified, got %q", sent)
+	}
+}
+
+func TestRecordRedactsTokenBodies(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodPost, "http://ci.example.com"+tokenPath, strings.NewReader("password=secret"))
+
+	body, interactions := record(t, r, func(r *http.Request) (*http.Response, error) {
+		return respond(r, http.Header{"Content-Type": []string{"application/json"}}, []byte(`{"access_token":"secret"}`)), nil
+	})
+
+	if string(body) != `{"access_token":"secret"}` {
+		t.Errorf("expected the response to be passed through, got %q", body)
+	}
+
+	if len(interactions) != 1 {
+		t.Fatalf("expected 1 interaction, got %d", len(interactions))
+	}
+
+	if i := interactions[0]; string(i.RequestBody) != redacted || string(i.ResponseBody) != redacted {
+		t.Errorf("expected the bodies to be redacted, got %q and %q", i.RequestBody, i.ResponseBody)
+	}
+}
+
+func TestRecordOmitsBinaryBodies(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "http://ci.example.com/api/v1/teams/main/artifacts/1", http.NoBody)
+	data := []byte{0x1f, 0x8b, 0x00, 0xff}
+
+	body, interactions := record(t, r, func(r *http.Request) (*http.Response, error) {
+		return respond(r, http.Header{"Content-Type": []string{"application/octet-stream"}}, data), nil
+	})
+
+	if !bytes.Equal(body, data) {
+		t.Errorf("expected the response to be passed through, got %q", body)
+	}
+
+	if len(interactions) != 1 {
+		t.Fatalf("expected 1 interaction, got %d", len(interactions))
+	}
+
+	if i := interactions[0]; !i.Omitted || len(i.ResponseBody) != 0 {
+		t.Errorf("expected the body to be omitted, got %+v", i)
+	}
+}
+
+// writeCassette writes the provided interactions to a cassette, and loads it.
+func writeCassette(t *testing.T, interactions ...cassetteInteraction) *cassettePlayer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	for i := range interactions {
+		if err := enc.Encode(&interactions[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	writeFile(t, path, buf.String())
+
+	player, err := loadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return player
+}
+
+func TestReplay(t *testing.T) {
+	player := writeCassette(t,
+		cassetteInteraction{Target: "a", API: "http://a", Team: "main", Method: http.MethodGet, URI: "/api/v1/info", Status: 200, ResponseBody: cassetteBody("first")},
+		cassetteInteraction{Target: "b", API: "http://b", Team: "dev", Method: http.MethodGet, URI: "/api/v1/info", Status: 200, ResponseBody: cassetteBody("other")},
+		cassetteInteraction{Target: "a", API: "http://a", Team: "main", Method: http.MethodGet, URI: "/api/v1/info", Status: 200, ResponseBody: cassetteBody("second")},
+		cassetteInteraction{Target: "a", API: "http://a", Team: "main", Method: http.MethodGet, URI: "/api/v1/artifact", Status: 200, Omitted: true},
+		cassetteInteraction{Target: "a", API: "http://a", Team: "main", Method: http.MethodGet, URI: "/api/v1/down", Error: "connection refused"},
+	)
+
+	if props := player.targets["b"]; props.API != "http://b" || props.TeamName != "dev" {
+		t.Errorf("expected the targets to be loaded, got %+v", player.targets)
+	}
+
+	transport := &replayTransport{target: "a", player: player}
+
+	get := func(uri string) (string, error) {
+		r, _ := http.NewRequest(http.MethodGet, "http://a"+uri, http.NoBody)
+
+		resp, err := transport.RoundTrip(r)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+
+	// Interactions are replayed in order, per target.
+	for _, want := range []string{"first", "second"} {
+		if got, err := get("/api/v1/info"); err != nil || got != want {
+			t.Errorf("expected %q, got %q (%v)", want, got, err)
+		}
+	}
+
+	if _, err := get("/api/v1/info"); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("expected an overrun to fail, got %v", err)
+	}
+
+	if _, err := get("/api/v1/info?x=1"); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("expected a miss to fail, got %v", err)
+	}
+
+	if _, err := get("/api/v1/artifact"); err == nil {
+		t.Error("expected an omitted response to fail")
+	}
+
+	if _, err := get("/api/v1/down"); err == nil || err.Error() != "connection refused" {
+		t.Errorf("expected the recorded error, got %v", err)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	state := fakeatc.NewState()
+	state.AddPipeline(atc.Pipeline{Name: "app"}, nil, nil)
+	state.AddPipeline(atc.Pipeline{Name: "infra", Paused: true}, nil, nil)
+
+	cassette := filepath.Join(t.TempDir(), "cassette.jsonl")
+
+	newClient := func(flags *types.Flags) *apiManager {
+		flags.Target = testTarget
+		flags.CacheDir = t.TempDir()
+
+		return NewAPIClient(context.Background(), &clix.CLI[types.Flags]{Flags: flags}).(*apiManager)
+	}
+
+	srv := fakeatc.New(state)
+	defer srv.Close()
+
+	restore, err := srv.UseFlyrc(testTarget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+
+	c := newClient(&types.Flags{Record: cassette})
+
+	recorded := c.QueryPipelines().(PipelineListMsg)
+	if recorded.Error != nil {
+		t.Fatal(recorded.Error)
+	}
+
+	c.Close()
+	srv.Close()
+
+	b, err := os.ReadFile(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(b), state.Token) {
+		t.Error("expected the token not to be recorded")
+	}
+
+	c = newClient(&types.Flags{Replay: cassette})
+	defer c.Close()
+
+	replayed := c.QueryPipelines().(PipelineListMsg)
+	if replayed.Error != nil {
+		t.Fatal(replayed.Error)
+	}
+
+	if !reflect.DeepEqual(replayed.Pipelines, recorded.Pipelines) {
+		t.Errorf("expected the pipelines to be replayed, got %v, want %v", replayed.Pipelines, recorded.Pipelines)
+	}
+
+	if msg := c.QueryPipelines().(PipelineListMsg); !errors.Is(msg.Error, ErrNotRecorded) {
+		t.Errorf("expected querying more than recorded to fail, got %v", msg.Error)
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/concourse/concourse/atc"
+	"github.com/concourse/concourse/fly/rc"
+	"github.com/concourse/concourse/fly/version"
+	"github.com/concourse/concourse/go-concourse/concourse"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+// APIVersion is the Concourse version which the bundled API client (and as
+// such, hangar-ui) was built against. go.mod pins an untagged commit of
+// concourse (so it can't be derived from the build info), made after 7.7.1
+// and before 7.8.0, whose API matches 7.7.1. It has to be updated along with
+// the dependency.
+const APIVersion = "7.7.1"
+
+// Feature is an action which isn't supported by all Concourse versions.
+type Feature string
+
+const (
+	FeatureClearResourceCache Feature = "clear-resource-cache"
+)
+
+// featureVersions are the minimum Concourse versions which support a feature.
+var featureVersions = map[Feature]string{
+	FeatureClearResourceCache: "7.7.0",
+}
+
+// UnsupportedError is returned when an action isn't supported by the Concourse
+// version of a target.
+type UnsupportedError struct {
+	Feature  Feature
+	Version  string
+	Required string
+}
+
+func (e UnsupportedError) Error() string {
+	return fmt.Sprintf("%s requires concourse %s or newer, the target is on %s", e.Feature, e.Required, e.Version)
+}
+
+// targetCompat is the last known version info of a target.
+type targetCompat struct {
+	info atc.Info
+
+	// outdatedWorkers is the number of workers with an incompatible version, or
+	// -1 if the workers couldn't be listed (e.g. because of missing auth).
+	outdatedWorkers int
+}
+
+// warnings returns human readable warnings about version mismatches between
+// hangar-ui, the target, and its workers.
+func (tc targetCompat) warnings() (warnings []string) {
+	if tc.info.Version != "" && !version.IsDev(tc.info.Version) {
+		server := parseVersion(tc.info.Version)
+		built := parseVersion(APIVersion)
+
+		switch {
+		case len(server) < 2:
+		case server[0] != built[0]:
+			warnings = append(warnings, fmt.Sprintf(
+				"concourse %s is unsupported, hangar-ui was built for %s", tc.info.Version, APIVersion,
+			))
+		case server[1] < built[1]:
+			warning := fmt.Sprintf("concourse %s is older than %s", tc.info.Version, APIVersion)
+
+			if unavailable := unsupportedFeatures(server); len(unavailable) > 0 {
+				warning += ", unavailable: " + strings.Join(unavailable, ", ")
+			}
+
+			warnings = append(warnings, warning)
+		case server[1] > built[1]:
+			warnings = append(warnings, fmt.Sprintf(
+				"concourse %s is newer than %s, hangar-ui may need to be updated", tc.info.Version, APIVersion,
+			))
+		}
+	}
+
+	if tc.outdatedWorkers > 0 {
+		warnings = append(warnings, fmt.Sprintf(
+			"%d worker(s) need to be updated to version %s", tc.outdatedWorkers, tc.info.WorkerVersion,
+		))
+	}
+
+	return warnings
+}
+
+// unsupportedFeatures returns the features which aren't supported by the
+// provided (parsed) Concourse version.
+func unsupportedFeatures(server []int) (features []string) {
+	for feature, required := range featureVersions {
+		if versionLess(server, parseVersion(required)) {
+			features = append(features, string(feature))
+		}
+	}
+
+	sort.Strings(features)
+	return features
+}
+
+// parseVersion parses the release components of a version (e.g. "7.8.0" or
+// "2.3"), ignoring any pre-release or build suffixes. Returns nil if the
+// version can't be parsed.
+func parseVersion(v string) []int {
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+
+	parts := strings.Split(v, ".")
+	out := make([]int, len(parts))
+
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil
+		}
+
+		out[i] = n
+	}
+
+	return out
+}
+
+// versionLess returns true if version a is older than version b. Missing
+// components are treated as 0.
+func versionLess(a, b []int) bool {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+
+		if x != y {
+			return x < y
+		}
+	}
+
+	return false
+}
+
+// isWorkerCompatible returns true if the worker version is compatible with the
+// worker version expected by the ATC, the same as the ATC does when workers
+// register: the major versions must match, and the worker can't be behind on
+// the minor version.
+func isWorkerCompatible(worker, expected string) bool {
+	w, e := parseVersion(worker), parseVersion(expected)
+	if len(w) < 2 || len(e) < 2 {
+		return false
+	}
+
+	return w[0] == e[0] && w[1] >= e[1]
+}
+
+// outdatedWorkers returns the number of workers which aren't compatible with
+// the ATC.
+func outdatedWorkers(info atc.Info, workers []atc.Worker) (outdated int) {
+	if info.WorkerVersion == "" {
+		return 0
+	}
+
+	for _, w := range workers {
+		if !isWorkerCompatible(w.Version, info.WorkerVersion) {
+			outdated++
+		}
+	}
+
+	return outdated
+}
+
+// Compat returns warnings about version mismatches between hangar-ui, the
+// provided target, and its workers, as of the last time the target was
+// queried.
+func (c *apiManager) Compat(target string) []string {
+	c.compatMu.Lock()
+	defer c.compatMu.Unlock()
+
+	tc, ok := c.compat[target]
+	if !ok {
+		return nil
+	}
+
+	return tc.warnings()
+}
+
+// Supports returns an UnsupportedError if the provided target is known to be
+// on a Concourse version which doesn't support the feature. If the version of
+// the target isn't known (yet), the feature is assumed to be supported.
+func (c *apiManager) Supports(target string, feature Feature) error {
+	required, ok := featureVersions[feature]
+	if !ok {
+		return nil
+	}
+
+	c.compatMu.Lock()
+	current := c.compat[target].info.Version
+	c.compatMu.Unlock()
+
+	v := parseVersion(current)
+	if v == nil || version.IsDev(current) {
+		return nil
+	}
+
+	if versionLess(v, parseVersion(required)) {
+		return UnsupportedError{Feature: feature, Version: current, Required: required}
+	}
+
+	return nil
+}
+
+// updateCompat stores the version info of a target, and returns the resulting
+// warnings. workers should be nil if they couldn't be listed, in which case
+// the last known worker state is kept.
+func (c *apiManager) updateCompat(target string, info atc.Info, workers []atc.Worker) []string {
+	if info.Version == "" {
+		return c.Compat(target)
+	}
+
+	c.compatMu.Lock()
+
+	previous, ok := c.compat[target]
+	if !ok {
+		previous.outdatedWorkers = -1
+	}
+
+	tc := targetCompat{info: info, outdatedWorkers: previous.outdatedWorkers}
+	if workers != nil {
+		tc.outdatedWorkers = outdatedWorkers(info, workers)
+	}
+
+	c.compat[target] = tc
+	c.compatMu.Unlock()
+
+	warnings := tc.warnings()
+
+	if ok && reflect.DeepEqual(warnings, previous.warnings()) {
+		return warnings
+	}
+
+	if len(warnings) > 0 {
+		c.logger.WithFields(log.Fields{
+			"target":   target,
+			"warnings": warnings,
+		}).Warn("target version mismatch")
+	}
+
+	if target == c.ActiveName() {
+		c.bus.Publish(types.TopicState, types.FlyCompatUpdated)
+	}
+
+	return warnings
+}
+
+// checkCompat queries the version info of a target in the background, so
+// compatibility warnings are known without the targets view being open. It
+// should be invoked as a goroutine, with the waitgroup already incremented.
+func (c *apiManager) checkCompat(name string, t rc.Target) {
+	defer c.wg.Done()
+
+	timeout := c.targetTimeout(name)
+	deadline := time.Now().Add(timeout)
+	client, httpClient := contextClient(c.ctx, t.Client(), timeout)
+
+	info, err := client.GetInfo()
+	if err != nil {
+		c.logger.WithError(err).WithField("target", name).Debug("failed to check target version")
+		return
+	}
+
+	c.updateCompat(name, info, listWorkers(client, deadline, httpClient))
+}
+
+// listWorkers lists the workers of a target before the deadline, returning nil
+// on failure. Listing workers requires authentication, so errors are expected.
+func listWorkers(client concourse.Client, deadline time.Time, httpClient *http.Client) []atc.Worker {
+	if httpClient.Timeout = time.Until(deadline); httpClient.Timeout <= 0 {
+		return nil
+	}
+
+	workers, err := client.ListWorkers()
+	if err != nil {
+		return nil
+	}
+
+	if workers == nil {
+		workers = []atc.Worker{}
+	}
+
+	return workers
+}
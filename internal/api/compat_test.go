@@ -0,0 +1,35 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/concourse/concourse/atc"
+)
+
+func TestCompatWarnings(t *testing.T) {
+	tests := []struct {
+		version string
+		want    []string
+	}{
+		{APIVersion, nil},
+		{"7.7.0", nil},
+		{"7.6.0", []string{"concourse 7.6.0 is older than " + APIVersion + ", unavailable: clear-resource-cache"}},
+		{"7.9.0", []string{"concourse 7.9.0 is newer than " + APIVersion + ", hangar-ui may need to be updated"}},
+		{"6.7.0", []string{"concourse 6.7.0 is unsupported, hangar-ui was built for " + APIVersion}},
+		{"0.0.0-dev", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			got := targetCompat{info: atc.Info{Version: tt.version}}.warnings()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the config file of hangar-ui itself, for settings which the flyrc
+// (shared with fly) has no place for.
+type Config struct {
+	// Targets are per-target settings, keyed by target name.
+	Targets map[string]TransportConfig `json:"targets,omitempty"`
+}
+
+// TransportConfig are the HTTP transport settings of a target. They apply to
+// all requests made to the target, in addition to its flyrc settings.
+type TransportConfig struct {
+	// Proxy is the URL of an HTTP(S) or SOCKS5 proxy, used instead of the
+	// proxy from the environment (e.g. socks5://proxy.example.com:1080).
+	Proxy string `json:"proxy,omitempty"`
+
+	// CABundle is the path to a PEM encoded CA bundle, trusted in addition to
+	// the system CAs and the flyrc ca_cert.
+	CABundle string `json:"ca_bundle,omitempty"`
+
+	// ClientCert and ClientKey are the paths to a PEM encoded client
+	// certificate and key, used for mutual TLS instead of the ones from the
+	// flyrc (if any).
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"`
+
+	// Timeout is how long to wait for the target to accept a connection
+	// (including the TLS handshake), and to send the headers of a response
+	// (e.g. 30s). Response bodies aren't limited, so streamed responses (e.g.
+	// build events) can stay open.
+	Timeout string `json:"timeout,omitempty"`
+
+	proxyURL *url.URL
+	timeout  time.Duration
+}
+
+// ConfigPath returns the path of the config file, defaulting to config.yaml in
+// the hangar-ui user config directory if path is empty.
+func ConfigPath(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "hangar-ui", "config.yaml"), nil
+}
+
+// LoadConfig loads and validates the config file at path (see ConfigPath). A
+// missing config file isn't an error.
+func LoadConfig(path string) (*Config, error) {
+	path, err := ConfigPath(path)
+	if err != nil {
+		// Without a user config directory, there's no default config either.
+		return &Config{}, nil
+	}
+
+	cfg := &Config{}
+
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return cfg, nil
+	case err != nil:
+		return nil, err
+	}
+
+	if err = yaml.UnmarshalStrict(b, cfg); err != nil {
+		return nil, fmt.Errorf("in the file '%s': %w", path, err)
+	}
+
+	for name, tc := range cfg.Targets {
+		if err = tc.validate(); err != nil {
+			return nil, fmt.Errorf("in the file '%s': target %q: %w", path, name, err)
+		}
+
+		cfg.Targets[name] = tc
+	}
+
+	return cfg, nil
+}
+
+// validate validates the settings, and parses the proxy URL and timeout.
+func (tc *TransportConfig) validate() error {
+	if tc.Proxy != "" {
+		u, err := url.Parse(tc.Proxy)
+		if err != nil {
+			return fmt.Errorf("invalid proxy: %w", err)
+		}
+
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return fmt.Errorf("invalid proxy %q, should be an http, https or socks5 URL", tc.Proxy)
+		}
+
+		tc.proxyURL = u
+	}
+
+	if (tc.ClientCert == "") != (tc.ClientKey == "") {
+		return errors.New("client_cert and client_key must be set together")
+	}
+
+	if tc.Timeout != "" {
+		timeout, err := time.ParseDuration(tc.Timeout)
+		if err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid timeout %q, should be a duration like 30s", tc.Timeout)
+		}
+
+		tc.timeout = timeout
+	}
+
+	return nil
+}
+
+// loadedConfig is the config file, as last loaded by the client.
+type loadedConfig struct {
+	cfg *Config
+	err error // Set if the config file was invalid on startup.
+}
+
+// ConfigReloadedMsg is sent once the config file was reloaded (see
+// ReloadConfig).
+type ConfigReloadedMsg struct {
+	Path  string
+	Error error
+}
+
+// ReloadConfig reloads the config file, and the loaded targets with its
+// settings. If the config file is invalid, the previous settings are kept.
+func (c *apiManager) ReloadConfig() tea.Cmd {
+	return func() tea.Msg {
+		path, _ := ConfigPath(c.config.Flags.Config)
+
+		cfg, err := LoadConfig(c.config.Flags.Config)
+		if err != nil {
+			return ConfigReloadedMsg{Path: path, Error: err}
+		}
+
+		c.settings.Store(loadedConfig{cfg: cfg})
+		c.logger.WithField("path", path).Info("reloaded config")
+
+		// Targets are only loaded once (see loadTarget), so they have to be
+		// loaded again with the new settings.
+		c.unloadTargets()
+
+		if name := c.ActiveName(); name != "" {
+			if err = c.SetActive(name); err != nil {
+				return ConfigReloadedMsg{Path: path, Error: fmt.Errorf("failed to reload target %q: %w", name, err)}
+			}
+		}
+
+		return ConfigReloadedMsg{Path: path}
+	}
+}
+
+// transportConfig returns the transport settings of the provided target, from
+// the config file.
+func (c *apiManager) transportConfig(name string) (TransportConfig, error) {
+	settings := c.settings.Load()
+	if settings.err != nil {
+		return TransportConfig{}, settings.err
+	}
+
+	if settings.cfg == nil {
+		return TransportConfig{}, nil
+	}
+
+	return settings.cfg.Targets[name], nil
+}
+
+// targetTimeout returns how long the provided target has to respond to health
+// checks, which is the configured timeout of the target (if any).
+func (c *apiManager) targetTimeout(name string) time.Duration {
+	if tc, err := c.transportConfig(name); err == nil && tc.timeout > 0 {
+		return tc.timeout
+	}
+
+	return targetInfoTimeout
+}
@@ -0,0 +1,51 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lrstanley/hangar-ui/internal/fakeatc"
+)
+
+func TestReloadConfig(t *testing.T) {
+	c, _ := newTestClient(t, fakeatc.NewState())
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	c.config.Flags.Config = path
+
+	writeFile(t, path, "targets:\n  fake:\n    timeout: 5s\n")
+
+	// The config is only loaded on startup, until reloaded.
+	if timeout := c.targetTimeout(testTarget); timeout != targetInfoTimeout {
+		t.Fatalf("expected the default timeout before reloading, got %s", timeout)
+	}
+
+	active := c.Active()
+
+	if msg := c.ReloadConfig()().(ConfigReloadedMsg); msg.Error != nil {
+		t.Fatalf("unexpected error: %v", msg.Error)
+	}
+
+	if timeout := c.targetTimeout(testTarget); timeout != 5*time.Second {
+		t.Errorf("expected the configured timeout, got %s", timeout)
+	}
+
+	if c.Active() == active {
+		t.Error("expected the active target to be reloaded")
+	}
+
+	writeFile(t, path, "targets:\n  fake:\n    timeout: never\n")
+
+	if msg := c.ReloadConfig()().(ConfigReloadedMsg); msg.Error == nil {
+		t.Error("expected an invalid config to fail")
+	}
+
+	if timeout := c.targetTimeout(testTarget); timeout != 5*time.Second {
+		t.Errorf("expected the previous config to be kept, got %s", timeout)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/apex/log"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/concourse/concourse/atc"
+	"github.com/lrstanley/hangar-ui/internal/types"
+	"sigs.k8s.io/yaml"
+)
+
+// ErrConfigChanged is returned when a pipeline config was changed on the server
+// after it was fetched, and applying the local copy would clobber it.
+var ErrConfigChanged = errors.New("pipeline config was changed on the server since it was fetched, refusing to overwrite it")
+
+// PipelineConfigMsg contains the currently deployed config of a pipeline, and
+// the config version, which is required when applying changes.
+type PipelineConfigMsg struct {
+	Target   string
+	Team     string
+	Pipeline atc.PipelineRef
+	Config   []byte
+	Version  string
+	Error    error
+}
+
+// PipelineConfigAppliedMsg is returned once a pipeline config has been
+// applied.
+type PipelineConfigAppliedMsg struct {
+	Target   string
+	Team     string
+	Pipeline atc.PipelineRef
+	Warnings []string
+	Error    error
+}
+
+// QueryPipelineConfig fetches the deployed config of the provided pipeline, as
+// YAML. If target is empty, the active target is used. The query is canceled
+// when view is left.
+func (c *apiManager) QueryPipelineConfig(view types.Viewable, target, team string, pipeline atc.PipelineRef) tea.Cmd {
+	return func() tea.Msg {
+		defer c.Loading("fetching pipeline config")()
+
+		msg := PipelineConfigMsg{Target: target, Team: team, Pipeline: pipeline}
+
+		client, name, err := c.clientFor(target)
+		if err != nil {
+			msg.Error = err
+			return msg
+		}
+
+		ctx := c.viewContextOf(view)
+		client, _ = contextClient(ctx, client, 0)
+
+		config, version, found, err := client.Team(team).PipelineConfig(pipeline)
+		if ctx.Err() != nil {
+			return nil
+		}
+		err = c.checkAuth(name, "config:"+team+"/"+pipeline.String(), err, c.QueryPipelineConfig(view, target, team, pipeline))
+		if err == nil && !found {
+			err = fmt.Errorf("pipeline %s/%s not found", team, pipeline.String())
+		}
+
+		if err == nil {
+			msg.Config, err = yaml.Marshal(config)
+			msg.Version = version
+		}
+
+		c.logger.WithFields(log.Fields{
+			"target":   name,
+			"team":     team,
+			"pipeline": pipeline.String(),
+			"version":  version,
+			"error":    err,
+		}).Debug("queried pipeline config")
+
+		msg.Error = err
+		return msg
+	}
+}
+
+// ApplyPipelineConfig updates the provided pipeline with config. version must
+// be the config version that the changes were based on. If the config has
+// changed on the server since, ErrConfigChanged is returned, and nothing is
+// applied. If target is empty, the active target is used.
+func (c *apiManager) ApplyPipelineConfig(target, team string, pipeline atc.PipelineRef, version string, config []byte) tea.Cmd {
+	return func() tea.Msg {
+		defer c.Loading("applying pipeline config")()
+
+		msg := PipelineConfigAppliedMsg{Target: target, Team: team, Pipeline: pipeline}
+
+		client, name, err := c.clientFor(target)
+		if err != nil {
+			msg.Error = err
+			return msg
+		}
+
+		t := client.Team(team)
+
+		_, current, found, err := t.PipelineConfig(pipeline)
+		switch {
+		case err != nil:
+			msg.Error = err
+		case !found:
+			msg.Error = fmt.Errorf("pipeline %s/%s no longer exists", team, pipeline.String())
+		case current != version:
+			msg.Error = ErrConfigChanged
+		}
+
+		if msg.Error == nil {
+			// The ATC also validates the config version, which covers the (small)
+			// window between the check above, and the update.
+			_, _, warnings, err := t.CreateOrUpdatePipelineConfig(pipeline, version, config, false)
+			for _, w := range warnings {
+				msg.Warnings = append(msg.Warnings, w.Message)
+			}
+
+			msg.Error = err
+		}
+
+		c.logger.WithFields(log.Fields{
+			"target":   name,
+			"team":     team,
+			"pipeline": pipeline.String(),
+			"version":  version,
+			"warnings": len(msg.Warnings),
+			"error":    msg.Error,
+		}).Debug("applied pipeline config")
+
+		return msg
+	}
+}
@@ -0,0 +1,331 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/apex/log"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/concourse/concourse/atc"
+	"github.com/concourse/concourse/fly/rc"
+	"github.com/concourse/concourse/go-concourse/concourse"
+)
+
+// ExecuteOptions are the options for running a one-off build, the equivalent
+// of `fly execute`.
+type ExecuteOptions struct {
+	// ConfigPath is the path to the local task config file.
+	ConfigPath string
+
+	// Inputs maps task input names to local directories, which are uploaded.
+	Inputs map[string]string
+
+	// InputsFrom is an optional "<pipeline>/<job>" reference, which is used
+	// for any task inputs not provided through Inputs.
+	InputsFrom string
+
+	// Outputs maps task output names to local directories, which the outputs
+	// are downloaded into once the build has finished.
+	Outputs map[string]string
+
+	Privileged     bool
+	IncludeIgnored bool
+	Tags           []string
+}
+
+// Execute uploads the provided inputs, and starts a one-off build on the active
+// target. Build events are streamed through api.BuildEventMsg, and
+// api.BuildFinishedMsg is sent once the build (and downloading of any outputs)
+// has finished.
+func (c *apiManager) Execute(opts ExecuteOptions) tea.Cmd {
+	return func() tea.Msg {
+		target := c.Active()
+
+		build, err := c.createExecuteBuild(target, opts)
+
+		c.logger.WithFields(log.Fields{
+			"build":  build.ID,
+			"config": opts.ConfigPath,
+			"error":  err,
+		}).Debug("created one-off build")
+
+		if err != nil {
+			return c.buildStarted(BuildStartedMsg{Error: err})
+		}
+
+		msg := c.buildStarted(BuildStartedMsg{Build: build})
+
+		c.wg.Add(1)
+		go c.streamBuild(target, build, func(status atc.BuildStatus) error {
+			if len(opts.Outputs) == 0 {
+				return nil
+			}
+
+			defer c.Loading("downloading outputs")()
+			return downloadOutputs(target, build, opts.Outputs)
+		})
+
+		return msg
+	}
+}
+
+func (c *apiManager) createExecuteBuild(target rc.Target, opts ExecuteOptions) (build atc.Build, err error) {
+	defer c.Loading("preparing one-off build")()
+
+	b, err := os.ReadFile(opts.ConfigPath)
+	if err != nil {
+		return build, err
+	}
+
+	config, err := atc.NewTaskConfig(b)
+	if err != nil {
+		return build, fmt.Errorf("invalid task config %q: %w", opts.ConfigPath, err)
+	}
+
+	team := target.Team()
+	fact := atc.NewPlanFactory(time.Now().Unix())
+
+	for name := range opts.Inputs {
+		if !hasTaskInput(config.Inputs, name) {
+			return build, fmt.Errorf("unknown input %q", name)
+		}
+	}
+
+	local, err := c.uploadInputs(team, opts, config.Platform)
+	if err != nil {
+		return build, err
+	}
+
+	var fromJob map[string]atc.Plan
+	var resourceTypes atc.ResourceTypes
+	var pipeline atc.PipelineRef
+
+	if opts.InputsFrom != "" {
+		var job string
+
+		pipeline, job, err = ParseRef(opts.InputsFrom)
+		if err != nil {
+			return build, err
+		}
+
+		fromJob, resourceTypes, err = jobInputPlans(fact, team, pipeline, job)
+		if err != nil {
+			return build, err
+		}
+	}
+
+	inputs := atc.InParallelPlan{}
+
+	for _, input := range config.Inputs {
+		if id, ok := local[input.Name]; ok {
+			inputs.Steps = append(inputs.Steps, fact.NewPlan(atc.ArtifactInputPlan{
+				ArtifactID: id,
+				Name:       input.Name,
+			}))
+			continue
+		}
+
+		if plan, ok := fromJob[input.Name]; ok {
+			inputs.Steps = append(inputs.Steps, plan)
+			continue
+		}
+
+		if !input.Optional {
+			return build, fmt.Errorf("missing required input %q", input.Name)
+		}
+	}
+
+	outputs := atc.InParallelPlan{}
+
+	for name := range opts.Outputs {
+		if !hasTaskOutput(config.Outputs, name) {
+			return build, fmt.Errorf("unknown output %q", name)
+		}
+
+		outputs.Steps = append(outputs.Steps, fact.NewPlan(atc.ArtifactOutputPlan{Name: name}))
+	}
+
+	if err = config.Validate(); err != nil {
+		return build, err
+	}
+
+	task := fact.NewPlan(atc.TaskPlan{
+		Name:          "one-off",
+		Privileged:    opts.Privileged,
+		Config:        &config,
+		ResourceTypes: resourceTypes,
+		Tags:          opts.Tags,
+	})
+
+	plan := fact.NewPlan(atc.DoPlan{fact.NewPlan(inputs), task})
+
+	if len(outputs.Steps) > 0 {
+		plan = fact.NewPlan(atc.EnsurePlan{
+			Step: plan,
+			Next: fact.NewPlan(outputs),
+		})
+	}
+
+	if pipeline.Name != "" {
+		return team.CreatePipelineBuild(pipeline, plan)
+	}
+
+	return team.CreateBuild(plan)
+}
+
+// uploadInputs uploads all local inputs as artifacts, returning a map of input
+// name to artifact ID.
+func (c *apiManager) uploadInputs(team concourse.Team, opts ExecuteOptions, platform string) (map[string]int, error) {
+	artifacts := make(map[string]int, len(opts.Inputs))
+
+	names := make([]string, 0, len(opts.Inputs))
+	for name := range opts.Inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := opts.Inputs[name]
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !fi.IsDir() {
+			return nil, fmt.Errorf("input %q: %s is not a directory", name, path)
+		}
+
+		done := c.Loading("uploading " + name)
+
+		r, w := io.Pipe()
+		go func() {
+			_ = w.CloseWithError(compressDir(w, path, archiveFiles(path, opts.IncludeIgnored)))
+		}()
+
+		artifact, err := team.CreateArtifact(r, platform, opts.Tags)
+		_ = r.Close()
+		done()
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload input %q: %w", name, err)
+		}
+
+		artifacts[name] = artifact.ID
+	}
+
+	return artifacts, nil
+}
+
+// jobInputPlans returns get plans for the latest inputs of the provided job,
+// keyed by input name.
+func jobInputPlans(
+	fact atc.PlanFactory,
+	team concourse.Team,
+	pipeline atc.PipelineRef,
+	job string,
+) (map[string]atc.Plan, atc.ResourceTypes, error) {
+	inputs, found, err := team.BuildInputsForJob(pipeline, job)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !found {
+		return nil, nil, fmt.Errorf("build inputs for %s/%s not found", pipeline.String(), job)
+	}
+
+	resourceTypes, found, err := team.ResourceTypes(pipeline)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !found {
+		return nil, nil, fmt.Errorf("resource types of %s not found", pipeline.String())
+	}
+
+	plans := make(map[string]atc.Plan, len(inputs))
+
+	for _, input := range inputs {
+		version := input.Version
+
+		plan := fact.NewPlan(atc.GetPlan{
+			Name:    input.Name,
+			Type:    input.Type,
+			Source:  input.Source,
+			Version: &version,
+			Params:  input.Params,
+			Tags:    input.Tags,
+		})
+		plan.Get.TypeImage = resourceTypes.ImageForType(plan.ID, input.Type, input.Tags, false)
+
+		plans[input.Name] = plan
+	}
+
+	return plans, resourceTypes, nil
+}
+
+// downloadOutputs downloads the artifacts of the provided build into the local
+// directories mapped by outputs.
+func downloadOutputs(target rc.Target, build atc.Build, outputs map[string]string) error {
+	artifacts, err := target.Client().ListBuildArtifacts(fmt.Sprint(build.ID))
+	if err != nil {
+		return err
+	}
+
+	for name, path := range outputs {
+		var found bool
+
+		for _, artifact := range artifacts {
+			if artifact.Name != name {
+				continue
+			}
+
+			found = true
+
+			if err = downloadArtifact(target.Team(), artifact.ID, path); err != nil {
+				return fmt.Errorf("failed to download output %q: %w", name, err)
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("output %q not found in build %d", name, build.ID)
+		}
+	}
+
+	return nil
+}
+
+func downloadArtifact(team concourse.Team, id int, path string) error {
+	r, err := team.GetArtifact(id)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	return extractArchive(r, path)
+}
+
+func hasTaskInput(inputs []atc.TaskInputConfig, name string) bool {
+	for _, input := range inputs {
+		if input.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTaskOutput(outputs []atc.TaskOutputConfig, name string) bool {
+	for _, output := range outputs {
+		if output.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,209 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/concourse/concourse/go-concourse/concourse"
+)
+
+// flyVersionTimeout is how long `fly --version` has to respond.
+const flyVersionTimeout = 5 * time.Second
+
+// FlySyncedMsg is returned once the fly binary has been synced with the
+// active target. Before is empty if fly wasn't installed yet.
+type FlySyncedMsg struct {
+	Target string
+	Path   string
+	Before string
+	After  string
+
+	// UpToDate is true if fly already matched the target's version, and
+	// nothing was downloaded.
+	UpToDate bool
+	Error    error
+}
+
+// ResolveFlyPath returns the path fly should be installed to. If path is
+// empty, the fly binary found in $PATH is used. Symlinks are resolved, so the
+// binary they point to is replaced, rather than the link itself.
+func ResolveFlyPath(path string) (string, error) {
+	if path == "" {
+		name := "fly"
+		if runtime.GOOS == "windows" {
+			name += ".exe"
+		}
+
+		var err error
+
+		path, err = exec.LookPath(name)
+		if err != nil {
+			return "", fmt.Errorf("fly not found in $PATH, use --fly-path (or -p) to set where to install it: %w", err)
+		}
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := filepath.EvalSymlinks(path)
+	switch {
+	case err == nil:
+		return resolved, nil
+	case errors.Is(err, fs.ErrNotExist):
+		return path, nil
+	default:
+		return "", err
+	}
+}
+
+// flyVersion returns the version reported by the fly binary at path, or an
+// empty string if it doesn't exist.
+func (c *apiManager) flyVersion(path string) (string, error) {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+
+	ctx, cancel := context.WithTimeout(c.ctx, flyVersionTimeout)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s --version: %w", path, err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// SyncFly downloads the fly binary matching the version of the active target
+// for the current platform, the equivalent of `fly sync`. The download is
+// verified before it replaces the binary at path (see ResolveFlyPath). Unless
+// force is true, nothing is downloaded if fly already matches the target's
+// version. Returns api.FlySyncedMsg.
+func (c *apiManager) SyncFly(path string, force bool) tea.Cmd {
+	target := c.ActiveName()
+	client := c.Client()
+
+	return func() tea.Msg {
+		defer c.Loading("syncing fly")()
+
+		msg := FlySyncedMsg{Target: target}
+
+		// Never install binaries from a cassette.
+		if c.player != nil {
+			msg.Error = ErrReplaying
+			return msg
+		}
+
+		var err error
+
+		msg.Path, err = ResolveFlyPath(path)
+		if err != nil {
+			msg.Error = err
+			return msg
+		}
+
+		// A broken fly (e.g. built for another platform) shouldn't prevent it
+		// from being replaced.
+		msg.Before, err = c.flyVersion(msg.Path)
+		if err != nil {
+			c.logger.WithError(err).WithField("path", msg.Path).Warn("failed to determine current fly version")
+		}
+
+		info, err := client.GetInfo()
+		if err != nil {
+			msg.Error = fmt.Errorf("failed to fetch target version: %w", err)
+			return msg
+		}
+
+		if !force && msg.Before == info.Version {
+			msg.After = msg.Before
+			msg.UpToDate = true
+			return msg
+		}
+
+		msg.After, msg.Error = c.downloadFly(client, msg.Path, info.Version)
+
+		c.logger.WithFields(log.Fields{
+			"target": target,
+			"path":   msg.Path,
+			"before": msg.Before,
+			"after":  msg.After,
+			"error":  msg.Error,
+		}).Info("synced fly")
+
+		return msg
+	}
+}
+
+// downloadFly downloads fly into a temporary file next to path, verifies that
+// it's complete and reports the expected version, and moves it into place.
+// Returns the version of the installed binary.
+func (c *apiManager) downloadFly(client concourse.Client, path, expected string) (string, error) {
+	body, headers, err := client.GetCLIReader(runtime.GOARCH, runtime.GOOS)
+	if err != nil {
+		return "", fmt.Errorf("failed to download fly for %s/%s: %w", runtime.GOOS, runtime.GOARCH, err)
+	}
+	defer body.Close()
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+
+	// Download next to the destination, so it can be renamed into place
+	// atomically, and a failed download never leaves a broken fly behind.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".fly-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("failed to download fly: %w", err)
+	}
+
+	if size, serr := strconv.ParseInt(headers.Get("Content-Length"), 10, 64); serr == nil && size != written {
+		return "", fmt.Errorf("incomplete download of fly, got %d of %d bytes", written, size)
+	}
+
+	if err = os.Chmod(tmp.Name(), 0o755); err != nil {
+		return "", err
+	}
+
+	version, err := c.flyVersion(tmp.Name())
+	if err != nil {
+		return "", fmt.Errorf("downloaded fly failed verification: %w", err)
+	}
+
+	if version != expected {
+		return "", fmt.Errorf("downloaded fly reports version %q, expected %q", version, expected)
+	}
+
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to install fly: %w", err)
+	}
+
+	return version, nil
+}
@@ -0,0 +1,373 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apex/log"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/concourse/concourse/fly/rc"
+	"sigs.k8s.io/yaml"
+)
+
+// caCertRemove is used as the CA cert path when editing a target, to remove
+// its CA cert.
+const caCertRemove = "-"
+
+// TargetConfig is the config of a target, as entered by the user.
+type TargetConfig struct {
+	Name string
+	API  string
+	Team string
+
+	// CACert is the path to a PEM encoded CA cert (optional). When editing a
+	// target, the current CA cert is kept if empty, and removed if "-".
+	CACert   string
+	Insecure bool
+}
+
+// TargetSavedMsg is sent once a target has been added to, or edited in, the
+// flyrc. Target is the (new) name of the target.
+type TargetSavedMsg struct {
+	Target string
+	Error  error
+}
+
+// TargetDeletedMsg is sent once a target has been deleted from the flyrc.
+type TargetDeletedMsg struct {
+	Target string
+	Error  error
+}
+
+// validate validates the config, and normalizes the API URL and team.
+func (cfg *TargetConfig) validate() error {
+	cfg.Name = strings.TrimSpace(cfg.Name)
+	cfg.API = strings.TrimRight(strings.TrimSpace(cfg.API), "/")
+	cfg.Team = strings.TrimSpace(cfg.Team)
+
+	if cfg.Name == "" {
+		return errors.New("target name is required")
+	}
+
+	// Target names are used in paths (e.g. snapshots), so only allow a safe
+	// subset of characters.
+	if cfg.Name == "." || cfg.Name == ".." || strings.TrimFunc(cfg.Name, isTargetNameRune) != "" {
+		return errors.New("target name can only contain letters, digits, '.', '_' and '-'")
+	}
+
+	u, err := url.Parse(cfg.API)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid API URL %q, should be in the format https://ci.example.com", cfg.API)
+	}
+
+	if cfg.Team == "" {
+		cfg.Team = "main"
+	}
+
+	return nil
+}
+
+func isTargetNameRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') ||
+		r == '.' || r == '_' || r == '-'
+}
+
+// readCACert reads and validates the CA cert at the configured path (if any),
+// returning its contents, as they are stored in the flyrc.
+func (cfg *TargetConfig) readCACert() (string, error) {
+	path := strings.TrimSpace(cfg.CACert)
+	if path == "" {
+		return "", nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read CA cert: %w", err)
+	}
+
+	if _, err = loadCACertPool(string(b)); err != nil {
+		return "", fmt.Errorf("%s: %w", path, err)
+	}
+
+	return string(b), nil
+}
+
+// editFlyrc loads the raw targets from the flyrc, calls fn to modify them, and
+// writes them back. Unlike rc.SaveTarget, fields unknown to rc.TargetProps (and
+// any other top-level keys) are preserved. The flyrc is never modified while
+// replaying.
+func (c *apiManager) editFlyrc(fn func(targets map[string]map[string]any) error) error {
+	if c.player != nil {
+		return ErrReplaying
+	}
+
+	// Edits read the flyrc and write it back, so concurrent edits (e.g. a
+	// login finishing while a target is added) would drop each other's changes.
+	c.flyrcMu.Lock()
+	defer c.flyrcMu.Unlock()
+
+	path := FlyrcPath()
+	raw := map[string]any{}
+	mode := fs.FileMode(0o600)
+
+	// Write to the file a symlinked flyrc points to, rather than replacing the
+	// symlink.
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+
+	b, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if fi, serr := os.Stat(path); serr == nil {
+			mode = fi.Mode().Perm()
+		}
+
+		if err = yaml.Unmarshal(b, &raw); err != nil {
+			return fmt.Errorf("in the file '%s': %w", path, err)
+		}
+
+		if raw == nil {
+			raw = map[string]any{}
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return err
+	}
+
+	targets := map[string]map[string]any{}
+
+	if t, ok := raw["targets"].(map[string]any); ok {
+		for name, props := range t {
+			targets[name], _ = props.(map[string]any)
+
+			if targets[name] == nil {
+				targets[name] = map[string]any{}
+			}
+		}
+	}
+
+	if err = fn(targets); err != nil {
+		return err
+	}
+
+	raw["targets"] = targets
+
+	b, err = yaml.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so fly (or the watcher) never reads a
+	// partially written flyrc. It's created next to the flyrc, so it can be
+	// renamed over it, with a unique name, so other tools writing the flyrc
+	// the same way don't clobber it.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Only needed if it wasn't renamed.
+
+	if _, err = tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// apply applies the config to the raw props of a target. caCert is the
+// contents of the CA cert, and is only applied if not nil.
+func (cfg *TargetConfig) apply(props map[string]any, caCert *string) {
+	props["api"] = cfg.API
+	props["team"] = cfg.Team
+
+	if cfg.Insecure {
+		props["insecure"] = true
+	} else {
+		delete(props, "insecure")
+	}
+
+	switch {
+	case caCert == nil:
+	case *caCert == "":
+		delete(props, "ca_cert")
+	default:
+		props["ca_cert"] = *caCert
+	}
+}
+
+// AddTarget adds a new target to the flyrc, without credentials (the user
+// should login to it afterwards). Returns api.TargetSavedMsg.
+func (c *apiManager) AddTarget(cfg TargetConfig) tea.Cmd {
+	return func() tea.Msg {
+		if err := cfg.validate(); err != nil {
+			return TargetSavedMsg{Target: cfg.Name, Error: err}
+		}
+
+		caCert, err := cfg.readCACert()
+		if err != nil {
+			return TargetSavedMsg{Target: cfg.Name, Error: err}
+		}
+
+		err = c.editFlyrc(func(targets map[string]map[string]any) error {
+			if _, ok := targets[cfg.Name]; ok {
+				return fmt.Errorf("target %q already exists", cfg.Name)
+			}
+
+			targets[cfg.Name] = map[string]any{}
+			cfg.apply(targets[cfg.Name], &caCert)
+
+			return nil
+		})
+		if err != nil {
+			return TargetSavedMsg{Target: cfg.Name, Error: fmt.Errorf("failed to add target: %w", err)}
+		}
+
+		c.logger.WithField("target", cfg.Name).Info("added target")
+
+		// Don't wait for the watcher to pick up the change, as the target is
+		// used right away.
+		c.UpdateTargets()
+
+		return TargetSavedMsg{Target: cfg.Name}
+	}
+}
+
+// EditTarget updates the API URL, team, CA cert and insecure flag of an
+// existing target, and renames it if cfg.Name differs from name, the same as
+// `fly edit-target`. The token is kept. Returns api.TargetSavedMsg.
+func (c *apiManager) EditTarget(name string, cfg TargetConfig) tea.Cmd {
+	return func() tea.Msg {
+		if err := cfg.validate(); err != nil {
+			return TargetSavedMsg{Target: name, Error: err}
+		}
+
+		var caCert *string
+
+		switch strings.TrimSpace(cfg.CACert) {
+		case "":
+		case caCertRemove:
+			caCert = new(string)
+		default:
+			cert, err := cfg.readCACert()
+			if err != nil {
+				return TargetSavedMsg{Target: name, Error: err}
+			}
+
+			caCert = &cert
+		}
+
+		err := c.editFlyrc(func(targets map[string]map[string]any) error {
+			props, ok := targets[name]
+			if !ok {
+				return rc.UnknownTargetError{TargetName: rc.TargetName(name)}
+			}
+
+			if cfg.Name != name {
+				if _, ok = targets[cfg.Name]; ok {
+					return fmt.Errorf("target %q already exists", cfg.Name)
+				}
+
+				delete(targets, name)
+				targets[cfg.Name] = props
+			}
+
+			cfg.apply(props, caCert)
+			return nil
+		})
+		if err != nil {
+			return TargetSavedMsg{Target: name, Error: fmt.Errorf("failed to edit target: %w", err)}
+		}
+
+		c.logger.WithFields(log.Fields{
+			"target":   name,
+			"new_name": cfg.Name,
+		}).Info("edited target")
+
+		if cfg.Name != name {
+			c.forgetTarget(name)
+
+			// Switch to the new name before the targets are reloaded, so the
+			// rename isn't treated as the active target being removed.
+			if name == c.ActiveName() {
+				if err = c.SetActive(cfg.Name); err != nil {
+					c.logger.WithError(err).WithField("target", cfg.Name).Error("failed to reload renamed target")
+				}
+			}
+		}
+
+		c.UpdateTargets()
+
+		return TargetSavedMsg{Target: cfg.Name}
+	}
+}
+
+// DeleteTarget removes a target from the flyrc, the same as
+// `fly delete-target`. The active target can't be deleted. Returns
+// api.TargetDeletedMsg.
+func (c *apiManager) DeleteTarget(name string) tea.Cmd {
+	return func() tea.Msg {
+		if name == c.ActiveName() {
+			return TargetDeletedMsg{Target: name, Error: errors.New("can't delete the active target, switch to another target first")}
+		}
+
+		err := c.editFlyrc(func(targets map[string]map[string]any) error {
+			if _, ok := targets[name]; !ok {
+				return rc.UnknownTargetError{TargetName: rc.TargetName(name)}
+			}
+
+			delete(targets, name)
+			return nil
+		})
+		if err != nil {
+			return TargetDeletedMsg{Target: name, Error: fmt.Errorf("failed to delete target: %w", err)}
+		}
+
+		c.logger.WithField("target", name).Info("deleted target")
+
+		c.forgetTarget(name)
+		c.UpdateTargets()
+
+		return TargetDeletedMsg{Target: name}
+	}
+}
+
+// forgetTarget removes all state kept for a target which was renamed or
+// deleted.
+func (c *apiManager) forgetTarget(name string) {
+	c.unloadTargets(name)
+	c.cache.invalidate(name)
+
+	c.authMu.Lock()
+	delete(c.needsLogin, name)
+	delete(c.retries, name)
+	c.authMu.Unlock()
+
+	c.forgetStale(name)
+
+	c.compatMu.Lock()
+	delete(c.compat, name)
+	c.compatMu.Unlock()
+
+	if err := c.snapshots.remove(name); err != nil {
+		c.logger.WithError(err).WithField("target", name).Warn("failed to remove snapshots")
+	}
+}
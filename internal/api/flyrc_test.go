@@ -0,0 +1,307 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/concourse/concourse/fly/rc"
+	"github.com/lrstanley/hangar-ui/internal/fakeatc"
+	"sigs.k8s.io/yaml"
+)
+
+func TestEditFlyrcConcurrent(t *testing.T) {
+	c, _ := newTestClient(t, fakeatc.NewState())
+
+	const edits = 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, edits)
+
+	for i := 0; i < edits; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+
+			errs <- c.editFlyrc(func(targets map[string]map[string]any) error {
+				targets[name] = map[string]any{"api": "http://" + name, "team": "main"}
+				return nil
+			})
+		}("target-" + strconv.Itoa(i))
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	targets, err := rc.LoadTargets()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// All edits, and the existing target.
+	if len(targets) != edits+1 {
+		t.Errorf("expected %d targets, got %d", edits+1, len(targets))
+	}
+
+	// No temporary files are left behind.
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(FlyrcPath()), "*.tmp"))
+	if len(matches) > 0 {
+		t.Errorf("expected no temporary files, got %v", matches)
+	}
+
+	fi, err := os.Stat(FlyrcPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode().Perm() != 0o600 {
+		t.Errorf("expected the flyrc to only be readable by the user, got %v", fi.Mode())
+	}
+}
+
+func TestTargetConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     TargetConfig
+		wantErr bool
+	}{
+		{name: "valid", cfg: TargetConfig{Name: "ci-1.example_a", API: "https://ci.example.com"}},
+		{name: "trimmed", cfg: TargetConfig{Name: " ci ", API: " https://ci.example.com/ "}},
+		{name: "empty", cfg: TargetConfig{Name: "", API: "https://ci.example.com"}, wantErr: true},
+		{name: "dot", cfg: TargetConfig{Name: ".", API: "https://ci.example.com"}, wantErr: true},
+		{name: "dotdot", cfg: TargetConfig{Name: "..", API: "https://ci.example.com"}, wantErr: true},
+		{name: "slash", cfg: TargetConfig{Name: "a/b", API: "https://ci.example.com"}, wantErr: true},
+		{name: "backslash", cfg: TargetConfig{Name: `a\b`, API: "https://ci.example.com"}, wantErr: true},
+		{name: "space", cfg: TargetConfig{Name: "a b", API: "https://ci.example.com"}, wantErr: true},
+		{name: "unicode", cfg: TargetConfig{Name: "ciö", API: "https://ci.example.com"}, wantErr: true},
+		{name: "no scheme", cfg: TargetConfig{Name: "ci", API: "ci.example.com"}, wantErr: true},
+		{name: "bad scheme", cfg: TargetConfig{Name: "ci", API: "ftp://ci.example.com"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			err := cfg.validate()
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %v, got %v", tt.wantErr, err)
+			}
+
+			if err == nil {
+				if cfg.Team != "main" {
+					t.Errorf("expected the team to default to main, got %q", cfg.Team)
+				}
+
+				if cfg.API != "https://ci.example.com" {
+					t.Errorf("expected a normalized API URL, got %q", cfg.API)
+				}
+			}
+		})
+	}
+}
+
+// readFlyrc returns the raw contents of the flyrc.
+func readFlyrc(t *testing.T) map[string]any {
+	t.Helper()
+
+	b, err := os.ReadFile(FlyrcPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := map[string]any{}
+	if err = yaml.Unmarshal(b, &raw); err != nil {
+		t.Fatal(err)
+	}
+
+	return raw
+}
+
+// writeFlyrc adds an "other" target with a CA cert and an unknown field, and
+// an unknown top-level key, to the flyrc.
+func writeFlyrc(t *testing.T) {
+	t.Helper()
+
+	raw := readFlyrc(t)
+	raw["unknown"] = "top-level"
+	raw["targets"].(map[string]any)["other"] = map[string]any{
+		"api":     "http://other.example.com",
+		"team":    "main",
+		"ca_cert": "cert",
+		"custom":  "field",
+	}
+
+	b, err := yaml.Marshal(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, FlyrcPath(), string(b))
+}
+
+func assertPreserved(t *testing.T, raw map[string]any, target string) map[string]any {
+	t.Helper()
+
+	if raw["unknown"] != "top-level" {
+		t.Errorf("expected unknown top-level keys to be preserved, got %v", raw["unknown"])
+	}
+
+	targets := raw["targets"].(map[string]any)
+	if _, ok := targets[testTarget]; !ok {
+		t.Errorf("expected %q to be kept", testTarget)
+	}
+
+	if target == "" {
+		return nil
+	}
+
+	props, ok := targets[target].(map[string]any)
+	if !ok {
+		t.Fatalf("expected target %q, got %v", target, targets)
+	}
+
+	if props["custom"] != "field" {
+		t.Errorf("expected unknown target keys to be preserved, got %v", props)
+	}
+
+	return props
+}
+
+func TestAddTarget(t *testing.T) {
+	c, _ := newTestClient(t, fakeatc.NewState())
+	writeFlyrc(t)
+
+	msg := c.AddTarget(TargetConfig{Name: "new", API: "https://new.example.com/", Insecure: true})().(TargetSavedMsg)
+	if msg.Error != nil {
+		t.Fatal(msg.Error)
+	}
+
+	raw := readFlyrc(t)
+	assertPreserved(t, raw, "other")
+
+	props := raw["targets"].(map[string]any)["new"].(map[string]any)
+	if props["api"] != "https://new.example.com" || props["team"] != "main" || props["insecure"] != true {
+		t.Errorf("unexpected props: %v", props)
+	}
+
+	msg = c.AddTarget(TargetConfig{Name: "other", API: "https://new.example.com"})().(TargetSavedMsg)
+	if msg.Error == nil {
+		t.Error("expected an error adding an existing target")
+	}
+}
+
+func TestEditTarget(t *testing.T) {
+	c, _ := newTestClient(t, fakeatc.NewState())
+	writeFlyrc(t)
+
+	msg := c.EditTarget("other", TargetConfig{Name: "renamed", API: "https://renamed.example.com", Team: "dev"})().(TargetSavedMsg)
+	if msg.Error != nil {
+		t.Fatal(msg.Error)
+	}
+
+	raw := readFlyrc(t)
+	props := assertPreserved(t, raw, "renamed")
+
+	if _, ok := raw["targets"].(map[string]any)["other"]; ok {
+		t.Error("expected the old name to be removed")
+	}
+
+	if props["api"] != "https://renamed.example.com" || props["team"] != "dev" {
+		t.Errorf("unexpected props: %v", props)
+	}
+
+	if props["ca_cert"] != "cert" {
+		t.Errorf("expected the CA cert to be kept, got %v", props["ca_cert"])
+	}
+
+	msg = c.EditTarget("renamed", TargetConfig{Name: "renamed", API: "https://renamed.example.com", CACert: caCertRemove})().(TargetSavedMsg)
+	if msg.Error != nil {
+		t.Fatal(msg.Error)
+	}
+
+	props = assertPreserved(t, readFlyrc(t), "renamed")
+	if _, ok := props["ca_cert"]; ok {
+		t.Errorf("expected the CA cert to be removed, got %v", props["ca_cert"])
+	}
+}
+
+func TestDeleteTarget(t *testing.T) {
+	c, _ := newTestClient(t, fakeatc.NewState())
+	writeFlyrc(t)
+
+	msg := c.DeleteTarget("other")().(TargetDeletedMsg)
+	if msg.Error != nil {
+		t.Fatal(msg.Error)
+	}
+
+	raw := readFlyrc(t)
+	assertPreserved(t, raw, "")
+
+	if _, ok := raw["targets"].(map[string]any)["other"]; ok {
+		t.Error("expected the target to be removed")
+	}
+
+	msg = c.DeleteTarget(testTarget)().(TargetDeletedMsg)
+	if msg.Error == nil {
+		t.Error("expected an error deleting the active target")
+	}
+}
+
+func TestEditFlyrcSymlink(t *testing.T) {
+	c, _ := newTestClient(t, fakeatc.NewState())
+
+	real := filepath.Join(t.TempDir(), "flyrc")
+	if err := os.Rename(FlyrcPath(), real); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chmod(real, 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(real, FlyrcPath()); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	err := c.editFlyrc(func(targets map[string]map[string]any) error {
+		targets["new"] = map[string]any{"api": "http://new", "team": "main"}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Lstat(FlyrcPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode()&fs.ModeSymlink == 0 {
+		t.Error("expected the flyrc to still be a symlink")
+	}
+
+	if fi, err = os.Stat(real); err != nil {
+		t.Fatal(err)
+	}
+
+	if fi.Mode().Perm() != 0o640 {
+		t.Errorf("expected the mode to be kept, got %v", fi.Mode())
+	}
+
+	if _, ok := readFlyrc(t)["targets"].(map[string]any)["new"]; !ok {
+		t.Error("expected the target to be written through the symlink")
+	}
+}
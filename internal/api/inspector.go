@@ -0,0 +1,249 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// maxRecordedRequests is the number of requests kept by the inspector,
+	// older requests are dropped.
+	maxRecordedRequests = 500
+
+	// maxRecordedBody is the max size of the request and response bodies kept
+	// per request.
+	maxRecordedBody = 64 * 1024
+
+	// tokenPath is the path of the token endpoint, used when logging in with a
+	// password, whose bodies contain credentials.
+	tokenPath = "/sky/issuer/token"
+
+	redacted = "[redacted]"
+)
+
+// RequestRecord is a single HTTP request made to an ATC, as recorded by the
+// request inspector. Credentials are redacted.
+type RequestRecord struct {
+	ID     int
+	Target string
+	Time   time.Time
+	Method string
+	URL    string
+	Path   string
+
+	RequestHeader http.Header
+	RequestBody   []byte
+	RequestSize   int64
+
+	Status         int
+	ResponseHeader http.Header
+	ResponseBody   []byte
+	ResponseSize   int64
+
+	// Latency is the time until the response headers were received.
+	Latency time.Duration
+
+	// Done is false while the response body is still being read (e.g. build
+	// events, which are streamed).
+	Done  bool
+	Error error
+}
+
+// RequestLogMsg contains all recorded requests, oldest first.
+type RequestLogMsg []RequestRecord
+
+// requestLog keeps the most recent requests made to all targets.
+type requestLog struct {
+	mu      sync.Mutex
+	nextID  int
+	records []*RequestRecord
+}
+
+func (l *requestLog) add(rec *RequestRecord) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.nextID++
+	rec.ID = l.nextID
+
+	l.records = append(l.records, rec)
+	if len(l.records) > maxRecordedRequests {
+		l.records = l.records[len(l.records)-maxRecordedRequests:]
+	}
+}
+
+// update calls fn with the log locked, to update a record.
+func (l *requestLog) update(fn func()) {
+	l.mu.Lock()
+	fn()
+	l.mu.Unlock()
+}
+
+// snapshot returns a copy of all records.
+func (l *requestLog) snapshot() []RequestRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make([]RequestRecord, len(l.records))
+	for i, rec := range l.records {
+		out[i] = *rec
+	}
+
+	return out
+}
+
+func (l *requestLog) clear() {
+	l.mu.Lock()
+	l.records = nil
+	l.mu.Unlock()
+}
+
+// inspectTransport records all requests made to a target. It sits right above
+// the network, so it records what is actually sent to the ATC (e.g. responses
+// served from the cache aren't recorded, but their revalidation is).
+type inspectTransport struct {
+	target string
+	base   http.RoundTripper
+	log    *requestLog
+}
+
+func (t *inspectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	sensitive := r.URL.Path == tokenPath
+
+	rec := &RequestRecord{
+		Target:        t.target,
+		Time:          time.Now(),
+		Method:        r.Method,
+		URL:           r.URL.String(),
+		Path:          r.URL.Path,
+		RequestHeader: redactHeader(r.Header),
+	}
+
+	if sensitive {
+		rec.RequestBody = []byte(redacted)
+	}
+
+	if r.Body != nil && r.Body != http.NoBody {
+		// The request must not be modified, so record the body of a copy.
+		r = r.Clone(r.Context())
+		r.Body = &recordingBody{ReadCloser: r.Body, log: t.log, rec: rec, sensitive: sensitive}
+	}
+
+	t.log.add(rec)
+
+	resp, err := t.base.RoundTrip(r)
+
+	t.log.update(func() {
+		rec.Latency = time.Since(rec.Time)
+		rec.Error = err
+
+		if err != nil {
+			rec.Done = true
+			return
+		}
+
+		rec.Status = resp.StatusCode
+		rec.ResponseHeader = redactHeader(resp.Header)
+
+		if sensitive {
+			rec.ResponseBody = []byte(redacted)
+		}
+	})
+
+	if err != nil {
+		return resp, err
+	}
+
+	resp.Body = &recordingBody{ReadCloser: resp.Body, log: t.log, rec: rec, response: true, sensitive: sensitive}
+	return resp, nil
+}
+
+// recordingBody records the body of a request or response as it's read, up to
+// maxRecordedBody.
+type recordingBody struct {
+	io.ReadCloser
+	log       *requestLog
+	rec       *RequestRecord
+	response  bool
+	sensitive bool
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	b.log.update(func() {
+		body, size := &b.rec.RequestBody, &b.rec.RequestSize
+		if b.response {
+			body, size = &b.rec.ResponseBody, &b.rec.ResponseSize
+		}
+
+		*size += int64(n)
+
+		if room := maxRecordedBody - len(*body); !b.sensitive && room > 0 {
+			if room > n {
+				room = n
+			}
+
+			*body = append(*body, p[:room]...)
+		}
+
+		if b.response && err != nil {
+			b.rec.Done = true
+
+			if err != io.EOF {
+				b.rec.Error = err
+			}
+		}
+	})
+
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	if b.response {
+		b.log.update(func() { b.rec.Done = true })
+	}
+
+	return b.ReadCloser.Close()
+}
+
+// redactHeader returns a copy of the header, with credentials redacted.
+func redactHeader(header http.Header) http.Header {
+	out := header.Clone()
+
+	for name, values := range out {
+		switch http.CanonicalHeaderKey(name) {
+		case "Authorization", "Proxy-Authorization":
+			for i, v := range values {
+				scheme, _, _ := strings.Cut(v, " ")
+				values[i] = scheme + " " + redacted
+			}
+		case "Cookie", "Set-Cookie":
+			for i := range values {
+				values[i] = redacted
+			}
+		}
+	}
+
+	return out
+}
+
+// QueryRequests returns all requests recorded by the request inspector, as
+// api.RequestLogMsg.
+func (c *apiManager) QueryRequests() tea.Msg {
+	return RequestLogMsg(c.requests.snapshot())
+}
+
+// ClearRequests removes all requests recorded by the request inspector.
+func (c *apiManager) ClearRequests() {
+	c.requests.clear()
+}
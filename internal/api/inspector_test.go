@@ -0,0 +1,151 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// inspect makes a request through an inspectTransport, with base responding,
+// reads the full response, and returns the recorded request.
+func inspect(t *testing.T, r *http.Request, base roundTripFunc) RequestRecord {
+	t.Helper()
+
+	l := &requestLog{}
+	transport := &inspectTransport{target: "target", base: base, log: l}
+
+	resp, err := transport.RoundTrip(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = io.Copy(io.Discard, resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	records := l.snapshot()
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+
+	return records[0]
+}
+
+func respond(r *http.Request, header http.Header, body []byte) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    r,
+	}
+}
+
+func TestInspectRedactsHeaders(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodPost, "http://ci.example.com/api/v1/teams/main/pipelines/app/pause", strings.NewReader("{}"))
+	r.Header.Set("Authorization", "Bearer secret-token")
+	r.Header.Set("Cookie", "skymarshal_auth=secret")
+
+	var sent http.Header
+	var sentBody []byte
+
+	rec := inspect(t, r, func(r *http.Request) (*http.Response, error) {
+		sent = r.Header
+		sentBody, _ = io.ReadAll(r.Body)
+
+		return respond(r, http.Header{"Set-Cookie": []string{"skymarshal_auth=secret"}}, []byte("ok")), nil
+	})
+
+	if got := rec.RequestHeader.Get("Authorization"); got != "Bearer "+redacted {
+		t.Errorf("expected the token to be redacted, got %q", got)
+	}
+
+	if got := rec.RequestHeader.Get("Cookie"); got != redacted {
+		t.Errorf("expected the cookie to be redacted, got %q", got)
+	}
+
+	if got := rec.ResponseHeader.Get("Set-Cookie"); got != redacted {
+		t.Errorf("expected the response cookie to be redacted, got %q", got)
+	}
+
+	// Only the record is redacted, not what's sent.
+	if sent.Get("Authorization") != "Bearer secret-token" || r.Header.Get("Authorization") != "Bearer secret-token" {
+		t.Error("expected the request to be sent unmodified")
+	}
+
+	if string(sentBody) != "{}" || string(rec.RequestBody) != "{}" || string(rec.ResponseBody) != "ok" {
+		t.Errorf("expected bodies to be recorded, got %q and %q", rec.RequestBody, rec.ResponseBody)
+	}
+
+	if !rec.Done || rec.Status != http.StatusOK {
+		t.Errorf("expected a completed request, got %+v", rec)
+	}
+}
+
+func TestInspectRedactsTokenBodies(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodPost, "http://ci.example.com"+tokenPath, strings.NewReader("grant_type=password&username=admin&password=secret"))
+
+	var sentBody []byte
+
+	rec := inspect(t, r, func(r *http.Request) (*http.Response, error) {
+		sentBody, _ = io.ReadAll(r.Body)
+		return respond(r, http.Header{}, []byte(`{"access_token":"secret-token"}`)), nil
+	})
+
+	if !strings.Contains(string(sentBody), "password=secret") {
+		t.Errorf("expected the credentials to be sent, got %q", sentBody)
+	}
+
+	if string(rec.RequestBody) != redacted || string(rec.ResponseBody) != redacted {
+		t.Errorf("expected the bodies to be redacted, got %q and %q", rec.RequestBody, rec.ResponseBody)
+	}
+
+	if rec.RequestSize != int64(len(sentBody)) {
+		t.Errorf("expected the request size to be recorded, got %d", rec.RequestSize)
+	}
+}
+
+func TestInspectBodyLimit(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "http://ci.example.com/api/v1/builds/1/events", http.NoBody)
+	body := bytes.Repeat([]byte("x"), maxRecordedBody*2+1)
+
+	rec := inspect(t, r, func(r *http.Request) (*http.Response, error) {
+		return respond(r, http.Header{}, body), nil
+	})
+
+	if len(rec.ResponseBody) != maxRecordedBody {
+		t.Errorf("expected %d bytes to be kept, got %d", maxRecordedBody, len(rec.ResponseBody))
+	}
+
+	if rec.ResponseSize != int64(len(body)) {
+		t.Errorf("expected the full size to be recorded, got %d", rec.ResponseSize)
+	}
+}
+
+func TestRequestLogLimit(t *testing.T) {
+	l := &requestLog{}
+
+	for i := 0; i < maxRecordedRequests+10; i++ {
+		l.add(&RequestRecord{})
+	}
+
+	records := l.snapshot()
+	if len(records) != maxRecordedRequests {
+		t.Fatalf("expected %d records, got %d", maxRecordedRequests, len(records))
+	}
+
+	if records[0].ID != 11 || records[len(records)-1].ID != maxRecordedRequests+10 {
+		t.Errorf("expected the oldest records to be dropped, got IDs %d to %d", records[0].ID, records[len(records)-1].ID)
+	}
+
+	l.clear()
+	if len(l.snapshot()) != 0 {
+		t.Error("expected no records after clearing")
+	}
+}
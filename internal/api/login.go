@@ -0,0 +1,263 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/concourse/concourse/fly/rc"
+	"github.com/lrstanley/hangar-ui/internal/types"
+	"golang.org/x/oauth2"
+)
+
+// browserLoginTimeout is how long to wait for a browser login to complete.
+const browserLoginTimeout = 5 * time.Minute
+
+// LoginURLMsg is sent once a browser login has started, with the URL the user
+// needs to open to complete it (e.g. when the browser couldn't be opened).
+type LoginURLMsg struct {
+	Target string
+	URL    string
+}
+
+// oauth2Config returns the same OAuth2 client config as used by fly, for the
+// provided ATC URL.
+func oauth2Config(url string) oauth2.Config {
+	return oauth2.Config{
+		ClientID:     "fly",
+		ClientSecret: "Zmx5",
+		Endpoint:     oauth2.Endpoint{TokenURL: url + "/sky/issuer/token"},
+		Scopes:       []string{"openid", "profile", "email", "federated:id", "groups"},
+	}
+}
+
+// LoginWithPassword logs into the provided target with a username and password,
+// using the password grant (local users). The token is saved to the flyrc.
+func (c *apiManager) LoginWithPassword(target, username, password string) tea.Cmd {
+	return func() tea.Msg {
+		defer c.Loading("logging in to " + target)()
+
+		if c.player != nil {
+			return LoginMsg{Target: target, Error: ErrReplaying}
+		}
+
+		t, err := c.loadUnauthenticatedTarget(rc.TargetName(target))
+		if err != nil {
+			return LoginMsg{Target: target, Error: err}
+		}
+
+		config := oauth2Config(t.URL())
+		ctx := context.WithValue(c.ctx, oauth2.HTTPClient, t.Client().HTTPClient())
+
+		token, err := config.PasswordCredentialsToken(ctx, username, password)
+		if err != nil {
+			var rerr *oauth2.RetrieveError
+			if errors.As(err, &rerr) && rerr.Response.StatusCode == http.StatusUnauthorized {
+				err = errors.New("invalid username or password")
+			}
+
+			return LoginMsg{Target: target, Error: err}
+		}
+
+		return c.saveLogin(target, &rc.TargetToken{
+			Type:  token.TokenType,
+			Value: token.AccessToken,
+		})
+	}
+}
+
+// LoginWithBrowser logs into the provided target through the browser (e.g. for
+// OAuth/OIDC connectors), the same way as fly. A local listener receives the
+// token from the ATC once the user has logged in. The login URL is sent as a
+// LoginURLMsg, in case the browser can't be opened (e.g. headless sessions).
+func (c *apiManager) LoginWithBrowser(target string) tea.Cmd {
+	return func() tea.Msg {
+		defer c.Loading("waiting for browser login to " + target)()
+
+		if c.player != nil {
+			return LoginMsg{Target: target, Error: ErrReplaying}
+		}
+
+		props, err := c.loadTargetProps(rc.TargetName(target))
+		if err != nil {
+			return LoginMsg{Target: target, Error: err}
+		}
+
+		ctx, cancel := context.WithTimeout(c.ctx, browserLoginTimeout)
+		defer cancel()
+
+		tokens := make(chan string, 1)
+
+		c.authMu.Lock()
+		if c.loginCancel != nil {
+			c.loginCancel()
+		}
+		c.loginCancel = cancel
+		c.loginTarget = target
+		c.loginTokens = tokens
+		c.authMu.Unlock()
+
+		defer func() {
+			c.authMu.Lock()
+			if c.loginTokens == tokens {
+				c.loginCancel = nil
+				c.loginTarget = ""
+				c.loginTokens = nil
+			}
+			c.authMu.Unlock()
+		}()
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return LoginMsg{Target: target, Error: fmt.Errorf("failed to start login listener: %w", err)}
+		}
+
+		srv := &http.Server{
+			ReadHeaderTimeout: 10 * time.Second,
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Access-Control-Allow-Origin", props.API)
+
+				select {
+				case tokens <- r.FormValue("token"):
+				default:
+				}
+
+				if r.Header.Get("Upgrade-Insecure-Requests") != "" {
+					http.Redirect(w, r, props.API+"/fly_success?noop=true", http.StatusFound)
+				}
+			}),
+		}
+		defer srv.Close()
+
+		go func() {
+			_ = srv.Serve(ln)
+		}()
+
+		url := fmt.Sprintf("%s/login?fly_port=%d", props.API, ln.Addr().(*net.TCPAddr).Port)
+
+		c.logger.WithField("url", url).Info("waiting for browser login")
+		c.bus.Publish(types.TopicLogin, LoginURLMsg{Target: target, URL: url})
+
+		if err = openBrowser(url); err != nil {
+			c.logger.WithError(err).Warn("failed to open browser")
+		}
+
+		var raw string
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return LoginMsg{Target: target, Error: errors.New("timed out waiting for browser login")}
+			}
+			return LoginMsg{Target: target, Error: errors.New("login cancelled")}
+		case raw = <-tokens:
+		}
+
+		token, err := parseToken(raw)
+		if err != nil {
+			return LoginMsg{Target: target, Error: err}
+		}
+
+		return c.saveLogin(target, token)
+	}
+}
+
+// LoginWithToken logs into the provided target with a token pasted by the
+// user, the same as entering it manually in `fly login`. This is needed when
+// the ATC can't reach the local listener of a browser login (e.g. over SSH),
+// in which case it shows the token to copy instead. If a browser login to the
+// target is in progress, it's completed with the token (and returns the
+// LoginMsg), otherwise returns api.LoginMsg.
+func (c *apiManager) LoginWithToken(target, raw string) tea.Cmd {
+	return func() tea.Msg {
+		c.authMu.Lock()
+		if c.loginTokens != nil && c.loginTarget == target {
+			select {
+			case c.loginTokens <- raw:
+			default:
+			}
+
+			c.authMu.Unlock()
+			return nil
+		}
+		c.authMu.Unlock()
+
+		defer c.Loading("logging in to " + target)()
+
+		if c.player != nil {
+			return LoginMsg{Target: target, Error: ErrReplaying}
+		}
+
+		token, err := parseToken(raw)
+		if err != nil {
+			return LoginMsg{Target: target, Error: err}
+		}
+
+		return c.saveLogin(target, token)
+	}
+}
+
+// parseToken parses a token in the format sent by the ATC to fly (e.g.
+// "bearer <value>"). A token without a type is assumed to be a bearer token.
+func parseToken(raw string) (*rc.TargetToken, error) {
+	raw = strings.TrimSpace(raw)
+
+	tokenType, tokenValue, ok := strings.Cut(raw, " ")
+	if !ok {
+		tokenType, tokenValue = "bearer", raw
+	}
+
+	tokenValue = strings.TrimSpace(tokenValue)
+	if tokenValue == "" || strings.ContainsAny(tokenValue, " \t\r\n") {
+		return nil, errors.New("invalid token, should be in the format \"bearer <token>\"")
+	}
+
+	return &rc.TargetToken{Type: tokenType, Value: tokenValue}, nil
+}
+
+// CancelLogin cancels any in-progress browser login.
+func (c *apiManager) CancelLogin() {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	if c.loginCancel != nil {
+		c.loginCancel()
+		c.loginCancel = nil
+		c.loginTokens = nil
+		c.loginTarget = ""
+	}
+}
+
+// openBrowser opens url in the default browser of the user.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	case "darwin":
+		cmd = exec.Command("open", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	go func() {
+		_ = cmd.Wait()
+	}()
+
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"errors"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/concourse/concourse/atc"
+	"github.com/concourse/concourse/fly/rc"
+	"github.com/lrstanley/hangar-ui/internal/fakeatc"
+)
+
+// flyrcToken returns the token saved in the flyrc for the test target.
+func flyrcToken(t *testing.T) *rc.TargetToken {
+	t.Helper()
+
+	targets, err := rc.LoadTargets()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return targets[testTarget].Token
+}
+
+func TestLoginWithPassword(t *testing.T) {
+	c, srv := newTestClient(t, fakeatc.NewState())
+	srv.Update(func(state *fakeatc.State) { state.Token = "new-token" })
+
+	msg := c.LoginWithPassword(testTarget, "admin", "admin")().(LoginMsg)
+	if msg.Error != nil {
+		t.Fatalf("unexpected error: %v", msg.Error)
+	}
+
+	if token := flyrcToken(t); token == nil || token.Value != "new-token" {
+		t.Errorf("expected the new token to be saved, got %v", token)
+	}
+
+	if c.NeedsLogin(testTarget) {
+		t.Error("expected the target to not need login")
+	}
+}
+
+func TestLoginWithPasswordInvalid(t *testing.T) {
+	state := fakeatc.NewState()
+	state.TokenExpired = true
+
+	c, _ := newTestClient(t, state)
+
+	msg := c.LoginWithPassword(testTarget, "admin", "wrong")().(LoginMsg)
+	if msg.Error == nil || msg.Error.Error() != "invalid username or password" {
+		t.Fatalf("expected invalid credentials, got %v", msg.Error)
+	}
+
+	if token := flyrcToken(t); token == nil || token.Value != "fake-token" {
+		t.Errorf("expected the token to be unchanged, got %v", token)
+	}
+}
+
+func TestLoginRetries(t *testing.T) {
+	state := fakeatc.NewState()
+	state.TokenExpired = true
+	state.AddPipeline(atc.Pipeline{Name: "app"}, nil, nil)
+
+	c, _ := newTestClient(t, state)
+
+	if msg := c.QueryPipelines().(PipelineListMsg); !errors.As(msg.Error, &NeedsLoginError{}) {
+		t.Fatalf("expected NeedsLoginError, got %v", msg.Error)
+	}
+
+	msg := c.LoginWithPassword(testTarget, "admin", "admin")().(LoginMsg)
+	if msg.Error != nil {
+		t.Fatalf("unexpected error: %v", msg.Error)
+	}
+
+	if msg.Retry == nil {
+		t.Fatal("expected the failed query to be retried")
+	}
+
+	batch, ok := msg.Retry().(tea.BatchMsg)
+	if !ok || len(batch) != 1 {
+		t.Fatalf("expected a single retry, got %v", batch)
+	}
+
+	retried, ok := batch[0]().(PipelineListMsg)
+	if !ok {
+		t.Fatalf("expected PipelineListMsg, got %T", retried)
+	}
+
+	if retried.Error != nil || len(retried.Pipelines) != 1 {
+		t.Errorf("expected the retry to succeed, got %v (%v)", retried.Pipelines, retried.Error)
+	}
+
+	// Retries are only replayed once.
+	if msg = c.completeLogin(testTarget).(LoginMsg); msg.Retry != nil {
+		t.Error("expected no retries after the first login")
+	}
+}
+
+func TestLoginWithToken(t *testing.T) {
+	c, srv := newTestClient(t, fakeatc.NewState())
+	srv.Update(func(state *fakeatc.State) { state.Token = "new-token" })
+
+	for _, raw := range []string{"", "bearer ", "bearer a b"} {
+		if msg := c.LoginWithToken(testTarget, raw)().(LoginMsg); msg.Error == nil {
+			t.Errorf("expected an error for token %q", raw)
+		}
+	}
+
+	msg := c.LoginWithToken(testTarget, "bearer wrong")().(LoginMsg)
+	if !errors.As(msg.Error, &NeedsLoginError{}) {
+		t.Errorf("expected NeedsLoginError for a rejected token, got %v", msg.Error)
+	}
+
+	msg = c.LoginWithToken(testTarget, " Bearer new-token\n")().(LoginMsg)
+	if msg.Error != nil {
+		t.Fatalf("unexpected error: %v", msg.Error)
+	}
+
+	if token := flyrcToken(t); token == nil || token.Type != "Bearer" || token.Value != "new-token" {
+		t.Errorf("expected the pasted token to be saved, got %v", token)
+	}
+}
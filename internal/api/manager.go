@@ -6,89 +6,237 @@ package api
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/apex/log"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/concourse/concourse/atc"
 	"github.com/concourse/concourse/fly/rc"
 	"github.com/concourse/concourse/go-concourse/concourse"
 	"github.com/lrstanley/clix"
 	"github.com/lrstanley/hangar-ui/internal/types"
 )
 
-var Manager *apiManager
+// Manager is the API layer used by the UI, providing access to the configured
+// fly targets, the active target, and all queries and actions against it.
+// NewAPIClient returns the default implementation, which can be swapped out
+// (e.g. with a fake backend for testing).
+type Manager interface {
+	// Targets.
+	UpdateTargets()
+	ReloadConfig() tea.Cmd
+	Targets() rc.Targets
+	TargetNames() []string
+	Active() rc.Target
+	ActiveName() string
+	Client() concourse.Client
+	SetActive(targetName string) error
+	NeedsLogin(target string) bool
+	StaleSince(target string) time.Time
+	Compat(target string) []string
+	Supports(target string, feature Feature) error
+	LoginWithPassword(target, username, password string) tea.Cmd
+	LoginWithBrowser(target string) tea.Cmd
+	LoginWithToken(target, token string) tea.Cmd
+	CancelLogin()
+	AddTarget(cfg TargetConfig) tea.Cmd
+	EditTarget(name string, cfg TargetConfig) tea.Cmd
+	DeleteTarget(name string) tea.Cmd
+
+	// Lifecycle.
+	Subscribe(view types.Viewable, topics ...types.Topic)
+	HandleMsg(cb func(tea.Msg))
+	Busy() bool
+	Close()
+
+	// Polling.
+	Poll(view types.Viewable, key string, interval time.Duration, query tea.Cmd)
+	SetVisible(view types.Viewable)
+	Refresh(key string)
+
+	// Queries.
+	QueryPipelines() tea.Msg
+	QueryAllPipelines(targets []string) tea.Cmd
+	LoadPipelinesSnapshot() tea.Msg
+	QueryTargetInfo() tea.Msg
+	QueryPipelineConfig(view types.Viewable, target, team string, pipeline atc.PipelineRef) tea.Cmd
+	QueryJobInputVersions(view types.Viewable, pipeline atc.PipelineRef, job string) tea.Cmd
+	QueryRequests() tea.Msg
+
+	// Actions.
+	Execute(opts ExecuteOptions) tea.Cmd
+	AbortBuild(id int) tea.Cmd
+	TriggerWithVersions(pipeline atc.PipelineRef, job string, versions map[string]int) tea.Cmd
+	ClearTaskCache(pipeline atc.PipelineRef, job, step, cachePath string) tea.Cmd
+	ClearResourceCache(pipeline atc.PipelineRef, resource string, version atc.Version) tea.Cmd
+	PlanPauseAll(team, path string) tea.Cmd
+	PlanRestorePaused(path string) tea.Cmd
+	ApplyPausePlan(plan PausePlanMsg) tea.Cmd
+	ApplyPipelineConfig(target, team string, pipeline atc.PipelineRef, version string, config []byte) tea.Cmd
+	SyncFly(path string, force bool) tea.Cmd
+	ClearRequests()
+}
+
+var _ Manager = (*apiManager)(nil) // Validate interface.
 
 type apiManager struct {
-	ctx      context.Context
-	logger   log.Interface
-	config   *clix.CLI[types.Flags]
-	signaler chan tea.Msg
+	ctx    context.Context
+	logger log.Interface
+	config *clix.CLI[types.Flags]
+	bus    *types.EventBus
+
+	// settings is the config file, loaded once on startup (see ReloadConfig).
+	settings types.Atomic[loadedConfig]
 
 	cancelFn func()
 	wg       sync.WaitGroup
 
+	// busy is the number of queries and requests in flight.
+	busy atomic.Int64
+
 	targets           types.Atomic[rc.Targets]
 	currentTarget     types.Atomic[rc.Target]
 	currentTargetName types.Atomic[string]
+
+	// loaded are the targets loaded from the flyrc, which are reused (along
+	// with their connections) until they change.
+	loadedMu sync.Mutex
+	loaded   map[string]rc.Target
+
+	// flyrcMu serializes edits of the flyrc, see editFlyrc.
+	flyrcMu sync.Mutex
+
+	authMu      sync.Mutex
+	needsLogin  map[string]bool
+	retries     map[string]map[string]tea.Cmd
+	loginCancel context.CancelFunc
+	// loginTarget and loginTokens are the target and token channel of the
+	// in-progress browser login (if any), see LoginWithToken.
+	loginTarget string
+	loginTokens chan<- string
+
+	sched    *scheduler
+	cache    *responseCache
+	requests *requestLog
+	recorder *cassetteRecorder
+	player   *cassettePlayer
+
+	snapshots *snapshotStore
+	staleMu   sync.Mutex
+	stale     map[staleKey]time.Time
+
+	compatMu sync.Mutex
+	compat   map[string]targetCompat
 }
 
-func NewAPIClient(ctx context.Context, config *clix.CLI[types.Flags]) {
-	Manager = &apiManager{
-		logger:   log.WithField("src", "api-client"),
-		config:   config,
-		signaler: make(chan tea.Msg, 50),
+// NewAPIClient returns the default Manager, backed by the fly config file
+// (flyrc), or by a cassette when replaying (--replay), and starts the
+// background worker.
+func NewAPIClient(ctx context.Context, config *clix.CLI[types.Flags]) Manager {
+	c := &apiManager{
+		logger: log.WithField("src", "api-client"),
+		config: config,
+		bus:    types.NewEventBus(types.DefaultPolicies),
+
+		loaded:     map[string]rc.Target{},
+		needsLogin: map[string]bool{},
+		retries:    map[string]map[string]tea.Cmd{},
+
+		sched:    newScheduler(),
+		cache:    newResponseCache(),
+		requests: &requestLog{},
+		stale:    map[staleKey]time.Time{},
+		compat:   map[string]targetCompat{},
 	}
 
-	Manager.ctx, Manager.cancelFn = context.WithCancel(ctx)
+	var err error
 
-	Manager.UpdateTargets()
+	// An invalid config file fails all targets (see newTarget), rather than
+	// silently ignoring their settings.
+	cfg, err := LoadConfig(config.Flags.Config)
+	c.settings.Store(loadedConfig{cfg: cfg, err: err})
 
-	if config.Flags.Target != "" {
-		if err := Manager.SetActive(config.Flags.Target); err != nil {
-			Manager.logger.WithError(err).WithField("target", config.Flags.Target).Fatal("failed to configure target")
-		}
-	} else {
-		targets := Manager.TargetNames()
-		if len(targets) == 0 {
-			Manager.logger.Fatal("no targets found, please setup one with `fly -t <target> login`")
-			return
+	c.snapshots, err = newSnapshotStore(config.Flags.CacheDir)
+	if err != nil {
+		c.logger.WithError(err).Warn("failed to find cache directory, offline snapshots are disabled")
+	}
+
+	c.ctx, c.cancelFn = context.WithCancel(ctx)
+	c.sched.viewCtx, c.sched.viewCancel = context.WithCancel(c.ctx)
+
+	switch {
+	case config.Flags.Replay != "":
+		c.player, err = loadCassette(config.Flags.Replay)
+		if err != nil {
+			// Never fall back to the network, replay an empty session instead.
+			c.logger.WithError(err).Error("failed to load cassette")
+			c.bus.Publish(types.TopicNotify, types.NotifyMsg{Error: err})
+			c.player = &cassettePlayer{}
 		}
 
-		if err := Manager.SetActive(targets[0]); err != nil {
-			Manager.logger.WithError(err).WithField("target", targets[0]).Fatal("failed to configure target")
-			return
+		// Replays have to be deterministic, and must not replace the offline
+		// snapshots of the actual targets.
+		c.snapshots = &snapshotStore{}
+
+		if config.Flags.Record != "" {
+			c.bus.Publish(types.TopicNotify, types.NotifyMsg{Error: errors.New("--record can't be used with --replay, not recording")})
+		}
+	case config.Flags.Record != "":
+		c.recorder, err = newCassetteRecorder(config.Flags.Record)
+		if err != nil {
+			c.logger.WithError(err).Error("failed to start recording")
+			c.bus.Publish(types.TopicNotify, types.NotifyMsg{Error: err})
 		}
 	}
 
-	Manager.wg.Add(1)
-	go Manager.Watcher()
-}
+	c.UpdateTargets()
 
-// HandleMsg allows the user to handle a message from the signaler channel.
-func (c *apiManager) HandleMsg(cb func(tea.Msg)) {
-	var cmd tea.Msg
-	for {
-		select {
-		case <-c.ctx.Done():
-			return
-		case cmd = <-c.signaler:
-			cb(cmd)
+	targets := c.TargetNames()
+	if config.Flags.Target != "" {
+		targets = append([]string{config.Flags.Target}, targets...)
+	}
+
+	// Use the requested target, falling back to the first one which can be
+	// loaded. Expired credentials don't prevent a target from being used, the
+	// user is prompted to login instead. If there are no usable targets, the
+	// UI starts with onboarding, to add one.
+	for _, name := range targets {
+		if err = c.SetActive(name); err == nil {
+			break
 		}
+
+		c.logger.WithError(err).WithField("target", name).Error("failed to configure target")
+		c.bus.Publish(types.TopicNotify, types.NotifyMsg{Error: fmt.Errorf("failed to configure target %q: %w", name, err)})
 	}
-}
 
-// UpdateTargets updates the targets list from the fly config file (flyrc).
-func (c *apiManager) UpdateTargets() {
-	targets, err := rc.LoadTargets()
-	if err != nil {
-		c.logger.WithError(err).Fatal("failed to load targets from flyrc")
-		return
+	if c.ActiveName() == "" {
+		c.logger.Warn("no usable targets found, starting onboarding")
 	}
 
-	c.targets.Store(targets)
-	c.signaler <- types.FlyTargetsUpdated
+	c.wg.Add(2)
+	go c.Watcher()
+	go c.Scheduler()
+
+	return c
+}
+
+// Subscribe subscribes view to the provided topics of events published by the
+// client, which are delivered through HandleMsg as types.ViewMsg.
+func (c *apiManager) Subscribe(view types.Viewable, topics ...types.Topic) {
+	c.bus.Subscribe(view, topics...)
+}
+
+// HandleMsg delivers the events published by the client to cb (e.g.
+// tea.Program.Send), until the client is closed. Events which can't be
+// delivered fast enough are dropped or coalesced (see types.Policy), rather
+// than blocking the client.
+func (c *apiManager) HandleMsg(cb func(tea.Msg)) {
+	c.bus.Run(c.ctx, cb)
 }
 
 // Targets returns the current known targets list.
@@ -122,12 +270,28 @@ func (c *apiManager) Client() concourse.Client {
 	return c.currentTarget.Load().Client()
 }
 
-// SetActive sets the active target to the given target name. This will fail if
-// the target credentials are outdated.
+// clientFor returns a concourse.Client for the provided target, or for the
+// active target if empty, along with the resolved target name.
+func (c *apiManager) clientFor(target string) (concourse.Client, string, error) {
+	if target == "" || target == c.ActiveName() {
+		return c.Client(), c.ActiveName(), nil
+	}
+
+	t, err := c.loadTarget(rc.TargetName(target))
+	if err != nil {
+		return nil, target, err
+	}
+
+	return t.Client(), target, nil
+}
+
+// SetActive sets the active target to the given target name. Targets with
+// outdated credentials can still be used, and are flagged as needing login on
+// the first rejected request.
 func (c *apiManager) SetActive(targetName string) error {
 	c.logger.WithField("target", targetName).Debug("setting active target")
 
-	target, err := rc.LoadTarget(rc.TargetName(targetName), false)
+	target, err := c.loadTarget(rc.TargetName(targetName))
 	if err != nil {
 		return err
 	}
@@ -135,34 +299,40 @@ func (c *apiManager) SetActive(targetName string) error {
 	c.currentTarget.Store(target)
 	c.currentTargetName.Store(targetName)
 
-	c.signaler <- types.FlyActiveTargetUpdated
+	c.bus.Publish(types.TopicState, types.FlyActiveTargetUpdated)
+
+	c.wg.Add(1)
+	go c.checkCompat(targetName, target)
 
 	return nil
 }
 
-// Close closes the api client, and signals to stop the background worker.
+// Close closes the api client, canceling all in-flight requests, and waits for
+// the background workers to stop. If recording, the cassette is completed once
+// the workers are done.
 func (c *apiManager) Close() {
 	c.cancelFn()
 	c.wg.Wait()
-}
 
-// Watcher is a background worker that will periodically update the API client
-// using the flyrc, and other forms of configuration.
-func (c *apiManager) Watcher() {
-	for {
-		select {
-		case <-c.ctx.Done():
-			return
-		case <-time.After(5 * time.Second):
-			c.UpdateTargets()
+	if c.recorder != nil {
+		if err := c.recorder.close(); err != nil {
+			c.logger.WithError(err).Error("failed to close cassette")
 		}
 	}
 }
 
 func (c *apiManager) Loading(text string) (cancel func()) {
-	c.signaler <- types.LoadingMsg{Text: text}
+	c.busy.Add(1)
+	c.bus.Publish(types.TopicLoading, types.LoadingMsg{Text: text})
 
 	return func() {
-		c.signaler <- types.CancelLoadingMsg{}
+		c.bus.Publish(types.TopicLoading, types.CancelLoadingMsg{})
+		c.busy.Add(-1)
 	}
 }
+
+// Busy returns true while any queries (or requests to a target) are in flight.
+// Long-running streams, like build events, only count until they are opened.
+func (c *apiManager) Busy() bool {
+	return c.busy.Load() > 0
+}
@@ -0,0 +1,496 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/concourse/concourse/atc"
+	"github.com/concourse/concourse/atc/event"
+	"github.com/lrstanley/clix"
+	"github.com/lrstanley/hangar-ui/internal/fakeatc"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+// testTarget is the name of the target pointing at the fake ATC.
+const testTarget = "fake"
+
+// newTestClient returns a client whose only target is a fake ATC serving
+// state. Both are closed once the test is done.
+func newTestClient(t *testing.T, state *fakeatc.State) (*apiManager, *fakeatc.Server) {
+	t.Helper()
+
+	srv := fakeatc.New(state)
+	t.Cleanup(srv.Close)
+
+	restore, err := srv.UseFlyrc(testTarget)
+	if err != nil {
+		t.Fatalf("failed to write flyrc: %v", err)
+	}
+	t.Cleanup(restore)
+
+	c := NewAPIClient(context.Background(), &clix.CLI[types.Flags]{Flags: &types.Flags{
+		Target:   testTarget,
+		CacheDir: t.TempDir(),
+	}}).(*apiManager)
+	t.Cleanup(c.Close)
+
+	if c.ActiveName() != testTarget {
+		t.Fatalf("expected the active target to be %q, got %q", testTarget, c.ActiveName())
+	}
+
+	return c, srv
+}
+
+// subscribe subscribes to the provided topics, and returns a channel receiving
+// all published messages.
+func subscribe(c *apiManager, topics ...types.Topic) <-chan tea.Msg {
+	ch := make(chan tea.Msg, 100)
+
+	c.Subscribe("test", topics...)
+	go c.HandleMsg(func(msg tea.Msg) {
+		ch <- msg.(types.ViewMsg).Msg
+	})
+
+	return ch
+}
+
+// waitFor returns the first message received on ch of the same type as want.
+func waitFor[T tea.Msg](t *testing.T, ch <-chan tea.Msg) (want T) {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		select {
+		case msg := <-ch:
+			if m, ok := msg.(T); ok {
+				return m
+			}
+		case <-timeout:
+			t.Fatalf("timed out waiting for %T", want)
+			return want
+		}
+	}
+}
+
+func TestQueryPipelines(t *testing.T) {
+	state := fakeatc.NewState()
+	state.AddPipeline(atc.Pipeline{Name: "app"}, nil, nil)
+	state.AddPipeline(atc.Pipeline{Name: "infra", Paused: true}, nil, nil)
+
+	c, _ := newTestClient(t, state)
+
+	msg := c.QueryPipelines().(PipelineListMsg)
+	if msg.Error != nil {
+		t.Fatalf("unexpected error: %v", msg.Error)
+	}
+
+	var names []string
+	for _, p := range msg.Pipelines {
+		names = append(names, p.Name)
+	}
+
+	if want := []string{"app", "infra"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected pipelines %v, got %v", want, names)
+	}
+
+	if !msg.Stale.IsZero() {
+		t.Errorf("expected live pipelines, got a snapshot from %s", msg.Stale)
+	}
+}
+
+func TestQueryPipelinesNeedsLogin(t *testing.T) {
+	state := fakeatc.NewState()
+	state.TokenExpired = true
+
+	c, _ := newTestClient(t, state)
+
+	msg := c.QueryPipelines().(PipelineListMsg)
+	if !errors.As(msg.Error, &NeedsLoginError{}) {
+		t.Fatalf("expected NeedsLoginError, got %v", msg.Error)
+	}
+
+	if !c.NeedsLogin(testTarget) {
+		t.Error("expected the target to need login")
+	}
+}
+
+func TestQueryTargetInfo(t *testing.T) {
+	c, _ := newTestClient(t, fakeatc.NewState())
+
+	msg := c.QueryTargetInfo().(TargetInfoMsg)
+	if len(msg) != 1 {
+		t.Fatalf("expected 1 target, got %d", len(msg))
+	}
+
+	info := msg[0]
+
+	if info.Status != TargetOK || info.Error != nil {
+		t.Errorf("expected target to be ok, got %s (%v)", info.Status, info.Error)
+	}
+
+	if info.Info.Version != APIVersion {
+		t.Errorf("expected version %s, got %q", APIVersion, info.Info.Version)
+	}
+}
+
+func TestQueryTargetInfoLoadError(t *testing.T) {
+	c, _ := newTestClient(t, fakeatc.NewState())
+
+	err := c.editFlyrc(func(targets map[string]map[string]any) error {
+		targets["broken"] = map[string]any{"api": "http://127.0.0.1:1", "team": "main", "ca_cert": "not a cert"}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.UpdateTargets()
+
+	msg := c.QueryTargetInfo().(TargetInfoMsg)
+	if len(msg) != 2 {
+		t.Fatalf("expected 2 targets, got %d", len(msg))
+	}
+
+	info := msg[0]
+	if info.TargetName != "broken" || info.Status != TargetError || info.Error == nil || info.Target != nil {
+		t.Errorf("expected the broken target to be shown with an error, got %+v", info)
+	}
+
+	if msg[1].Status != TargetOK {
+		t.Errorf("expected the other target to be ok, got %s (%v)", msg[1].Status, msg[1].Error)
+	}
+}
+
+func TestQueryTargetInfoPending(t *testing.T) {
+	c, srv := newTestClient(t, fakeatc.NewState())
+	updates := subscribe(c, types.TopicQuery)
+
+	done := make(chan TargetInfoMsg, 1)
+
+	// Hold the fake ATC, so the target can't respond until its pending state
+	// was published.
+	srv.Update(func(_ *fakeatc.State) {
+		go func() { done <- c.QueryTargetInfo().(TargetInfoMsg) }()
+
+		if update := waitFor[TargetInfoUpdateMsg](t, updates); update.Status != TargetPending {
+			t.Errorf("expected the target to be pending first, got %s", update.Status)
+		}
+	})
+
+	if update := waitFor[TargetInfoUpdateMsg](t, updates); update.Status != TargetOK {
+		t.Errorf("expected the target to be ok once queried, got %s", update.Status)
+	}
+
+	if msg := <-done; len(msg) != 1 || msg[0].Status != TargetOK {
+		t.Errorf("expected a single ok target, got %v", msg)
+	}
+}
+
+func TestLoadTargetReused(t *testing.T) {
+	c, _ := newTestClient(t, fakeatc.NewState())
+
+	first, err := c.loadTarget(testTarget)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := c.loadTarget(testTarget)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second {
+		t.Error("expected the target to be reused between loads")
+	}
+
+	c.unloadTargets(testTarget)
+
+	if third, _ := c.loadTarget(testTarget); third == first {
+		t.Error("expected the target to be loaded again once unloaded")
+	}
+}
+
+func TestTriggerWithVersions(t *testing.T) {
+	ref := atc.PipelineRef{Name: "app"}
+
+	state := fakeatc.NewState()
+	state.AddPipeline(
+		atc.Pipeline{Name: ref.Name},
+		[]atc.Job{{Name: "build", Inputs: []atc.JobInput{{Name: "repo", Resource: "repo"}}}},
+		[]atc.Resource{{Name: "repo", Type: "git"}},
+	)
+	state.AddVersions(atc.DefaultTeamName, ref, "repo",
+		atc.Version{"ref": "v1"},
+		atc.Version{"ref": "v2"},
+		atc.Version{"ref": "v3"},
+	)
+
+	key := fakeatc.ResourceKey(atc.DefaultTeamName, ref, "repo")
+
+	// Versions are listed newest first.
+	v1, v2 := state.Versions[key][2], state.Versions[key][1]
+
+	// The resource is already pinned, which has to be restored afterwards.
+	resource := &state.Resources[fakeatc.PipelineKey(atc.DefaultTeamName, ref)][0]
+	resource.PinnedVersion = v1.Version
+	resource.PinComment = "pinned by a human"
+
+	var pinnedAtBuild atc.Version
+	state.OnCreateBuild = func(build *atc.Build) []atc.Event {
+		pinnedAtBuild = resource.PinnedVersion
+		build.Status = atc.StatusSucceeded
+
+		return []atc.Event{}
+	}
+
+	c, srv := newTestClient(t, state)
+	events := subscribe(c, types.TopicBuilds)
+
+	msg := c.TriggerWithVersions(ref, "build", map[string]int{"repo": v2.ID})().(BuildStartedMsg)
+	if msg.Error != nil {
+		t.Fatalf("unexpected error: %v", msg.Error)
+	}
+
+	if started := waitFor[BuildStartedMsg](t, events); started.Build.ID != msg.Build.ID {
+		t.Errorf("expected build %d to be published, got %d", msg.Build.ID, started.Build.ID)
+	}
+
+	srv.Update(func(state *fakeatc.State) {
+		if !reflect.DeepEqual(pinnedAtBuild, v2.Version) {
+			t.Errorf("expected %v to be pinned when triggering, got %v", v2.Version, pinnedAtBuild)
+		}
+
+		if !reflect.DeepEqual(resource.PinnedVersion, v1.Version) {
+			t.Errorf("expected pin to be restored to %v, got %v", v1.Version, resource.PinnedVersion)
+		}
+
+		if resource.PinComment != "pinned by a human" {
+			t.Errorf("expected pin comment to be restored, got %q", resource.PinComment)
+		}
+	})
+}
+
+func TestPinVersionNotFound(t *testing.T) {
+	ref := atc.PipelineRef{Name: "app"}
+
+	state := fakeatc.NewState()
+	state.AddPipeline(atc.Pipeline{Name: ref.Name}, nil, []atc.Resource{{Name: "repo", Type: "git"}})
+	state.AddVersions(atc.DefaultTeamName, ref, "repo", atc.Version{"ref": "v1"})
+
+	c, _ := newTestClient(t, state)
+	team := c.Active().Team()
+
+	if err := pinVersion(team, ref, "repo", 100, "comment"); err == nil {
+		t.Error("expected an error pinning an unknown version")
+	}
+
+	if err := pinVersion(team, ref, "missing", 1, "comment"); err == nil {
+		t.Error("expected an error pinning an unknown resource")
+	}
+
+	if err := pinVersion(team, ref, "repo", 1, "comment"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestResourceVersionID(t *testing.T) {
+	ref := atc.PipelineRef{Name: "app"}
+
+	state := fakeatc.NewState()
+	state.AddPipeline(atc.Pipeline{Name: ref.Name}, nil, []atc.Resource{{Name: "repo", Type: "git"}})
+	state.AddVersions(atc.DefaultTeamName, ref, "repo",
+		atc.Version{"ref": "v1"},
+		atc.Version{"ref": "v2"},
+	)
+
+	// Versions are listed newest first.
+	want := state.Versions[fakeatc.ResourceKey(atc.DefaultTeamName, ref, "repo")][1].ID
+
+	c, _ := newTestClient(t, state)
+	team := c.Client().Team(atc.DefaultTeamName)
+
+	id, err := resourceVersionID(team, ref, "repo", atc.Version{"ref": "v1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if id != want {
+		t.Errorf("expected version ID %d, got %d", want, id)
+	}
+
+	if _, err = resourceVersionID(team, ref, "repo", atc.Version{"ref": "v3"}); err == nil {
+		t.Error("expected an error for an unknown version")
+	}
+}
+
+func TestExecute(t *testing.T) {
+	state := fakeatc.NewState()
+
+	// An artifact which already exists, which uploads must not replace.
+	state.Artifacts[2] = []byte("existing")
+
+	var inputID int
+	state.OnCreateBuild = func(build *atc.Build) []atc.Event {
+		// Echo the uploaded input back as the output.
+		for id := range state.Artifacts {
+			if id != 2 {
+				inputID = id
+			}
+		}
+
+		state.Artifacts[100] = state.Artifacts[inputID]
+		state.BuildArtifacts[build.ID] = []atc.WorkerArtifact{{ID: 100, Name: "out"}}
+		build.Status = atc.StatusSucceeded
+
+		return []atc.Event{event.Status{Status: atc.StatusSucceeded, Time: build.StartTime}}
+	}
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "task.yml")
+	input := filepath.Join(dir, "in")
+	output := filepath.Join(dir, "out")
+
+	writeFile(t, config, "platform: linux\nrun: {path: true}\ninputs: [{name: in}]\noutputs: [{name: out}]\n")
+	writeFile(t, filepath.Join(input, "file.txt"), "hello")
+
+	c, srv := newTestClient(t, state)
+	events := subscribe(c, types.TopicBuilds)
+
+	msg := c.Execute(ExecuteOptions{
+		ConfigPath: config,
+		Inputs:     map[string]string{"in": input},
+		Outputs:    map[string]string{"out": output},
+	})().(BuildStartedMsg)
+	if msg.Error != nil {
+		t.Fatalf("unexpected error: %v", msg.Error)
+	}
+
+	finished := waitFor[BuildFinishedMsg](t, events)
+	if finished.Error != nil || finished.Status != atc.StatusSucceeded {
+		t.Fatalf("expected build to succeed, got %s (%v)", finished.Status, finished.Error)
+	}
+
+	srv.Update(func(state *fakeatc.State) {
+		if string(state.Artifacts[2]) != "existing" {
+			t.Error("existing artifact was replaced by the uploaded input")
+		}
+	})
+
+	b, err := os.ReadFile(filepath.Join(output, "file.txt"))
+	if err != nil || string(b) != "hello" {
+		t.Errorf("expected output to be downloaded, got %q (%v)", b, err)
+	}
+}
+
+func TestExecuteLeaveBuildView(t *testing.T) {
+	state := fakeatc.NewState()
+
+	opened := make(chan struct{})
+	release := make(chan struct{})
+
+	state.OnCreateBuild = func(build *atc.Build) []atc.Event {
+		// Echo the uploaded input (the only artifact) back as the output.
+		for _, b := range state.Artifacts {
+			state.Artifacts[100] = b
+			break
+		}
+
+		state.BuildArtifacts[build.ID] = []atc.WorkerArtifact{{ID: 100, Name: "out"}}
+		build.Status = atc.StatusSucceeded
+
+		return []atc.Event{
+			event.Log{Payload: "hello\n"},
+			event.Status{Status: atc.StatusSucceeded, Time: build.StartTime},
+		}
+	}
+	state.OnStreamEvents = func(_ int) {
+		close(opened)
+		<-release
+	}
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "task.yml")
+	input := filepath.Join(dir, "in")
+	output := filepath.Join(dir, "out")
+
+	writeFile(t, config, "platform: linux\nrun: {path: true}\ninputs: [{name: in}]\noutputs: [{name: out}]\n")
+	writeFile(t, filepath.Join(input, "file.txt"), "hello")
+
+	c, _ := newTestClient(t, state)
+	events := subscribe(c, types.TopicBuilds)
+
+	c.SetVisible(types.ViewBuild)
+
+	msg := c.Execute(ExecuteOptions{
+		ConfigPath: config,
+		Inputs:     map[string]string{"in": input},
+		Outputs:    map[string]string{"out": output},
+	})().(BuildStartedMsg)
+	if msg.Error != nil {
+		t.Fatalf("unexpected error: %v", msg.Error)
+	}
+
+	select {
+	case <-opened:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the build events to be streamed")
+	}
+
+	// Leave the build view while the build is running.
+	c.SetVisible(types.ViewRoot)
+	close(release)
+
+	timeout := time.After(5 * time.Second)
+
+	for {
+		var next tea.Msg
+
+		select {
+		case next = <-events:
+		case <-timeout:
+			t.Fatal("timed out waiting for the build to finish")
+		}
+
+		switch m := next.(type) {
+		case BuildEventMsg:
+			t.Errorf("expected no events once the build view was left, got %q", m.Text)
+			continue
+		case BuildFinishedMsg:
+			if m.Error != nil || m.Status != atc.StatusSucceeded {
+				t.Fatalf("expected build to succeed, got %s (%v)", m.Status, m.Error)
+			}
+		default:
+			continue
+		}
+
+		break
+	}
+
+	b, err := os.ReadFile(filepath.Join(output, "file.txt"))
+	if err != nil || string(b) != "hello" {
+		t.Errorf("expected outputs to be downloaded, got %q (%v)", b, err)
+	}
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,387 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/concourse/concourse/atc"
+	"github.com/concourse/concourse/go-concourse/concourse"
+)
+
+// PauseSnapshot is the paused state of all pipelines (and their jobs) of a
+// target, as it was before they were bulk paused.
+type PauseSnapshot struct {
+	Target    string             `json:"target"`
+	URL       string             `json:"url"`
+	Team      string             `json:"team,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+	Pipelines []PipelineSnapshot `json:"pipelines"`
+}
+
+type PipelineSnapshot struct {
+	Team         string           `json:"team"`
+	Name         string           `json:"name"`
+	InstanceVars atc.InstanceVars `json:"instance_vars,omitempty"`
+	Paused       bool             `json:"paused"`
+	Jobs         []JobSnapshot    `json:"jobs"`
+}
+
+type JobSnapshot struct {
+	Name   string `json:"name"`
+	Paused bool   `json:"paused"`
+}
+
+// Ref returns the pipeline reference of the snapshotted pipeline.
+func (p PipelineSnapshot) Ref() atc.PipelineRef {
+	return atc.PipelineRef{Name: p.Name, InstanceVars: p.InstanceVars}
+}
+
+// PauseChange is a single pause or unpause of a pipeline, or of a job if Job is
+// set.
+type PauseChange struct {
+	Team     string
+	Pipeline atc.PipelineRef
+	Job      string
+	Pause    bool
+}
+
+func (c PauseChange) String() string {
+	action := "unpause"
+	if c.Pause {
+		action = "pause"
+	}
+
+	if c.Job != "" {
+		return fmt.Sprintf("%s job %s/%s/%s", action, c.Team, c.Pipeline.String(), c.Job)
+	}
+
+	return fmt.Sprintf("%s pipeline %s/%s", action, c.Team, c.Pipeline.String())
+}
+
+// PausePlanMsg contains the changes that would be made by a bulk pause, or by
+// restoring a snapshot. Nothing is changed until it's passed to ApplyPausePlan.
+type PausePlanMsg struct {
+	// Snapshot is the snapshot that will be written (when pausing), or restored
+	// from (when restoring).
+	Snapshot PauseSnapshot
+	// Path is where the snapshot will be written to, or was read from.
+	Path    string
+	Restore bool
+	Changes []PauseChange
+	Error   error
+}
+
+// PauseAppliedMsg is returned once a pause plan has been applied.
+type PauseAppliedMsg struct {
+	Plan    PausePlanMsg
+	Applied int
+	Error   error
+}
+
+// SnapshotDir returns the directory that pause snapshots are stored in by
+// default.
+func SnapshotDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "hangar-ui", "snapshots"), nil
+}
+
+// snapshotTimeFormat is the format of the timestamp in snapshot filenames,
+// which sorts lexically.
+const snapshotTimeFormat = "20060102-150405"
+
+// snapshotFilename returns the filename of the snapshot of the provided target,
+// created at t.
+func snapshotFilename(target string, t time.Time) string {
+	return target + "-" + t.Format(snapshotTimeFormat) + ".json"
+}
+
+// isSnapshotOf returns true if filename is a snapshot of the provided target.
+// Other targets may share the same prefix (e.g. "prod" and "prod-eu"), so the
+// rest of the filename has to be exactly the timestamp.
+func isSnapshotOf(target, filename string) bool {
+	if !strings.HasPrefix(filename, target+"-") || !strings.HasSuffix(filename, ".json") {
+		return false
+	}
+
+	ts := strings.TrimSuffix(strings.TrimPrefix(filename, target+"-"), ".json")
+
+	_, err := time.Parse(snapshotTimeFormat, ts)
+	return err == nil
+}
+
+// LatestSnapshot returns the path of the most recent snapshot for the provided
+// target.
+func LatestSnapshot(target string) (string, error) {
+	dir, err := SnapshotDir()
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	var matches []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && isSnapshotOf(target, entry.Name()) {
+			matches = append(matches, entry.Name())
+		}
+	}
+
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no snapshots found for target %q in %s", target, dir)
+	}
+
+	// Timestamps in the filename sort lexically.
+	sort.Strings(matches)
+	return filepath.Join(dir, matches[len(matches)-1]), nil
+}
+
+// PlanPauseAll snapshots the paused state of all pipelines of the active target
+// (or only those of team, if provided), and returns the changes required to
+// pause them all. If path is empty, the snapshot is stored in SnapshotDir.
+func (c *apiManager) PlanPauseAll(team, path string) tea.Cmd {
+	return func() tea.Msg {
+		defer c.Loading("snapshotting pipelines")()
+
+		snapshot, err := c.snapshotPaused(team)
+		if err != nil {
+			return PausePlanMsg{Error: err}
+		}
+
+		if path == "" {
+			var dir string
+
+			dir, err = SnapshotDir()
+			if err != nil {
+				return PausePlanMsg{Error: err}
+			}
+
+			path = filepath.Join(dir, snapshotFilename(snapshot.Target, snapshot.CreatedAt))
+		}
+
+		msg := PausePlanMsg{Snapshot: snapshot, Path: path}
+
+		for _, p := range snapshot.Pipelines {
+			if !p.Paused {
+				msg.Changes = append(msg.Changes, PauseChange{Team: p.Team, Pipeline: p.Ref(), Pause: true})
+			}
+		}
+
+		c.logger.WithFields(log.Fields{
+			"team":    team,
+			"changes": len(msg.Changes),
+		}).Debug("planned bulk pause")
+
+		return msg
+	}
+}
+
+// PlanRestorePaused reads the snapshot at path, and returns the changes
+// required to unpause everything that wasn't paused when the snapshot was
+// taken. Pipelines and jobs that were already paused are left alone.
+func (c *apiManager) PlanRestorePaused(path string) tea.Cmd {
+	return func() tea.Msg {
+		defer c.Loading("comparing snapshot")()
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return PausePlanMsg{Error: err}
+		}
+
+		var previous PauseSnapshot
+		if err = json.Unmarshal(b, &previous); err != nil {
+			return PausePlanMsg{Error: fmt.Errorf("invalid snapshot %q: %w", path, err)}
+		}
+
+		if previous.URL != c.Active().URL() {
+			return PausePlanMsg{Error: fmt.Errorf("snapshot was taken from %s, but the active target is %s", previous.URL, c.Active().URL())}
+		}
+
+		current, err := c.snapshotPaused(previous.Team)
+		if err != nil {
+			return PausePlanMsg{Error: err}
+		}
+
+		msg := PausePlanMsg{Snapshot: previous, Path: path, Restore: true}
+
+		for _, prev := range previous.Pipelines {
+			cur, ok := findPipelineSnapshot(current.Pipelines, prev)
+			if !ok {
+				continue
+			}
+
+			if !prev.Paused && cur.Paused {
+				msg.Changes = append(msg.Changes, PauseChange{Team: prev.Team, Pipeline: prev.Ref()})
+			}
+
+			for _, job := range prev.Jobs {
+				if job.Paused {
+					continue
+				}
+
+				for _, curJob := range cur.Jobs {
+					if curJob.Name == job.Name && curJob.Paused {
+						msg.Changes = append(msg.Changes, PauseChange{Team: prev.Team, Pipeline: prev.Ref(), Job: job.Name})
+					}
+				}
+			}
+		}
+
+		c.logger.WithFields(log.Fields{
+			"path":    path,
+			"changes": len(msg.Changes),
+		}).Debug("planned pause restore")
+
+		return msg
+	}
+}
+
+// ApplyPausePlan applies the changes from a plan returned by PlanPauseAll or
+// PlanRestorePaused. When pausing, the snapshot is written before any changes
+// are made.
+func (c *apiManager) ApplyPausePlan(plan PausePlanMsg) tea.Cmd {
+	return func() tea.Msg {
+		if plan.Restore {
+			defer c.Loading("restoring paused state")()
+		} else {
+			defer c.Loading("pausing pipelines")()
+
+			if err := writeSnapshot(plan.Path, plan.Snapshot); err != nil {
+				return PauseAppliedMsg{Plan: plan, Error: fmt.Errorf("failed to write snapshot: %w", err)}
+			}
+		}
+
+		client := c.Active().Client()
+
+		var applied int
+		var errs []string
+
+		for _, change := range plan.Changes {
+			if err := applyPauseChange(client.Team(change.Team), change); err != nil {
+				errs = append(errs, change.String()+": "+err.Error())
+				continue
+			}
+
+			applied++
+		}
+
+		c.logger.WithFields(log.Fields{
+			"restore": plan.Restore,
+			"applied": applied,
+			"failed":  len(errs),
+		}).Debug("applied pause plan")
+
+		msg := PauseAppliedMsg{Plan: plan, Applied: applied}
+		if len(errs) > 0 {
+			msg.Error = errors.New(strings.Join(errs, "; "))
+		}
+
+		return msg
+	}
+}
+
+func applyPauseChange(team concourse.Team, change PauseChange) (err error) {
+	var found bool
+
+	switch {
+	case change.Job != "" && change.Pause:
+		found, err = team.PauseJob(change.Pipeline, change.Job)
+	case change.Job != "":
+		found, err = team.UnpauseJob(change.Pipeline, change.Job)
+	case change.Pause:
+		found, err = team.PausePipeline(change.Pipeline)
+	default:
+		found, err = team.UnpausePipeline(change.Pipeline)
+	}
+
+	if err == nil && !found {
+		err = errors.New("not found")
+	}
+
+	return err
+}
+
+// snapshotPaused returns the current paused state of all pipelines and jobs on
+// the active target, optionally filtered to a single team. Archived pipelines
+// are skipped, as they can't be unpaused.
+func (c *apiManager) snapshotPaused(team string) (PauseSnapshot, error) {
+	client := c.Active().Client()
+
+	snapshot := PauseSnapshot{
+		Target:    c.ActiveName(),
+		URL:       c.Active().URL(),
+		Team:      team,
+		CreatedAt: time.Now(),
+	}
+
+	pipelines, err := client.ListPipelines()
+	if err != nil {
+		return snapshot, err
+	}
+
+	for _, p := range pipelines {
+		if p.Archived || (team != "" && p.TeamName != team) {
+			continue
+		}
+
+		ps := PipelineSnapshot{
+			Team:         p.TeamName,
+			Name:         p.Name,
+			InstanceVars: p.InstanceVars,
+			Paused:       p.Paused,
+		}
+
+		jobs, err := client.Team(p.TeamName).ListJobs(p.Ref())
+		if err != nil {
+			return snapshot, fmt.Errorf("failed to list jobs of %s/%s: %w", p.TeamName, p.Ref().String(), err)
+		}
+
+		for _, j := range jobs {
+			ps.Jobs = append(ps.Jobs, JobSnapshot{Name: j.Name, Paused: j.Paused})
+		}
+
+		snapshot.Pipelines = append(snapshot.Pipelines, ps)
+	}
+
+	return snapshot, nil
+}
+
+func findPipelineSnapshot(pipelines []PipelineSnapshot, p PipelineSnapshot) (PipelineSnapshot, bool) {
+	for _, cur := range pipelines {
+		if cur.Team == p.Team && cur.Ref().String() == p.Ref().String() {
+			return cur, true
+		}
+	}
+	return PipelineSnapshot{}, false
+}
+
+func writeSnapshot(path string, snapshot PauseSnapshot) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(snapshot, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b, 0o600)
+}
@@ -0,0 +1,190 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/concourse/concourse/atc"
+	"github.com/lrstanley/hangar-ui/internal/fakeatc"
+)
+
+func TestLatestSnapshot(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	dir, err := SnapshotDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{
+		"prod-20220101-100000.json",
+		"prod-20220102-100000.json",
+		"prod-eu-20220103-100000.json", // Another target sharing the prefix.
+		"prod-notes.json",
+	} {
+		writeFile(t, filepath.Join(dir, name), "{}")
+	}
+
+	path, err := LatestSnapshot("prod")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := filepath.Join(dir, "prod-20220102-100000.json"); path != want {
+		t.Errorf("expected %s, got %s", want, path)
+	}
+
+	if _, err = LatestSnapshot("staging"); err == nil {
+		t.Error("expected an error for a target without snapshots")
+	}
+}
+
+func TestLatestSnapshotNoDir(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	if _, err := LatestSnapshot("prod"); err == nil || os.IsNotExist(err) {
+		t.Errorf("expected a missing snapshot error, got %v", err)
+	}
+}
+
+// pausedState returns the paused state of all pipelines and jobs of the fake
+// ATC, as "pipeline" or "pipeline/job".
+func pausedState(srv *fakeatc.Server) (paused []string) {
+	srv.Update(func(state *fakeatc.State) {
+		for _, p := range state.Pipelines {
+			if p.Paused {
+				paused = append(paused, p.Name)
+			}
+
+			for _, j := range state.Jobs[fakeatc.PipelineKey(p.TeamName, p.Ref())] {
+				if j.Paused {
+					paused = append(paused, p.Name+"/"+j.Name)
+				}
+			}
+		}
+	})
+
+	return paused
+}
+
+func newPauseState() *fakeatc.State {
+	state := fakeatc.NewState()
+	state.AddPipeline(atc.Pipeline{Name: "app"}, []atc.Job{{Name: "build"}, {Name: "deploy", Paused: true}}, nil)
+	state.AddPipeline(atc.Pipeline{Name: "infra", Paused: true}, []atc.Job{{Name: "apply"}}, nil)
+	state.AddPipeline(atc.Pipeline{Name: "web"}, []atc.Job{{Name: "build"}}, nil)
+
+	return state
+}
+
+func TestPauseAllRestore(t *testing.T) {
+	c, srv := newTestClient(t, newPauseState())
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	plan := c.PlanPauseAll("", path)().(PausePlanMsg)
+	if plan.Error != nil {
+		t.Fatal(plan.Error)
+	}
+
+	if len(plan.Changes) != 2 {
+		t.Fatalf("expected app and web to be paused, got %v", plan.Changes)
+	}
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("expected the snapshot not to be written before the plan is applied")
+	}
+
+	applied := c.ApplyPausePlan(plan)().(PauseAppliedMsg)
+	if applied.Error != nil || applied.Applied != 2 {
+		t.Fatalf("expected 2 changes to be applied, got %d (%v)", applied.Applied, applied.Error)
+	}
+
+	if want := []string{"app", "app/deploy", "infra", "web"}; !reflect.DeepEqual(pausedState(srv), want) {
+		t.Errorf("expected %v to be paused, got %v", want, pausedState(srv))
+	}
+
+	// Pause a job while everything is paused, which should also be restored.
+	srv.Update(func(state *fakeatc.State) {
+		state.Jobs[fakeatc.PipelineKey(atc.DefaultTeamName, atc.PipelineRef{Name: "app"})][0].Paused = true
+	})
+
+	plan = c.PlanRestorePaused(path)().(PausePlanMsg)
+	if plan.Error != nil {
+		t.Fatal(plan.Error)
+	}
+
+	var changes []string
+	for _, change := range plan.Changes {
+		changes = append(changes, change.String())
+	}
+
+	if want := []string{"unpause pipeline main/app", "unpause job main/app/build", "unpause pipeline main/web"}; !reflect.DeepEqual(changes, want) {
+		t.Errorf("expected changes %v, got %v", want, changes)
+	}
+
+	applied = c.ApplyPausePlan(plan)().(PauseAppliedMsg)
+	if applied.Error != nil || applied.Applied != 3 {
+		t.Fatalf("expected 3 changes to be applied, got %d (%v)", applied.Applied, applied.Error)
+	}
+
+	// Only what was paused before the bulk pause is still paused.
+	if want := []string{"app/deploy", "infra"}; !reflect.DeepEqual(pausedState(srv), want) {
+		t.Errorf("expected %v to be paused, got %v", want, pausedState(srv))
+	}
+}
+
+func TestPlanRestorePausedOtherTarget(t *testing.T) {
+	c, _ := newTestClient(t, newPauseState())
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	writeFile(t, path, `{"target": "other", "url": "https://other.example.com", "pipelines": []}`)
+
+	if plan := c.PlanRestorePaused(path)().(PausePlanMsg); plan.Error == nil {
+		t.Error("expected an error restoring a snapshot of another target")
+	}
+
+	writeFile(t, path, `not json`)
+
+	if plan := c.PlanRestorePaused(path)().(PausePlanMsg); plan.Error == nil {
+		t.Error("expected an error restoring an invalid snapshot")
+	}
+}
+
+func TestApplyPausePlanPartialFailure(t *testing.T) {
+	c, srv := newTestClient(t, newPauseState())
+
+	plan := c.PlanPauseAll("", filepath.Join(t.TempDir(), "snapshot.json"))().(PausePlanMsg)
+	if plan.Error != nil {
+		t.Fatal(plan.Error)
+	}
+
+	// The app pipeline is deleted after planning.
+	srv.Update(func(state *fakeatc.State) {
+		state.Pipelines = state.Pipelines[1:]
+	})
+
+	applied := c.ApplyPausePlan(plan)().(PauseAppliedMsg)
+	if applied.Applied != 1 {
+		t.Errorf("expected the other change to be applied, got %d", applied.Applied)
+	}
+
+	if applied.Error == nil || !strings.Contains(applied.Error.Error(), "pause pipeline main/app: not found") {
+		t.Errorf("expected the failed change to be reported, got %v", applied.Error)
+	}
+
+	if want := []string{"infra", "web"}; !reflect.DeepEqual(pausedState(srv), want) {
+		t.Errorf("expected %v to be paused, got %v", want, pausedState(srv))
+	}
+
+	if _, err := os.Stat(plan.Path); err != nil {
+		t.Errorf("expected the snapshot to be written: %v", err)
+	}
+}
@@ -5,25 +5,161 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/apex/log"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/concourse/concourse/atc"
+	"github.com/concourse/concourse/fly/rc"
 )
 
 type PipelineListMsg struct {
 	Pipelines []atc.Pipeline
 	Error     error
+
+	// Stale is set to when the pipelines were fetched, if they are from an
+	// offline snapshot.
+	Stale time.Time
 }
 
+// QueryPipelines queries the pipelines of the active target, and returns
+// api.PipelineListMsg. The query is canceled when the visible view changes.
 func (c *apiManager) QueryPipelines() tea.Msg {
 	defer c.Loading("fetching pipelines")()
 
-	p, err := c.Active().Client().ListPipelines()
+	ctx := c.viewContext()
+	client, _ := contextClient(ctx, c.Client(), 0)
+
+	p, err := client.ListPipelines()
+	if ctx.Err() != nil {
+		// The view was left, so the pipelines are no longer needed (and
+		// shouldn't be replaced with the snapshot).
+		return nil
+	}
+	err = c.checkAuth(c.ActiveName(), "pipelines", err, c.QueryPipelines)
+
+	msg := PipelineListMsg{Pipelines: p, Error: err}
+	msg.Stale = c.snapshot(c.ActiveName(), PollPipelines, err, &msg.Pipelines)
 
 	c.logger.WithFields(log.Fields{
-		"pipelines": len(p),
+		"pipelines": len(msg.Pipelines),
+		"stale":     msg.Stale,
 		"error":     err,
 	}).Debug("queried pipeline list")
 
-	return PipelineListMsg{Pipelines: p, Error: err}
+	return msg
+}
+
+// TargetPipelines are the pipelines of a single target, as queried by
+// QueryAllPipelines.
+type TargetPipelines struct {
+	Target    string
+	Pipelines []atc.Pipeline
+	Error     error
+
+	// Stale is set to when the pipelines were fetched, if they are from an
+	// offline snapshot.
+	Stale time.Time
+}
+
+// AllPipelinesMsg contains the pipelines of multiple targets, sorted by target
+// name.
+type AllPipelinesMsg []TargetPipelines
+
+// QueryAllPipelines queries the pipelines of the provided targets (or all
+// targets, if none are provided) concurrently, each with their own deadline,
+// and returns api.AllPipelinesMsg. Queries are canceled when the visible view
+// changes.
+func (c *apiManager) QueryAllPipelines(targets []string) tea.Cmd {
+	return func() tea.Msg {
+		defer c.Loading("fetching pipelines of all targets")()
+
+		ctx := c.viewContext()
+
+		names := targets
+		if len(names) == 0 {
+			names = c.TargetNames()
+		}
+
+		var (
+			wg  sync.WaitGroup
+			msg = make(AllPipelinesMsg, len(names))
+			sem = make(chan struct{}, targetInfoWorkers)
+		)
+
+		for i, name := range names {
+			wg.Add(1)
+
+			go func(i int, name string) {
+				defer wg.Done()
+
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				msg[i] = c.queryTargetPipelines(ctx, name, targets)
+			}(i, name)
+		}
+
+		wg.Wait()
+
+		sort.Slice(msg, func(i, j int) bool {
+			return msg[i].Target < msg[j].Target
+		})
+
+		return msg
+	}
+}
+
+// queryTargetPipelines queries the pipelines of a single target, for
+// QueryAllPipelines.
+func (c *apiManager) queryTargetPipelines(ctx context.Context, name string, targets []string) (result TargetPipelines) {
+	result.Target = name
+
+	t, err := c.loadTarget(rc.TargetName(name))
+	if err != nil {
+		result.Error = err
+		return result
+	}
+
+	// Use a copy of the HTTP client with a deadline, so a single unreachable
+	// target doesn't hold up all others.
+	timeout := c.targetTimeout(name)
+	client, _ := contextClient(ctx, t.Client(), timeout)
+
+	result.Pipelines, err = client.ListPipelines()
+	if ctx.Err() != nil {
+		result.Error = ctx.Err()
+		return result
+	}
+
+	err = c.checkAuth(name, "all-pipelines", err, c.QueryAllPipelines(targets))
+
+	result.Stale = c.snapshot(name, PollPipelines, err, &result.Pipelines)
+	_, result.Error = targetStatus(err, timeout)
+
+	c.logger.WithFields(log.Fields{
+		"target":    name,
+		"pipelines": len(result.Pipelines),
+		"stale":     result.Stale,
+		"error":     err,
+	}).Debug("queried target pipelines")
+
+	return result
+}
+
+// ParseRef parses a "<pipeline>/<name>" reference, where name is a job or
+// resource within the pipeline (the same format fly uses for --job,
+// --resource and --inputs-from).
+func ParseRef(ref string) (pipeline atc.PipelineRef, name string, err error) {
+	idx := strings.LastIndex(ref, "/")
+	if idx < 1 || idx == len(ref)-1 {
+		return pipeline, "", errors.New("reference should be in the format <pipeline>/<name>")
+	}
+
+	return atc.PipelineRef{Name: ref[:idx]}, ref[idx+1:], nil
 }
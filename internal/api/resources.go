@@ -0,0 +1,45 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/concourse/concourse/atc"
+	"github.com/concourse/concourse/go-concourse/concourse"
+)
+
+// FormatVersion returns a stable, human readable representation of a resource
+// version (e.g. "ref:abc123,branch:main"), sorted by key.
+func FormatVersion(version atc.Version) string {
+	keys := make([]string, 0, len(version))
+	for k := range version {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+":"+version[k])
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// resourceVersionID looks up the ID of the provided resource version.
+func resourceVersionID(team concourse.Team, pipeline atc.PipelineRef, resource string, version atc.Version) (int, error) {
+	versions, _, found, err := team.ResourceVersions(pipeline, resource, concourse.Page{Limit: 1}, version)
+	if err != nil {
+		return 0, err
+	}
+
+	if !found || len(versions) == 0 {
+		return 0, fmt.Errorf("version %s of resource %q not found", FormatVersion(version), resource)
+	}
+
+	return versions[0].ID, nil
+}
@@ -0,0 +1,352 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+const (
+	// PollPipelines is the poll key of the pipelines list.
+	PollPipelines = "pipelines"
+
+	// PollTargetInfo is the poll key of the target health queries.
+	PollTargetInfo = "target-info"
+
+	// PollRequests is the poll key of the request inspector.
+	PollRequests = "requests"
+)
+
+const (
+	// maxPollBackoff is the max interval between polls of a failing query.
+	maxPollBackoff = 5 * time.Minute
+
+	// pollJitter is the max fraction of the interval added or removed, when
+	// backing off.
+	pollJitter = 0.2
+
+	// idlePollWait is how long the scheduler waits when there are no polls
+	// due (it's woken up early when anything changes).
+	idlePollWait = time.Minute
+)
+
+// poll is a query which is periodically refreshed by the scheduler, while the
+// view it belongs to is visible.
+type poll struct {
+	key      string
+	view     types.Viewable
+	interval time.Duration
+	query    tea.Cmd
+
+	next     time.Time
+	failures int
+	running  bool
+}
+
+// scheduler owns the polling of all queries. Polls only run while their view
+// is visible, back off exponentially when failing, and are never run more than
+// once at a time. Polls still running when their view is hidden are canceled.
+type scheduler struct {
+	mu      sync.Mutex
+	polls   map[string]*poll
+	visible types.Viewable
+	wake    chan struct{}
+
+	// viewCtx is canceled when the visible view changes.
+	viewCtx    context.Context
+	viewCancel context.CancelFunc
+
+	// owned are the contexts of one-off requests of views, see ownedContext.
+	owned map[types.Viewable]ownedContext
+}
+
+// ownedContext is the context of the one-off requests started by a view (e.g.
+// queries and build streams), which is canceled once the view is left.
+type ownedContext struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		polls:   map[string]*poll{},
+		owned:   map[types.Viewable]ownedContext{},
+		visible: types.ViewRoot,
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// notify wakes up the scheduler, to re-evaluate which polls are due.
+func (s *scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Poll registers (or updates) a query which is run every interval while view
+// is visible. Results are sent like any other async message. When a key is
+// registered again, its state (e.g. a run still in flight, or its backoff) is
+// kept, and the new query is used from the next run on.
+func (c *apiManager) Poll(view types.Viewable, key string, interval time.Duration, query tea.Cmd) {
+	c.sched.mu.Lock()
+	if p, ok := c.sched.polls[key]; ok {
+		p.view = view
+		p.query = query
+
+		// Don't wait for the previous interval, if the new one is shorter.
+		if next := time.Now().Add(interval); p.failures == 0 && next.Before(p.next) {
+			p.next = next
+		}
+
+		p.interval = interval
+	} else {
+		c.sched.polls[key] = &poll{
+			key:      key,
+			view:     view,
+			interval: interval,
+			query:    query,
+		}
+	}
+	c.sched.mu.Unlock()
+
+	c.sched.notify()
+}
+
+// SetVisible sets the view which is currently visible. Polls of the view are
+// run immediately, and polls of all other views are paused, canceling any
+// requests they have in flight.
+func (c *apiManager) SetVisible(view types.Viewable) {
+	c.sched.mu.Lock()
+	if c.sched.visible != view {
+		if owned, ok := c.sched.owned[c.sched.visible]; ok {
+			owned.cancel()
+			delete(c.sched.owned, c.sched.visible)
+		}
+
+		c.sched.visible = view
+
+		c.sched.viewCancel()
+		c.sched.viewCtx, c.sched.viewCancel = context.WithCancel(c.ctx)
+
+		for _, p := range c.sched.polls {
+			if p.view == view && p.failures == 0 {
+				p.next = time.Time{}
+			}
+		}
+	}
+	c.sched.mu.Unlock()
+
+	c.sched.notify()
+}
+
+// Refresh runs the poll with the provided key immediately (unless it's already
+// running), resetting any backoff.
+func (c *apiManager) Refresh(key string) {
+	c.sched.mu.Lock()
+	if p, ok := c.sched.polls[key]; ok {
+		p.next = time.Time{}
+		p.failures = 0
+
+		if !p.running {
+			c.runPoll(p)
+		}
+	}
+	c.sched.mu.Unlock()
+}
+
+// viewContext returns a context which is canceled once the visible view
+// changes, or the client is closed. Polled queries use it, so they are
+// canceled when their view is left.
+func (c *apiManager) viewContext() context.Context {
+	c.sched.mu.Lock()
+	defer c.sched.mu.Unlock()
+
+	return c.sched.viewCtx
+}
+
+// viewContextOf returns the context of the one-off requests of view, which is
+// canceled once view is left (or the client is closed). Unlike viewContext, it
+// can be used before view is visible (e.g. when a query is started along with
+// switching to the view), and is only canceled once view was visible.
+func (c *apiManager) viewContextOf(view types.Viewable) context.Context {
+	c.sched.mu.Lock()
+	defer c.sched.mu.Unlock()
+
+	owned, ok := c.sched.owned[view]
+	if !ok {
+		owned.ctx, owned.cancel = context.WithCancel(c.ctx)
+		c.sched.owned[view] = owned
+	}
+
+	return owned.ctx
+}
+
+// Scheduler is a background worker which runs all polls when they are due.
+func (c *apiManager) Scheduler() {
+	defer c.wg.Done()
+
+	for {
+		timer := time.NewTimer(c.runDuePolls())
+
+		select {
+		case <-c.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-c.sched.wake:
+			timer.Stop()
+		}
+	}
+}
+
+// runDuePolls runs all polls of the visible view which are due, and returns
+// how long to wait until the next one is due.
+func (c *apiManager) runDuePolls() (wait time.Duration) {
+	c.sched.mu.Lock()
+	defer c.sched.mu.Unlock()
+
+	now := time.Now()
+	wait = idlePollWait
+
+	for _, p := range c.sched.polls {
+		if p.view != c.sched.visible || p.running {
+			continue
+		}
+
+		if !p.next.After(now) {
+			c.runPoll(p)
+			continue
+		}
+
+		if until := p.next.Sub(now); until < wait {
+			wait = until
+		}
+	}
+
+	return wait
+}
+
+// runPoll runs the query of p in the background. Must be called with the
+// scheduler lock held.
+func (c *apiManager) runPoll(p *poll) {
+	if c.ctx.Err() != nil {
+		return
+	}
+
+	p.running = true
+	ctx, query := c.sched.viewCtx, p.query
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		msg := query()
+
+		// The view was left (or the client closed) while the query was
+		// running, so the result is outdated, and likely a cancellation
+		// error. Run it again once the view is visible again.
+		if ctx.Err() != nil {
+			c.sched.mu.Lock()
+			p.running = false
+			p.next = time.Time{}
+			c.sched.mu.Unlock()
+
+			c.sched.notify()
+			return
+		}
+
+		if msg != nil {
+			c.bus.Publish(types.TopicQuery, msg)
+		}
+
+		err := pollError(msg)
+
+		c.sched.mu.Lock()
+		p.running = false
+
+		if err != nil {
+			p.failures++
+		} else {
+			p.failures = 0
+		}
+
+		p.next = time.Now().Add(pollBackoff(p.interval, p.failures))
+		failures, next := p.failures, p.next
+		c.sched.mu.Unlock()
+
+		if err != nil {
+			c.logger.WithFields(log.Fields{
+				"poll":     p.key,
+				"failures": failures,
+				"next":     time.Until(next).Round(time.Second),
+			}).WithError(err).Debug("poll failed, backing off")
+		}
+
+		c.sched.notify()
+	}()
+}
+
+// pollBackoff returns the interval until the next poll, backing off
+// exponentially (with jitter) after failures.
+func pollBackoff(interval time.Duration, failures int) time.Duration {
+	if failures == 0 {
+		return interval
+	}
+
+	backoff := interval
+	for i := 0; i < failures && backoff < maxPollBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxPollBackoff {
+		backoff = maxPollBackoff
+	}
+
+	jitter := time.Duration((rand.Float64()*2 - 1) * pollJitter * float64(backoff))
+	return backoff + jitter
+}
+
+// pollError returns the error of the result of a poll, if any.
+func pollError(msg tea.Msg) error {
+	switch msg := msg.(type) {
+	case PipelineListMsg:
+		return msg.Error
+	case AllPipelinesMsg:
+		// Only back off if all targets are failing.
+		var err error
+		for _, result := range msg {
+			if result.Error == nil {
+				return nil
+			}
+
+			err = result.Error
+		}
+
+		return err
+	case TargetInfoMsg:
+		// Same as above, one unreachable target shouldn't slow down the
+		// others.
+		var err error
+		for _, info := range msg {
+			if info.Error == nil {
+				return nil
+			}
+
+			err = info.Error
+		}
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/lrstanley/hangar-ui/internal/fakeatc"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+func TestPollError(t *testing.T) {
+	failed := errors.New("unreachable")
+
+	tests := []struct {
+		name    string
+		msg     tea.Msg
+		wantErr bool
+	}{
+		{"pipelines", PipelineListMsg{}, false},
+		{"pipelines failed", PipelineListMsg{Error: failed}, true},
+		{"all pipelines, one failed", AllPipelinesMsg{{Error: failed}, {}}, false},
+		{"all pipelines failed", AllPipelinesMsg{{Error: failed}, {Error: failed}}, true},
+		{"target info, one failed", TargetInfoMsg{{Error: failed}, {}}, false},
+		{"target info failed", TargetInfoMsg{{Error: failed}, {Error: failed}}, true},
+		{"no targets", TargetInfoMsg{}, false},
+		{"other", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := pollError(tt.msg); (err != nil) != tt.wantErr {
+				t.Errorf("expected error: %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestViewContextOf(t *testing.T) {
+	c, _ := newTestClient(t, fakeatc.NewState())
+
+	// Started before switching to the view.
+	ctx := c.viewContextOf(types.ViewTrigger)
+
+	c.SetVisible(types.ViewTrigger)
+	if ctx.Err() != nil {
+		t.Fatal("expected the context to be active while the view is visible")
+	}
+
+	if c.viewContextOf(types.ViewTrigger) != ctx {
+		t.Error("expected the view to keep its context while visible")
+	}
+
+	c.SetVisible(types.ViewRoot)
+	if ctx.Err() == nil {
+		t.Error("expected the context to be canceled once the view was left")
+	}
+
+	if c.viewContextOf(types.ViewTrigger).Err() != nil {
+		t.Error("expected a new context for the view")
+	}
+}
+
+func TestPollReregister(t *testing.T) {
+	c, _ := newTestClient(t, fakeatc.NewState())
+
+	const view types.Viewable = "test"
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	ran := make(chan struct{}, 10)
+
+	c.Poll(view, "test", time.Hour, func() tea.Msg {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	c.SetVisible(view)
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the poll to run")
+	}
+
+	// Registering the key again while the first query is still running
+	// shouldn't start the new query alongside it.
+	c.Poll(view, "test", time.Hour, func() tea.Msg {
+		ran <- struct{}{}
+		return nil
+	})
+
+	select {
+	case <-ran:
+		t.Fatal("expected the new query to wait for the running one")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+
+	// The new query is used from the next run on.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.sched.mu.Lock()
+		running := c.sched.polls["test"].running
+		c.sched.mu.Unlock()
+
+		if !running {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the first query to finish")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c.Refresh("test")
+
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the new query to run")
+	}
+
+	select {
+	case <-started:
+		t.Error("expected the old query to be replaced")
+	default:
+	}
+}
@@ -0,0 +1,307 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+// snapshotStore persists the last successful results of queries to disk, per
+// target, so they can be shown on startup, and while the ATC is unreachable.
+type snapshotStore struct {
+	dir string // Empty if snapshots are disabled.
+
+	mu    sync.Mutex
+	saved map[string][sha256.Size]byte // Checksums of the saved data, by path.
+}
+
+type snapshot struct {
+	SavedAt time.Time       `json:"saved_at"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// newSnapshotStore returns a snapshotStore which stores snapshots in dir, or in
+// the user cache directory if dir is empty.
+func newSnapshotStore(dir string) (*snapshotStore, error) {
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return &snapshotStore{}, err
+		}
+
+		dir = filepath.Join(base, "hangar-ui", "snapshots")
+	}
+
+	return &snapshotStore{dir: dir}, nil
+}
+
+// targetDir returns the directory of the snapshots of target. Target names are
+// hex encoded, so they can never be a special segment (like ".."), or contain
+// separators, which would escape the snapshot directory.
+func (s *snapshotStore) targetDir(target string) (string, error) {
+	dir := filepath.Join(s.dir, hex.EncodeToString([]byte(target)))
+	if dir == filepath.Clean(s.dir) || !withinDir(s.dir, dir) {
+		return "", fmt.Errorf("invalid snapshot directory for target %q", target)
+	}
+
+	return dir, nil
+}
+
+func (s *snapshotStore) path(target, key string) (string, error) {
+	dir, err := s.targetDir(target)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, key+".json")
+	if filepath.Dir(path) != dir {
+		return "", fmt.Errorf("invalid snapshot key %q", key)
+	}
+
+	return path, nil
+}
+
+// save stores v as the snapshot of key for the provided target. If v hasn't
+// changed since it was last saved, only the modification time of the snapshot
+// is updated, rather than rewriting it on every poll.
+func (s *snapshotStore) save(target, key string, v any) error {
+	if s.dir == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	path, err := s.path(target, key)
+	if err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(data)
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if previous, ok := s.saved[path]; ok && previous == sum {
+		if err = os.Chtimes(path, now, now); err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	b, err := json.Marshal(snapshot{SavedAt: now, Data: data})
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, so a partially written snapshot is
+	// never loaded.
+	if err = os.WriteFile(path+".tmp", b, 0o600); err != nil {
+		return err
+	}
+
+	if err = os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	if s.saved == nil {
+		s.saved = map[string][sha256.Size]byte{}
+	}
+
+	s.saved[path] = sum
+	return nil
+}
+
+// load loads the snapshot of key for the provided target into v, and returns
+// when it was last saved.
+func (s *snapshotStore) load(target, key string, v any) (savedAt time.Time, err error) {
+	if s.dir == "" {
+		return savedAt, fs.ErrNotExist
+	}
+
+	path, err := s.path(target, key)
+	if err != nil {
+		return savedAt, err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return savedAt, err
+	}
+
+	var snap snapshot
+
+	if err = json.Unmarshal(b, &snap); err != nil {
+		return savedAt, err
+	}
+
+	if err = json.Unmarshal(snap.Data, v); err != nil {
+		return savedAt, err
+	}
+
+	// Unchanged snapshots aren't rewritten, see save.
+	savedAt = snap.SavedAt
+	if fi, serr := os.Stat(path); serr == nil && fi.ModTime().After(savedAt) {
+		savedAt = fi.ModTime()
+	}
+
+	return savedAt, nil
+}
+
+// remove removes all snapshots of the provided target.
+func (s *snapshotStore) remove(target string) error {
+	if s.dir == "" {
+		return nil
+	}
+
+	dir, err := s.targetDir(target)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	for path := range s.saved {
+		if filepath.Dir(path) == dir {
+			delete(s.saved, path)
+		}
+	}
+	s.mu.Unlock()
+
+	return os.RemoveAll(dir)
+}
+
+// snapshot saves v as the snapshot of key for target if err is nil (i.e. the
+// query succeeded). If the target is unreachable, the last snapshot is loaded
+// into v instead. Returns when the loaded snapshot was saved, or a zero time
+// if v is live (or the query failed for another reason).
+func (c *apiManager) snapshot(target, key string, err error, v any) (stale time.Time) {
+	if err == nil {
+		if err = c.snapshots.save(target, key, v); err != nil {
+			c.logger.WithError(err).WithField("target", target).Warn("failed to save snapshot")
+		}
+
+		c.setStale(target, key, time.Time{}, true)
+		return stale
+	}
+
+	// The ATC responded, so the error should be shown rather than hidden behind
+	// old data.
+	if !isUnreachable(err) {
+		c.setStale(target, key, time.Time{}, true)
+		return stale
+	}
+
+	stale, err = c.snapshots.load(target, key, v)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			c.logger.WithError(err).WithField("target", target).Warn("failed to load snapshot")
+		}
+
+		return time.Time{}
+	}
+
+	c.setStale(target, key, stale, true)
+	return stale
+}
+
+// staleKey identifies a snapshot of a target, see apiManager.stale.
+type staleKey struct {
+	target string
+	key    string
+}
+
+// StaleSince returns when the data shown for the provided target was last
+// fetched, if any of it is from an offline snapshot (otherwise a zero time).
+// If multiple snapshots are shown, the oldest is returned.
+func (c *apiManager) StaleSince(target string) (since time.Time) {
+	c.staleMu.Lock()
+	defer c.staleMu.Unlock()
+
+	for k, t := range c.stale {
+		if k.target == target && !t.IsZero() && (since.IsZero() || t.Before(since)) {
+			since = t
+		}
+	}
+
+	return since
+}
+
+// setStale sets when the data of key for target was last fetched, if it's
+// from a snapshot (or a zero time if it's live). If force is false, it's only
+// set if it hasn't been queried yet.
+func (c *apiManager) setStale(target, key string, since time.Time, force bool) {
+	k := staleKey{target: target, key: key}
+
+	c.staleMu.Lock()
+
+	previous, ok := c.stale[k]
+	if ok && (!force || previous.Equal(since)) {
+		c.staleMu.Unlock()
+		return
+	}
+
+	c.stale[k] = since
+	c.staleMu.Unlock()
+
+	c.logger.WithFields(log.Fields{
+		"target": target,
+		"key":    key,
+		"since":  since,
+	}).Debug("target stale state changed")
+
+	c.bus.Publish(types.TopicState, types.FlyStaleUpdated)
+}
+
+// forgetStale removes the stale state of all snapshots of target.
+func (c *apiManager) forgetStale(target string) {
+	c.staleMu.Lock()
+	defer c.staleMu.Unlock()
+
+	for k := range c.stale {
+		if k.target == target {
+			delete(c.stale, k)
+		}
+	}
+}
+
+// LoadPipelinesSnapshot returns the last snapshot of the pipelines of the active
+// target as api.PipelineListMsg (if any), to show until they are queried.
+func (c *apiManager) LoadPipelinesSnapshot() tea.Msg {
+	target := c.ActiveName()
+	if target == "" {
+		return nil
+	}
+
+	var msg PipelineListMsg
+
+	stale, err := c.snapshots.load(target, PollPipelines, &msg.Pipelines)
+	if err != nil {
+		return nil
+	}
+
+	msg.Stale = stale
+	c.setStale(target, PollPipelines, stale, false)
+
+	return msg
+}
@@ -0,0 +1,178 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+func newSnapshotClient(t *testing.T) *apiManager {
+	return &apiManager{
+		logger:    log.Log,
+		bus:       types.NewEventBus(types.DefaultPolicies),
+		stale:     map[staleKey]time.Time{},
+		snapshots: &snapshotStore{dir: t.TempDir()},
+	}
+}
+
+func TestSnapshotUnreachable(t *testing.T) {
+	c := newSnapshotClient(t)
+	unreachable := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+
+	live := []string{"app"}
+	if stale := c.snapshot("fake", "pipelines", nil, &live); !stale.IsZero() {
+		t.Fatalf("expected live data, got a snapshot from %s", stale)
+	}
+
+	var loaded []string
+	if stale := c.snapshot("fake", "pipelines", errors.New("internal server error"), &loaded); !stale.IsZero() || loaded != nil {
+		t.Errorf("expected no snapshot when the ATC responds with an error, got %v from %s", loaded, stale)
+	}
+
+	stale := c.snapshot("fake", "pipelines", unreachable, &loaded)
+	if stale.IsZero() || len(loaded) != 1 || loaded[0] != "app" {
+		t.Fatalf("expected the snapshot when unreachable, got %v from %s", loaded, stale)
+	}
+
+	if since := c.StaleSince("fake"); !since.Equal(stale) {
+		t.Errorf("expected target to be stale since %s, got %s", stale, since)
+	}
+}
+
+func TestStaleSinceOldest(t *testing.T) {
+	c := newSnapshotClient(t)
+
+	older := time.Now().Add(-time.Hour)
+	newer := time.Now()
+
+	c.setStale("fake", "info", newer, true)
+	c.setStale("fake", "pipelines", older, true)
+	c.setStale("other", "pipelines", older.Add(-time.Hour), true)
+
+	if since := c.StaleSince("fake"); !since.Equal(older) {
+		t.Errorf("expected the oldest snapshot (%s), got %s", older, since)
+	}
+
+	// Other snapshots of the target are still stale, if one is live again.
+	c.setStale("fake", "info", time.Time{}, true)
+	if since := c.StaleSince("fake"); !since.Equal(older) {
+		t.Errorf("expected %s, got %s", older, since)
+	}
+
+	c.setStale("fake", "pipelines", time.Time{}, true)
+	if since := c.StaleSince("fake"); !since.IsZero() {
+		t.Errorf("expected target to be live, got %s", since)
+	}
+
+	c.forgetStale("other")
+	if since := c.StaleSince("other"); !since.IsZero() {
+		t.Errorf("expected forgotten target to be live, got %s", since)
+	}
+}
+
+func TestSnapshotStoreUnchanged(t *testing.T) {
+	s := &snapshotStore{dir: t.TempDir()}
+
+	if err := s.save("fake", "info", []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := s.path("fake", "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Backdate the snapshot, so updates are visible.
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.save("fake", "info", []string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(before) != string(after) {
+		t.Error("expected unchanged snapshot not to be rewritten")
+	}
+
+	var v []string
+
+	savedAt, err := s.load("fake", "info", &v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if time.Since(savedAt) > time.Minute {
+		t.Errorf("expected snapshot to be saved just now, got %s", savedAt)
+	}
+
+	if err = s.save("fake", "info", []string{"b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = s.load("fake", "info", &v); err != nil || len(v) != 1 || v[0] != "b" {
+		t.Errorf("expected changed snapshot to be saved, got %v (%v)", v, err)
+	}
+}
+
+func TestSnapshotStoreDotTargets(t *testing.T) {
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "snapshots")
+	s := &snapshotStore{dir: dir}
+
+	sibling := filepath.Join(parent, "keep")
+	writeFile(t, sibling, "keep")
+
+	for _, target := range []string{".", "..", "../..", "a/../..", "/"} {
+		if err := s.save(target, "info", []string{target}); err != nil {
+			t.Fatalf("target %q: unexpected error: %v", target, err)
+		}
+
+		path, err := s.path(target, "info")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if filepath.Dir(filepath.Dir(path)) != dir {
+			t.Errorf("target %q: expected the snapshot to be in %s, got %s", target, dir, path)
+		}
+
+		var v []string
+		if _, err = s.load(target, "info", &v); err != nil || len(v) != 1 || v[0] != target {
+			t.Errorf("target %q: expected the snapshot to be loaded, got %v (%v)", target, v, err)
+		}
+
+		if err = s.remove(target); err != nil {
+			t.Fatalf("target %q: unexpected error: %v", target, err)
+		}
+
+		if _, err = os.Stat(sibling); err != nil {
+			t.Fatalf("target %q: removing its snapshots removed files outside of them: %v", target, err)
+		}
+	}
+
+	if _, err := s.path("", "info"); err == nil {
+		t.Error("expected an error for an empty target")
+	}
+}
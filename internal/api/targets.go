@@ -5,51 +5,242 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
 	"github.com/apex/log"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/concourse/concourse/atc"
 	"github.com/concourse/concourse/fly/rc"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+const (
+	// targetInfoWorkers is the max number of targets queried concurrently.
+	targetInfoWorkers = 4
+
+	// targetInfoTimeout is how long a single target has to respond, before it's
+	// considered timed out, unless it has a timeout configured.
+	targetInfoTimeout = 5 * time.Second
+)
+
+// TargetStatus is the health of a target, as determined by QueryTargetInfo.
+type TargetStatus int
+
+const (
+	TargetPending TargetStatus = iota
+	TargetOK
+	TargetNeedsLogin
+	TargetTimedOut
+	TargetUnreachable
+	TargetError
 )
 
+func (s TargetStatus) String() string {
+	switch s {
+	case TargetPending:
+		return "pending"
+	case TargetOK:
+		return "ok"
+	case TargetNeedsLogin:
+		return "needs login"
+	case TargetTimedOut:
+		return "timed out"
+	case TargetUnreachable:
+		return "unreachable"
+	default:
+		return "error"
+	}
+}
+
 type TargetInfo struct {
 	TargetName string
-	Target     rc.Target
+	// Target is nil if the target couldn't be loaded, see Error.
+	Target rc.Target
+
+	Info    atc.Info
+	Status  TargetStatus
+	Latency time.Duration
+	Error   error
 
-	Info  atc.Info
-	Error error
+	// Stale is set to when Info was fetched, if it's from an offline snapshot.
+	Stale time.Time
+
+	// Warnings are version mismatches between hangar-ui, the target, and its
+	// workers.
+	Warnings []string
 }
 
+// TargetInfoMsg contains the results for all targets, once all of them have
+// been queried.
 type TargetInfoMsg []TargetInfo
 
+// TargetInfoUpdateMsg is sent as soon as a single target has been queried,
+// before the final TargetInfoMsg.
+type TargetInfoUpdateMsg TargetInfo
+
+// CoalesceKey implements types.Coalescer, so updates of different targets
+// don't replace each other.
+func (m TargetInfoUpdateMsg) CoalesceKey() string {
+	return "target-info/" + m.TargetName
+}
+
 // QueryTargetInfo queries all known targets for their status, and returns
-// api.TargetInfoMsg.
+// api.TargetInfoMsg. Targets are queried concurrently, each with their own
+// deadline, and individual results are sent as api.TargetInfoUpdateMsg.
+// Queries are canceled when the visible view changes.
 func (c *apiManager) QueryTargetInfo() tea.Msg {
 	defer c.Loading("fetching targets")()
 
-	msg := make(TargetInfoMsg, 0)
-	targets := c.Targets()
+	ctx := c.viewContext()
 
-	for name := range targets {
-		t, err := rc.LoadTarget(name, false)
-		if err != nil {
-			continue
-		}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		msg = make(TargetInfoMsg, 0)
+		sem = make(chan struct{}, targetInfoWorkers)
+	)
+
+	for name := range c.Targets() {
+		wg.Add(1)
+
+		go func(name rc.TargetName) {
+			defer wg.Done()
+
+			t, err := c.loadTarget(name)
+			if err != nil {
+				// Still show the target (e.g. with an invalid CA cert), so it
+				// can be fixed.
+				info := TargetInfo{TargetName: string(name), Status: TargetError, Error: err}
+				c.bus.Publish(types.TopicQuery, TargetInfoUpdateMsg(info))
+
+				mu.Lock()
+				msg = append(msg, info)
+				mu.Unlock()
+				return
+			}
+
+			// Show the target right away, even if it takes a while to respond
+			// (or to get its turn).
+			c.bus.Publish(types.TopicQuery, TargetInfoUpdateMsg(TargetInfo{
+				TargetName: string(name),
+				Target:     t,
+				Status:     TargetPending,
+			}))
 
-		info, err := t.Client().GetInfo()
+			sem <- struct{}{}
+			defer func() { <-sem }()
 
-		c.logger.WithFields(log.Fields{
-			"target": name,
-			"error":  err,
-			"info":   info,
-		}).Debug("queried target info")
+			info, ok := c.queryTargetInfo(ctx, name, t)
+			if !ok || ctx.Err() != nil {
+				return
+			}
 
-		msg = append(msg, TargetInfo{
-			TargetName: string(name),
-			Target:     t,
-			Info:       info,
-			Error:      err,
-		})
+			c.bus.Publish(types.TopicQuery, TargetInfoUpdateMsg(info))
+
+			mu.Lock()
+			msg = append(msg, info)
+			mu.Unlock()
+		}(name)
 	}
 
+	wg.Wait()
+
+	sort.Slice(msg, func(i, j int) bool {
+		return msg[i].TargetName < msg[j].TargetName
+	})
+
 	return msg
 }
+
+// queryTargetInfo queries a single target. Returns false if the query was
+// canceled.
+func (c *apiManager) queryTargetInfo(ctx context.Context, name rc.TargetName, t rc.Target) (info TargetInfo, ok bool) {
+	info = TargetInfo{TargetName: string(name), Target: t}
+
+	// Use a copy of the HTTP client with a deadline, as the target client is
+	// also used for long-running requests (e.g. build events).
+	timeout := c.targetTimeout(string(name))
+	deadline := time.Now().Add(timeout)
+	client, httpClient := contextClient(ctx, t.Client(), timeout)
+
+	var err error
+
+	start := time.Now()
+	info.Info, err = client.GetInfo()
+	info.Latency = time.Since(start)
+
+	if ctx.Err() != nil {
+		return info, false
+	}
+
+	info.Stale = c.snapshot(string(name), "info", err, &info.Info)
+
+	// The info endpoint doesn't require authentication, so also check that
+	// the token is still valid.
+	var workers []atc.Worker
+	if err == nil {
+		httpClient.Timeout = time.Until(deadline)
+
+		_, err = client.UserInfo()
+		err = c.checkAuth(string(name), "target-info", err, c.QueryTargetInfo)
+
+		if err == nil {
+			workers = listWorkers(client, deadline, httpClient)
+		}
+	}
+
+	info.Status, info.Error = targetStatus(err, timeout)
+	info.Warnings = c.updateCompat(string(name), info.Info, workers)
+
+	c.logger.WithFields(log.Fields{
+		"target":  name,
+		"status":  info.Status,
+		"latency": info.Latency,
+		"error":   err,
+		"info":    info.Info,
+	}).Debug("queried target info")
+
+	return info, true
+}
+
+// isUnreachable returns true if err means that the target couldn't be reached
+// (or didn't respond in time), rather than the request failing.
+func isUnreachable(err error) bool {
+	status, _ := targetStatus(err, 0)
+	return status == TargetUnreachable || status == TargetTimedOut
+}
+
+// targetStatus returns the status of a target, based on the error (if any)
+// returned when querying it with the provided timeout.
+func targetStatus(err error, timeout time.Duration) (TargetStatus, error) {
+	if err == nil {
+		return TargetOK, nil
+	}
+
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return TargetTimedOut, fmt.Errorf("timed out after %s", timeout)
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return TargetUnreachable, fmt.Errorf("unreachable: %w", opErr)
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return TargetUnreachable, fmt.Errorf("unreachable: %w", dnsErr)
+	}
+
+	if errors.As(err, &NeedsLoginError{}) {
+		return TargetNeedsLogin, err
+	}
+
+	return TargetError, err
+}
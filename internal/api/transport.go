@@ -0,0 +1,400 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/concourse/concourse/fly/rc"
+	"github.com/concourse/concourse/go-concourse/concourse"
+	"golang.org/x/oauth2"
+)
+
+// authTransport reports authentication failures (e.g. expired tokens) of any
+// request made to a target.
+type authTransport struct {
+	base           http.RoundTripper
+	onUnauthorized func()
+}
+
+func (t *authTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(r)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		t.onUnauthorized()
+	}
+
+	return resp, err
+}
+
+// contextTransport cancels requests along with ctx, in addition to their own
+// context. go-concourse doesn't accept contexts, so this is how requests made
+// through it are canceled. If busy is set, it's incremented until the response
+// headers are received.
+type contextTransport struct {
+	base http.RoundTripper
+	ctx  context.Context
+	busy *atomic.Int64
+}
+
+func (t *contextTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.busy != nil {
+		t.busy.Add(1)
+		defer t.busy.Add(-1)
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+
+	go func() {
+		select {
+		case <-t.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	resp, err := t.base.RoundTrip(r.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// The body is read after RoundTrip returns, so only release the context
+	// once it's closed.
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody cancels the context of its request once closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// contextClient returns a copy of client whose requests are canceled along
+// with ctx, and time out after timeout (unless zero). The HTTP client of the
+// copy is also returned, so the timeout can be adjusted between requests.
+func contextClient(ctx context.Context, client concourse.Client, timeout time.Duration) (concourse.Client, *http.Client) {
+	httpClient := *client.HTTPClient()
+	httpClient.Transport = &contextTransport{base: httpClient.Transport, ctx: ctx}
+	httpClient.Timeout = timeout
+
+	return concourse.NewClient(client.URL(), &httpClient, false), &httpClient
+}
+
+// streamTransport stops event streams from reconnecting once ctx is done.
+// go-sse retries failed connections forever, even if they were canceled, but
+// gives up on rejected ones, so failures after ctx is done are turned into a
+// rejection.
+type streamTransport struct {
+	base http.RoundTripper
+	ctx  context.Context
+}
+
+func (t *streamTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(r)
+	if err != nil && t.ctx.Err() != nil {
+		return &http.Response{
+			Status:     http.StatusText(http.StatusRequestTimeout),
+			StatusCode: http.StatusRequestTimeout,
+			Header:     http.Header{},
+			Body:       http.NoBody,
+			Request:    r,
+		}, nil
+	}
+
+	return resp, err
+}
+
+// streamClient returns a copy of client for event streams, which are canceled
+// (without reconnecting) along with ctx.
+func streamClient(ctx context.Context, client concourse.Client) concourse.Client {
+	httpClient := *client.HTTPClient()
+	httpClient.Transport = &streamTransport{
+		base: &contextTransport{base: httpClient.Transport, ctx: ctx},
+		ctx:  ctx,
+	}
+
+	return concourse.NewClient(client.URL(), &httpClient, false)
+}
+
+// loadTarget loads the provided target from the flyrc, like rc.LoadTarget, but
+// with a client which flags the target as needing login when its token is
+// rejected. Targets are only loaded once, until unloaded (see unloadTargets).
+func (c *apiManager) loadTarget(name rc.TargetName) (rc.Target, error) {
+	c.loadedMu.Lock()
+	t, ok := c.loaded[string(name)]
+	c.loadedMu.Unlock()
+
+	if ok {
+		return t, nil
+	}
+
+	props, err := c.loadTargetProps(name)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err = c.newTarget(name, props, true)
+	if err != nil {
+		return nil, err
+	}
+
+	c.loadedMu.Lock()
+	c.loaded[string(name)] = t
+	c.loadedMu.Unlock()
+
+	return t, nil
+}
+
+// unloadTargets drops the provided targets (or all if none are provided) which
+// were loaded by loadTarget, so they're loaded from the flyrc again when used
+// next.
+func (c *apiManager) unloadTargets(names ...string) {
+	c.loadedMu.Lock()
+	defer c.loadedMu.Unlock()
+
+	if len(names) == 0 {
+		c.loaded = map[string]rc.Target{}
+		return
+	}
+
+	for _, name := range names {
+		delete(c.loaded, name)
+	}
+}
+
+// loadUnauthenticatedTarget loads the provided target from the flyrc, without
+// its token, like rc.LoadUnauthenticatedTarget. Used when logging in.
+func (c *apiManager) loadUnauthenticatedTarget(name rc.TargetName) (rc.Target, error) {
+	props, err := c.loadTargetProps(name)
+	if err != nil {
+		return nil, err
+	}
+
+	props.Token = nil
+	return c.newTarget(name, props, false)
+}
+
+// loadTargets loads all targets from the flyrc, or from the cassette when
+// replaying.
+func (c *apiManager) loadTargets() (rc.Targets, error) {
+	if c.player != nil {
+		return c.player.targets, nil
+	}
+
+	return rc.LoadTargets()
+}
+
+func (c *apiManager) loadTargetProps(name rc.TargetName) (rc.TargetProps, error) {
+	targets, err := c.loadTargets()
+	if err != nil {
+		return rc.TargetProps{}, err
+	}
+
+	props, ok := targets[name]
+	if !ok {
+		return rc.TargetProps{}, rc.UnknownTargetError{TargetName: name}
+	}
+
+	return props, nil
+}
+
+// newTarget returns a target for the provided props, applying the transport
+// settings of the target from the config file (see TransportConfig). If
+// checkAuth is true, the target is flagged as needing login when a request is
+// rejected.
+func (c *apiManager) newTarget(name rc.TargetName, props rc.TargetProps, checkAuth bool) (rc.Target, error) {
+	if c.player != nil {
+		return c.newTargetWithTransport(name, props, checkAuth, nil, nil, &replayTransport{
+			target: string(name),
+			player: c.player,
+		}), nil
+	}
+
+	tc, err := c.transportConfig(string(name))
+	if err != nil {
+		return nil, err
+	}
+
+	caCert := props.CACert
+	if tc.CABundle != "" {
+		bundle, rerr := os.ReadFile(tc.CABundle)
+		if rerr != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", rerr)
+		}
+
+		caCert += "\n" + string(bundle)
+	}
+
+	caCertPool, err := loadCACertPool(caCert)
+	if err != nil {
+		return nil, err
+	}
+
+	// Client certificates from the config take precedence over the flyrc.
+	if tc.ClientCert != "" {
+		props.ClientCertPath, props.ClientKeyPath = tc.ClientCert, tc.ClientKey
+	}
+
+	clientCerts, err := loadClientCertificate(props.ClientCertPath, props.ClientKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if tc.proxyURL != nil {
+		proxy = http.ProxyURL(tc.proxyURL)
+	}
+
+	// The timeout only bounds connecting and waiting for the response headers,
+	// not reading the body, so streams (e.g. build events) can stay open.
+	// Queries are canceled along with their view instead, and health checks
+	// have an overall deadline (see contextClient).
+	connectTimeout := 10 * time.Second
+	if tc.timeout > 0 {
+		connectTimeout = tc.timeout
+	}
+
+	return c.newTargetWithTransport(name, props, checkAuth, caCertPool, clientCerts, &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: props.Insecure,
+			RootCAs:            caCertPool,
+			Certificates:       clientCerts,
+		},
+		DialContext:           (&net.Dialer{Timeout: connectTimeout}).DialContext,
+		TLSHandshakeTimeout:   connectTimeout,
+		Proxy:                 proxy,
+		ResponseHeaderTimeout: tc.timeout,
+		IdleConnTimeout:       90 * time.Second,
+	}), nil
+}
+
+// newTargetWithTransport returns a target for the provided props, making all
+// requests through the layers of transports on top of base (the network).
+func (c *apiManager) newTargetWithTransport(
+	name rc.TargetName,
+	props rc.TargetProps,
+	checkAuth bool,
+	caCertPool *x509.CertPool,
+	clientCerts []tls.Certificate,
+	base http.RoundTripper,
+) rc.Target {
+	var transport http.RoundTripper = &inspectTransport{
+		target: string(name),
+		base:   base,
+		log:    c.requests,
+	}
+
+	if props.Token != nil {
+		transport = &oauth2.Transport{
+			Source: oauth2.StaticTokenSource(&oauth2.Token{
+				TokenType:   props.Token.Type,
+				AccessToken: props.Token.Value,
+			}),
+			Base: transport,
+		}
+	}
+
+	transport = &cacheTransport{
+		target:   string(name),
+		identity: tokenIdentity(props.Token),
+		base:     transport,
+		cache:    c.cache,
+	}
+
+	if checkAuth {
+		transport = &authTransport{
+			base: transport,
+			onUnauthorized: func() {
+				c.setNeedsLogin(string(name), true)
+			},
+		}
+	}
+
+	if c.recorder != nil {
+		transport = &recordTransport{
+			target: string(name),
+			props:  props,
+			base:   transport,
+			rec:    c.recorder,
+		}
+	}
+
+	// Cancel all requests when the client is closed.
+	transport = &contextTransport{base: transport, ctx: c.ctx, busy: &c.busy}
+
+	return rc.NewTarget(
+		name,
+		props.TeamName,
+		props.API,
+		props.Token,
+		props.CACert,
+		caCertPool,
+		props.ClientCertPath,
+		props.ClientKeyPath,
+		clientCerts,
+		props.Insecure,
+		concourse.NewClient(props.API, &http.Client{Transport: transport}, false),
+	)
+}
+
+// loadCACertPool returns the system cert pool, with caCert (PEM) appended, the
+// same as fly.
+func loadCACertPool(caCert string) (*x509.CertPool, error) {
+	if caCert == "" {
+		return nil, nil
+	}
+
+	pool := x509.NewCertPool()
+	if runtime.GOOS != "windows" {
+		var err error
+
+		pool, err = x509.SystemCertPool()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !pool.AppendCertsFromPEM([]byte(caCert)) {
+		return nil, errors.New("CA cert is not valid")
+	}
+
+	return pool, nil
+}
+
+func loadClientCertificate(certPath, keyPath string) ([]tls.Certificate, error) {
+	switch {
+	case certPath == "" && keyPath == "":
+		return nil, nil
+	case certPath == "":
+		return nil, errors.New("a client key may not be declared without a client certificate")
+	case keyPath == "":
+		return nil, errors.New("a client certificate may not be declared without a client key")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return []tls.Certificate{cert}, nil
+}
@@ -0,0 +1,311 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/concourse/concourse/atc"
+	"github.com/concourse/concourse/fly/rc"
+	"github.com/concourse/concourse/go-concourse/concourse"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+const (
+	// inputVersionsLimit is the number of versions fetched for each job input.
+	inputVersionsLimit = 50
+
+	// pinHoldTimeout is how long resources stay pinned after triggering a build,
+	// while waiting for the build to determine its inputs.
+	pinHoldTimeout = 2 * time.Minute
+)
+
+// JobInputVersions contains the recent versions of a job input.
+type JobInputVersions struct {
+	Input    atc.JobInput
+	Resource atc.Resource
+	Versions []atc.ResourceVersion
+}
+
+type JobInputVersionsMsg struct {
+	Pipeline atc.PipelineRef
+	Job      string
+	Inputs   []JobInputVersions
+	Error    error
+}
+
+// QueryJobInputVersions queries the inputs of the provided job, and the recent
+// versions of each input's resource. The query is canceled when view is left.
+func (c *apiManager) QueryJobInputVersions(view types.Viewable, pipeline atc.PipelineRef, job string) tea.Cmd {
+	return func() tea.Msg {
+		defer c.Loading("fetching job inputs")()
+
+		ctx := c.viewContextOf(view)
+		client, _ := contextClient(ctx, c.Client(), 0)
+
+		inputs, err := c.queryJobInputVersions(client.Team(c.Active().Team().Name()), pipeline, job)
+		if ctx.Err() != nil {
+			return nil
+		}
+		err = c.checkAuth(c.ActiveName(), "inputs:"+pipeline.String()+"/"+job, err, c.QueryJobInputVersions(view, pipeline, job))
+
+		c.logger.WithFields(log.Fields{
+			"pipeline": pipeline.String(),
+			"job":      job,
+			"inputs":   len(inputs),
+			"error":    err,
+		}).Debug("queried job input versions")
+
+		return JobInputVersionsMsg{Pipeline: pipeline, Job: job, Inputs: inputs, Error: err}
+	}
+}
+
+func (c *apiManager) queryJobInputVersions(team concourse.Team, pipeline atc.PipelineRef, job string) ([]JobInputVersions, error) {
+	j, found, err := team.Job(pipeline, job)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, fmt.Errorf("job %s/%s not found", pipeline.String(), job)
+	}
+
+	inputs := make([]JobInputVersions, 0, len(j.Inputs))
+
+	for _, input := range j.Inputs {
+		resource, found, err := team.Resource(pipeline, input.Resource)
+		if err != nil {
+			return nil, err
+		}
+
+		if !found {
+			return nil, fmt.Errorf("resource %q not found", input.Resource)
+		}
+
+		versions, _, _, err := team.ResourceVersions(pipeline, input.Resource, concourse.Page{Limit: inputVersionsLimit}, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		inputs = append(inputs, JobInputVersions{
+			Input:    input,
+			Resource: resource,
+			Versions: versions,
+		})
+	}
+
+	return inputs, nil
+}
+
+// pinState is the pin state of a resource, prior to being temporarily pinned.
+type pinState struct {
+	resource string
+	version  atc.Version
+	comment  string
+}
+
+// TriggerWithVersions triggers a build of the provided job, using the specific
+// versions provided (resource name -> resource version ID). This is done by
+// temporarily pinning each resource, triggering the job, and restoring the
+// previous pin state once the build has determined its inputs (or if any step
+// fails). The build is then streamed, like with Execute.
+func (c *apiManager) TriggerWithVersions(pipeline atc.PipelineRef, job string, versions map[string]int) tea.Cmd {
+	return func() tea.Msg {
+		target := c.Active()
+
+		build, err := c.triggerWithVersions(target, pipeline, job, versions)
+
+		c.logger.WithFields(log.Fields{
+			"pipeline": pipeline.String(),
+			"job":      job,
+			"versions": versions,
+			"build":    build.ID,
+			"error":    err,
+		}).Debug("triggered job with versions")
+
+		if build.ID == 0 {
+			return c.buildStarted(BuildStartedMsg{Error: err})
+		}
+
+		if err != nil {
+			c.bus.Publish(types.TopicNotify, types.NotifyMsg{Error: err})
+		}
+
+		msg := c.buildStarted(BuildStartedMsg{Build: build})
+
+		c.wg.Add(1)
+		go c.streamBuild(target, build, nil)
+
+		return msg
+	}
+}
+
+func (c *apiManager) triggerWithVersions(
+	target rc.Target,
+	pipeline atc.PipelineRef,
+	job string,
+	versions map[string]int,
+) (build atc.Build, err error) {
+	defer c.Loading("triggering " + job)()
+
+	team := target.Team()
+
+	resources := make([]string, 0, len(versions))
+	for resource := range versions {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+
+	// Record the pin state of all resources before changing anything, so we
+	// don't leave a partially pinned pipeline behind.
+	previous := make([]pinState, 0, len(resources))
+	for _, name := range resources {
+		resource, found, rerr := team.Resource(pipeline, name)
+		if rerr != nil {
+			return build, rerr
+		}
+
+		if !found {
+			return build, fmt.Errorf("resource %q not found", name)
+		}
+
+		if resource.PinnedInConfig {
+			return build, fmt.Errorf("resource %q is pinned in the pipeline config, and can't be re-pinned", name)
+		}
+
+		previous = append(previous, pinState{
+			resource: name,
+			version:  resource.PinnedVersion,
+			comment:  resource.PinComment,
+		})
+	}
+
+	var changed []pinState
+
+	defer func() {
+		if rerr := c.restorePins(team, pipeline, changed); rerr != nil {
+			if err != nil {
+				err = fmt.Errorf("%w (additionally, restoring pins failed: %v)", err, rerr)
+				return
+			}
+
+			err = fmt.Errorf("failed to restore pins: %w", rerr)
+		}
+	}()
+
+	for _, state := range previous {
+		changed = append(changed, state)
+
+		err = pinVersion(team, pipeline, state.resource, versions[state.resource], "temporarily pinned by hangar-ui to trigger "+job)
+		if err != nil {
+			return build, fmt.Errorf("failed to pin %q: %w", state.resource, err)
+		}
+	}
+
+	build, err = team.CreateJobBuild(pipeline, job)
+	if err != nil {
+		return build, err
+	}
+
+	return build, c.waitForBuildStart(target, build)
+}
+
+// waitForBuildStart waits until the build is no longer pending, at which point
+// its inputs have been determined.
+func (c *apiManager) waitForBuildStart(target rc.Target, build atc.Build) error {
+	defer c.Loading(fmt.Sprintf("waiting for build %d to start", build.ID))()
+
+	deadline := time.After(pinHoldTimeout)
+
+	for {
+		b, found, err := target.Client().Build(strconv.Itoa(build.ID))
+		if err != nil {
+			return err
+		}
+
+		if found && atc.BuildStatus(b.Status) != atc.StatusPending {
+			return nil
+		}
+
+		select {
+		case <-c.ctx.Done():
+			return c.ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("build %d still pending after %s, it may not use the selected versions", build.ID, pinHoldTimeout)
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// restorePins restores the provided resources to their previous pin state.
+func (c *apiManager) restorePins(team concourse.Team, pipeline atc.PipelineRef, states []pinState) error {
+	if len(states) == 0 {
+		return nil
+	}
+
+	defer c.Loading("restoring pins")()
+
+	var errs []string
+
+	for _, state := range states {
+		if len(state.version) == 0 {
+			found, err := team.UnpinResource(pipeline, state.resource)
+			if err == nil && !found {
+				err = errors.New("resource not found")
+			}
+
+			if err != nil {
+				errs = append(errs, state.resource+": "+err.Error())
+			}
+			continue
+		}
+
+		id, err := resourceVersionID(team, pipeline, state.resource, state.version)
+		if err == nil {
+			err = pinVersion(team, pipeline, state.resource, id, state.comment)
+		}
+
+		if err != nil {
+			errs = append(errs, state.resource+": "+err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// pinVersion pins the version of a resource with the provided ID, and sets the
+// pin comment.
+func pinVersion(team concourse.Team, pipeline atc.PipelineRef, resource string, id int, comment string) error {
+	found, err := team.PinResourceVersion(pipeline, resource, id)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return fmt.Errorf("version %d not found", id)
+	}
+
+	found, err = team.SetPinComment(pipeline, resource, comment)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return errors.New("resource not found while setting the pin comment")
+	}
+
+	return nil
+}
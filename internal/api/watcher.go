@@ -0,0 +1,203 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/concourse/concourse/fly/rc"
+	"github.com/fsnotify/fsnotify"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+// flyrcDebounce is how long to wait for writes to the flyrc to settle, before
+// reloading it.
+const flyrcDebounce = 250 * time.Millisecond
+
+// FlyrcPath returns the path to the flyrc, the same as fly.
+func FlyrcPath() string {
+	home := os.Getenv("FLY_HOME")
+
+	if home == "" {
+		home = os.Getenv("HOME")
+	}
+
+	if home == "" && runtime.GOOS == "windows" {
+		home = os.Getenv("USERPROFILE")
+
+		if home == "" {
+			home = os.Getenv("HOMEDRIVE") + os.Getenv("HOMEPATH")
+		}
+	}
+
+	return filepath.Join(home, ".flyrc")
+}
+
+// Watcher is a background worker that reloads the targets when the flyrc
+// changes. If the flyrc can't be watched, it's reloaded periodically instead.
+func (c *apiManager) Watcher() {
+	defer c.wg.Done()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		c.logger.WithError(err).Warn("failed to watch flyrc, falling back to polling")
+		c.pollTargets()
+		return
+	}
+	defer watcher.Close()
+
+	path := filepath.Clean(FlyrcPath())
+
+	// Watch the directory rather than the file itself, so the flyrc being
+	// created or replaced (e.g. by editors) is also picked up.
+	if err = watcher.Add(filepath.Dir(path)); err != nil {
+		c.logger.WithError(err).Warn("failed to watch flyrc, falling back to polling")
+		c.pollTargets()
+		return
+	}
+
+	var reload <-chan time.Time
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if filepath.Clean(event.Name) == path {
+				reload = time.After(flyrcDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+
+			c.logger.WithError(err).Warn("error while watching flyrc")
+		case <-reload:
+			reload = nil
+			c.UpdateTargets()
+		}
+	}
+}
+
+// pollTargets periodically reloads the targets from the flyrc.
+func (c *apiManager) pollTargets() {
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+			c.UpdateTargets()
+		}
+	}
+}
+
+// UpdateTargets updates the targets list from the fly config file (flyrc), and
+// sends a types.FlyTargetsChangedMsg if any targets were added, removed or
+// changed.
+func (c *apiManager) UpdateTargets() {
+	targets, err := c.loadTargets()
+	if err != nil {
+		c.logger.WithError(err).Error("failed to load targets from flyrc")
+		c.bus.Publish(types.TopicNotify, types.NotifyMsg{Error: fmt.Errorf("failed to load targets from flyrc: %w", err)})
+		return
+	}
+
+	previous := c.targets.Load()
+	c.targets.Store(targets)
+
+	msg := diffTargets(previous, targets)
+	if len(msg.Added) == 0 && len(msg.Removed) == 0 && len(msg.Changed) == 0 {
+		return
+	}
+
+	c.logger.WithFields(log.Fields{
+		"added":   msg.Added,
+		"removed": msg.Removed,
+		"changed": msg.Changed,
+	}).Debug("targets updated")
+
+	c.unloadTargets(append(msg.Changed, msg.Removed...)...)
+	c.bus.Publish(types.TopicTargets, msg)
+
+	for _, name := range msg.Changed {
+		c.reloadTarget(name, previous[rc.TargetName(name)], targets[rc.TargetName(name)])
+	}
+
+	for _, name := range msg.Removed {
+		if name == c.ActiveName() {
+			c.bus.Publish(types.TopicNotify, types.NotifyMsg{Error: fmt.Errorf("active target %q was removed from the flyrc", name)})
+		}
+	}
+
+	// If there was no usable target yet (e.g. on first run), use the first one
+	// that was added. previous is nil on the initial load, where NewAPIClient
+	// picks the active target instead.
+	if previous != nil && c.ActiveName() == "" {
+		for _, name := range msg.Added {
+			if err = c.SetActive(name); err == nil {
+				break
+			}
+
+			c.logger.WithError(err).WithField("target", name).Error("failed to configure target")
+		}
+	}
+}
+
+// reloadTarget reloads a target which was changed in the flyrc. If its token
+// was refreshed (e.g. by `fly login`) while it needed login, the login is
+// completed, otherwise the active target is reloaded with the new config.
+func (c *apiManager) reloadTarget(name string, previous, current rc.TargetProps) {
+	if c.NeedsLogin(name) && !reflect.DeepEqual(previous.Token, current.Token) {
+		c.bus.Publish(types.TopicLogin, c.completeLogin(name))
+		return
+	}
+
+	if name != c.ActiveName() {
+		return
+	}
+
+	if err := c.SetActive(name); err != nil {
+		c.logger.WithError(err).WithField("target", name).Error("failed to reload active target")
+		c.bus.Publish(types.TopicNotify, types.NotifyMsg{Error: fmt.Errorf("failed to reload target %q: %w", name, err)})
+	}
+}
+
+// diffTargets returns the names of the targets which were added, removed or
+// changed between previous and current.
+func diffTargets(previous, current rc.Targets) (msg types.FlyTargetsChangedMsg) {
+	for name, props := range current {
+		old, ok := previous[name]
+
+		switch {
+		case !ok:
+			msg.Added = append(msg.Added, string(name))
+		case !reflect.DeepEqual(old, props):
+			msg.Changed = append(msg.Changed, string(name))
+		}
+	}
+
+	for name := range previous {
+		if _, ok := current[name]; !ok {
+			msg.Removed = append(msg.Removed, string(name))
+		}
+	}
+
+	sort.Strings(msg.Added)
+	sort.Strings(msg.Removed)
+	sort.Strings(msg.Changed)
+
+	return msg
+}
@@ -0,0 +1,113 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/concourse/concourse/fly/rc"
+	"github.com/lrstanley/hangar-ui/internal/fakeatc"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+func TestDiffTargets(t *testing.T) {
+	a := rc.TargetProps{API: "https://a.example.com", TeamName: "main"}
+	b := rc.TargetProps{API: "https://b.example.com", TeamName: "main"}
+
+	relogged := a
+	relogged.Token = &rc.TargetToken{Type: "bearer", Value: "new"}
+
+	tests := []struct {
+		name     string
+		previous rc.Targets
+		current  rc.Targets
+		want     types.FlyTargetsChangedMsg
+	}{
+		{
+			name:    "initial load",
+			current: rc.Targets{"b": b, "a": a},
+			want:    types.FlyTargetsChangedMsg{Added: []string{"a", "b"}},
+		},
+		{
+			name:     "unchanged",
+			previous: rc.Targets{"a": a},
+			current:  rc.Targets{"a": a},
+		},
+		{
+			name:     "added",
+			previous: rc.Targets{"a": a},
+			current:  rc.Targets{"a": a, "b": b},
+			want:     types.FlyTargetsChangedMsg{Added: []string{"b"}},
+		},
+		{
+			name:     "removed",
+			previous: rc.Targets{"a": a, "b": b},
+			current:  rc.Targets{"a": a},
+			want:     types.FlyTargetsChangedMsg{Removed: []string{"b"}},
+		},
+		{
+			name:     "changed",
+			previous: rc.Targets{"a": a, "b": b},
+			current:  rc.Targets{"a": relogged, "b": b},
+			want:     types.FlyTargetsChangedMsg{Changed: []string{"a"}},
+		},
+		{
+			name:     "renamed",
+			previous: rc.Targets{"a": a},
+			current:  rc.Targets{"c": a},
+			want:     types.FlyTargetsChangedMsg{Added: []string{"c"}, Removed: []string{"a"}},
+		},
+		{
+			name:     "all removed",
+			previous: rc.Targets{"a": a, "b": b},
+			current:  rc.Targets{},
+			want:     types.FlyTargetsChangedMsg{Removed: []string{"a", "b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffTargets(tt.previous, tt.current); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUpdateTargetsActiveRemoved(t *testing.T) {
+	c, _ := newTestClient(t, fakeatc.NewState())
+	updates := subscribe(c, types.TopicTargets, types.TopicNotify)
+
+	err := c.editFlyrc(func(targets map[string]map[string]any) error {
+		delete(targets, testTarget)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.UpdateTargets()
+
+	// Skip the initial load.
+	changed := waitFor[types.FlyTargetsChangedMsg](t, updates)
+	if len(changed.Added) > 0 {
+		changed = waitFor[types.FlyTargetsChangedMsg](t, updates)
+	}
+
+	if !reflect.DeepEqual(changed.Removed, []string{testTarget}) {
+		t.Errorf("expected %q to be removed, got %+v", testTarget, changed)
+	}
+
+	notify := waitFor[types.NotifyMsg](t, updates)
+	if notify.Error == nil || !strings.Contains(notify.Error.Error(), "active target") {
+		t.Errorf("expected the user to be notified, got %v", notify.Error)
+	}
+
+	if _, ok := c.Targets()[testTarget]; ok {
+		t.Error("expected the target to be removed")
+	}
+}
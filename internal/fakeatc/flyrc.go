@@ -0,0 +1,80 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package fakeatc
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/concourse/concourse/atc"
+	"github.com/concourse/concourse/fly/rc"
+	"sigs.k8s.io/yaml"
+)
+
+// FlyrcTarget returns flyrc target properties pointing at the server, using
+// the currently configured token.
+func (s *Server) FlyrcTarget() rc.TargetProps {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	props := rc.TargetProps{API: s.URL, TeamName: atc.DefaultTeamName}
+
+	if s.state.Token != "" {
+		props.Token = &rc.TargetToken{Type: "bearer", Value: s.state.Token}
+	}
+
+	return props
+}
+
+// WriteFlyrc writes a flyrc containing targets to a new temporary directory,
+// and returns the directory. Setting FLY_HOME to the directory makes fly (and
+// the fly/rc package) use it instead of the real flyrc. The caller is
+// responsible for removing the directory.
+func WriteFlyrc(targets rc.Targets) (dir string, err error) {
+	dir, err = os.MkdirTemp("", "hangar-ui-flyrc-")
+	if err != nil {
+		return "", err
+	}
+
+	b, err := yaml.Marshal(rc.RC{Targets: targets})
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, ".flyrc"), b, 0o600); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// UseFlyrc writes a flyrc with a single target (named name) pointing at the
+// server, and points FLY_HOME at it. The returned function restores FLY_HOME,
+// and removes the flyrc.
+func (s *Server) UseFlyrc(name string) (restore func(), err error) {
+	dir, err := WriteFlyrc(rc.Targets{rc.TargetName(name): s.FlyrcTarget()})
+	if err != nil {
+		return nil, err
+	}
+
+	previous, hadPrevious := os.LookupEnv("FLY_HOME")
+
+	if err = os.Setenv("FLY_HOME", dir); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return func() {
+		if hadPrevious {
+			_ = os.Setenv("FLY_HOME", previous)
+		} else {
+			_ = os.Unsetenv("FLY_HOME")
+		}
+
+		_ = os.RemoveAll(dir)
+	}, nil
+}
@@ -0,0 +1,566 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package fakeatc
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/concourse/concourse/atc"
+	"github.com/concourse/concourse/atc/event"
+	"github.com/tedsuo/rata"
+	"github.com/vito/go-sse/sse"
+	"sigs.k8s.io/yaml"
+)
+
+func (s *Server) getInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, s.state.Info)
+}
+
+func (s *Server) downloadCLI(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cli, ok := s.state.CLI[r.URL.Query().Get("platform")+"/"+r.URL.Query().Get("arch")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(cli)))
+	_, _ = w.Write(cli)
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	teams := map[string][]string{}
+	for _, t := range s.state.Teams {
+		teams[t.Name] = []string{"owner"}
+	}
+
+	writeJSON(w, http.StatusOK, atc.UserInfo{
+		Sub:      s.state.Username,
+		Name:     s.state.Username,
+		UserName: s.state.Username,
+		IsAdmin:  true,
+		Teams:    teams,
+	})
+}
+
+func (s *Server) listTeams(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, s.state.Teams)
+}
+
+func (s *Server) listWorkers(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, s.state.Workers)
+}
+
+func (s *Server) listAllPipelines(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, s.state.Pipelines)
+}
+
+func (s *Server) listPipelines(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pipelines := []atc.Pipeline{}
+	for _, p := range s.state.Pipelines {
+		if p.TeamName == rata.Param(r, "team_name") {
+			pipelines = append(pipelines, p)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, pipelines)
+}
+
+func (s *Server) getPipeline(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := s.state.pipeline(rata.Param(r, "team_name"), pipelineRef(r))
+	if p == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p)
+}
+
+func (s *Server) setPipelinePaused(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		p := s.state.pipeline(rata.Param(r, "team_name"), pipelineRef(r))
+		if p == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		p.Paused = paused
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) getConfig(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := PipelineKey(rata.Param(r, "team_name"), pipelineRef(r))
+
+	config, ok := s.state.Configs[key]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set(atc.ConfigVersionHeader, strconv.Itoa(s.state.ConfigVersions[key]))
+	writeJSON(w, http.StatusOK, atc.ConfigResponse{Config: config})
+}
+
+func (s *Server) saveConfig(w http.ResponseWriter, r *http.Request) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var config atc.Config
+	if err = yaml.Unmarshal(b, &config); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string][]string{"errors": {err.Error()}})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	team, ref := rata.Param(r, "team_name"), pipelineRef(r)
+	key := PipelineKey(team, ref)
+
+	status := http.StatusOK
+	if _, ok := s.state.Configs[key]; !ok {
+		status = http.StatusCreated
+		s.state.AddPipeline(atc.Pipeline{Name: ref.Name, InstanceVars: ref.InstanceVars, TeamName: team, Paused: true}, nil, nil)
+	} else if r.Header.Get(atc.ConfigVersionHeader) != strconv.Itoa(s.state.ConfigVersions[key]) {
+		http.Error(w, "config version mismatch", http.StatusConflict)
+		return
+	}
+
+	s.state.Configs[key] = config
+	s.state.ConfigVersions[key]++
+
+	writeJSON(w, status, map[string]any{"warnings": []any{}})
+}
+
+func (s *Server) listAllJobs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	jobs := []atc.Job{}
+	for _, p := range s.state.Pipelines {
+		jobs = append(jobs, s.state.Jobs[PipelineKey(p.TeamName, p.Ref())]...)
+	}
+
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+func (s *Server) listJobs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	team, ref := rata.Param(r, "team_name"), pipelineRef(r)
+	if s.state.pipeline(team, ref) == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	jobs := s.state.Jobs[PipelineKey(team, ref)]
+	if jobs == nil {
+		jobs = []atc.Job{}
+	}
+
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+func (s *Server) getJob(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job := s.state.job(rata.Param(r, "team_name"), pipelineRef(r), rata.Param(r, "job_name"))
+	if job == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *Server) setJobPaused(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		job := s.state.job(rata.Param(r, "team_name"), pipelineRef(r), rata.Param(r, "job_name"))
+		if job == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		job.Paused = paused
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) createJobBuild(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	team, ref := rata.Param(r, "team_name"), pipelineRef(r)
+
+	job := s.state.job(team, ref, rata.Param(r, "job_name"))
+	if job == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	build := s.state.createBuild(atc.Build{
+		TeamName:             team,
+		PipelineName:         ref.Name,
+		PipelineInstanceVars: ref.InstanceVars,
+		PipelineID:           job.PipelineID,
+		JobName:              job.Name,
+	})
+
+	job.NextBuild = nil
+	job.FinishedBuild = &build
+
+	writeJSON(w, http.StatusOK, build)
+}
+
+func (s *Server) listJobBuilds(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	team, ref, name := rata.Param(r, "team_name"), pipelineRef(r), rata.Param(r, "job_name")
+
+	builds := []atc.Build{}
+	for i := len(s.state.Builds) - 1; i >= 0; i-- {
+		b := s.state.Builds[i]
+		if b.TeamName == team && b.PipelineName == ref.Name && b.JobName == name {
+			builds = append(builds, b)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, builds)
+}
+
+func (s *Server) clearCache(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, atc.ClearTaskCacheResponse{CachesRemoved: s.state.CachesRemoved})
+}
+
+func (s *Server) listResources(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resources := s.state.Resources[PipelineKey(rata.Param(r, "team_name"), pipelineRef(r))]
+	if resources == nil {
+		resources = []atc.Resource{}
+	}
+
+	writeJSON(w, http.StatusOK, resources)
+}
+
+func (s *Server) getResource(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resource := s.state.resource(rata.Param(r, "team_name"), pipelineRef(r), rata.Param(r, "resource_name"))
+	if resource == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, resource)
+}
+
+func (s *Server) listResourceVersions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	team, ref, name := rata.Param(r, "team_name"), pipelineRef(r), rata.Param(r, "resource_name")
+	if s.state.resource(team, ref, name) == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	versions := filterVersions(s.state.Versions[ResourceKey(team, ref, name)], r.URL.Query()["filter"])
+	if limit, _ := strconv.Atoi(r.URL.Query().Get("limit")); limit > 0 && limit < len(versions) {
+		versions = versions[:limit]
+	}
+
+	if versions == nil {
+		versions = []atc.ResourceVersion{}
+	}
+
+	writeJSON(w, http.StatusOK, versions)
+}
+
+// filterVersions returns the versions matching all filters, which are in the
+// "<key>:<value>" format, like the ATC.
+func filterVersions(versions []atc.ResourceVersion, filters []string) []atc.ResourceVersion {
+	if len(filters) == 0 {
+		return versions
+	}
+
+	var out []atc.ResourceVersion
+
+outer:
+	for _, v := range versions {
+		for _, filter := range filters {
+			key, value, _ := strings.Cut(filter, ":")
+			if v.Version[key] != value {
+				continue outer
+			}
+		}
+
+		out = append(out, v)
+	}
+
+	return out
+}
+
+func (s *Server) pinResourceVersion(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	team, ref, name := rata.Param(r, "team_name"), pipelineRef(r), rata.Param(r, "resource_name")
+	id := intParam(r, "resource_config_version_id")
+
+	resource := s.state.resource(team, ref, name)
+	if resource == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	for _, v := range s.state.Versions[ResourceKey(team, ref, name)] {
+		if v.ID == id {
+			resource.PinnedVersion = v.Version
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (s *Server) unpinResource(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resource := s.state.resource(rata.Param(r, "team_name"), pipelineRef(r), rata.Param(r, "resource_name"))
+	if resource == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	resource.PinnedVersion = nil
+	resource.PinComment = ""
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) setPinComment(w http.ResponseWriter, r *http.Request) {
+	var body atc.SetPinCommentRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resource := s.state.resource(rata.Param(r, "team_name"), pipelineRef(r), rata.Param(r, "resource_name"))
+	if resource == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	resource.PinComment = body.PinComment
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) listBuilds(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	builds := []atc.Build{}
+	for i := len(s.state.Builds) - 1; i >= 0; i-- {
+		builds = append(builds, s.state.Builds[i])
+	}
+
+	writeJSON(w, http.StatusOK, builds)
+}
+
+func (s *Server) createBuild(w http.ResponseWriter, r *http.Request) {
+	var plan atc.Plan
+	if err := json.NewDecoder(r.Body).Decode(&plan); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	build := s.state.createBuild(atc.Build{TeamName: rata.Param(r, "team_name")})
+	writeJSON(w, http.StatusCreated, build)
+}
+
+func (s *Server) getBuild(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	build := s.state.build(intParam(r, "build_id"))
+	if build == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, build)
+}
+
+func (s *Server) abortBuild(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	build := s.state.build(intParam(r, "build_id"))
+	if build == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if !build.Abortable() {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	build.Status = atc.StatusAborted
+	build.EndTime = time.Now().Unix()
+	s.state.Events[build.ID] = append(s.state.Events[build.ID], event.Status{Status: atc.StatusAborted, Time: build.EndTime})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// buildEvents streams the events of a build over SSE, in the same format as
+// the ATC, ending the stream once all events have been sent.
+func (s *Server) buildEvents(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	build := s.state.build(intParam(r, "build_id"))
+	events := append([]atc.Event(nil), s.state.Events[intParam(r, "build_id")]...)
+	onStream := s.state.OnStreamEvents
+	s.mu.Unlock()
+
+	if build == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+
+	if onStream != nil {
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		onStream(build.ID)
+	}
+
+	for i, e := range events {
+		b, err := json.Marshal(event.Message{Event: e})
+		if err != nil {
+			return
+		}
+
+		if err = (sse.Event{ID: strconv.Itoa(i), Name: "event", Data: b}).Write(w); err != nil {
+			return
+		}
+
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	_ = sse.Event{ID: strconv.Itoa(len(events)), Name: "end"}.Write(w)
+
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+func (s *Server) listBuildArtifacts(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	artifacts := s.state.BuildArtifacts[intParam(r, "build_id")]
+	if artifacts == nil {
+		artifacts = []atc.WorkerArtifact{}
+	}
+
+	writeJSON(w, http.StatusOK, artifacts)
+}
+
+func (s *Server) createArtifact(w http.ResponseWriter, r *http.Request) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.state.nextArtifactID()
+	s.state.Artifacts[id] = b
+
+	writeJSON(w, http.StatusCreated, atc.WorkerArtifact{ID: id, CreatedAt: time.Now().Unix()})
+}
+
+func (s *Server) getArtifact(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	b, ok := s.state.Artifacts[intParam(r, "artifact_id")]
+	s.mu.Unlock()
+
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(b)
+}
@@ -0,0 +1,222 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+// Package fakeatc provides an in-process fake Concourse ATC, backed by
+// in-memory state, implementing the endpoints used by hangar-ui. It allows
+// running the API layer and the UI end-to-end without a Concourse install.
+package fakeatc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/concourse/concourse/atc"
+	"github.com/tedsuo/rata"
+)
+
+// Server is a running fake ATC. The embedded httptest.Server provides the URL,
+// which can be used as a fly target (see WriteFlyrc).
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	state    *State
+	requests []string
+}
+
+// New starts a fake ATC serving state. If state is nil, NewState is used. The
+// caller must call Close once done.
+func New(state *State) *Server {
+	if state == nil {
+		state = NewState()
+	}
+
+	s := &Server{state: state}
+
+	handlers := rata.Handlers{}
+	for _, route := range atc.Routes {
+		handlers[route.Name] = http.HandlerFunc(notImplemented)
+	}
+
+	for name, fn := range map[string]http.HandlerFunc{
+		atc.GetInfo:                 s.getInfo,
+		atc.DownloadCLI:             s.downloadCLI,
+		atc.GetUser:                 s.auth(s.getUser),
+		atc.ListTeams:               s.auth(s.listTeams),
+		atc.ListWorkers:             s.auth(s.listWorkers),
+		atc.ListAllPipelines:        s.auth(s.listAllPipelines),
+		atc.ListPipelines:           s.auth(s.listPipelines),
+		atc.GetPipeline:             s.auth(s.getPipeline),
+		atc.PausePipeline:           s.auth(s.setPipelinePaused(true)),
+		atc.UnpausePipeline:         s.auth(s.setPipelinePaused(false)),
+		atc.GetConfig:               s.auth(s.getConfig),
+		atc.SaveConfig:              s.auth(s.saveConfig),
+		atc.ListAllJobs:             s.auth(s.listAllJobs),
+		atc.ListJobs:                s.auth(s.listJobs),
+		atc.GetJob:                  s.auth(s.getJob),
+		atc.PauseJob:                s.auth(s.setJobPaused(true)),
+		atc.UnpauseJob:              s.auth(s.setJobPaused(false)),
+		atc.CreateJobBuild:          s.auth(s.createJobBuild),
+		atc.ListJobBuilds:           s.auth(s.listJobBuilds),
+		atc.ClearTaskCache:          s.auth(s.clearCache),
+		atc.ListResources:           s.auth(s.listResources),
+		atc.GetResource:             s.auth(s.getResource),
+		atc.ListResourceVersions:    s.auth(s.listResourceVersions),
+		atc.PinResourceVersion:      s.auth(s.pinResourceVersion),
+		atc.UnpinResource:           s.auth(s.unpinResource),
+		atc.SetPinCommentOnResource: s.auth(s.setPinComment),
+		atc.ClearResourceCache:      s.auth(s.clearCache),
+		atc.ListBuilds:              s.auth(s.listBuilds),
+		atc.CreateBuild:             s.auth(s.createBuild),
+		atc.GetBuild:                s.auth(s.getBuild),
+		atc.AbortBuild:              s.auth(s.abortBuild),
+		atc.BuildEvents:             s.auth(s.buildEvents),
+		atc.ListBuildArtifacts:      s.auth(s.listBuildArtifacts),
+		atc.CreateArtifact:          s.auth(s.createArtifact),
+		atc.GetArtifact:             s.auth(s.getArtifact),
+	} {
+		handlers[name] = fn
+	}
+
+	router, err := rata.NewRouter(atc.Routes, handlers)
+	if err != nil {
+		panic(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sky/issuer/token", s.token)
+	mux.HandleFunc("/login", s.login)
+	mux.Handle("/api/", router)
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		s.mu.Unlock()
+
+		mux.ServeHTTP(w, r)
+	}))
+
+	return s
+}
+
+// Update calls fn with the state locked, allowing it to be modified while the
+// server is running.
+func (s *Server) Update(fn func(state *State)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fn(s.state)
+}
+
+// Requests returns all requests received so far, as "METHOD /path".
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.requests...)
+}
+
+// auth wraps handlers which require a valid token.
+func (s *Server) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		token, expired := s.state.Token, s.state.TokenExpired
+		s.mu.Unlock()
+
+		if token != "" {
+			typ, value, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+
+			if expired || !strings.EqualFold(typ, "bearer") || value != token {
+				http.Error(w, "not authorized", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// token implements the password grant of the token endpoint, as used by
+// `fly login --username --password`.
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.PostForm.Get("grant_type") != "password" ||
+		r.PostForm.Get("username") != s.state.Username ||
+		r.PostForm.Get("password") != s.state.Password {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	s.state.TokenExpired = false
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": s.state.Token,
+		"token_type":   "bearer",
+		"expires_in":   86400,
+	})
+}
+
+// login implements the browser login used by `fly login` (without username and
+// password), as if the user is already logged in: it immediately redirects to
+// the local callback listener of the client, with the token.
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	port := r.URL.Query().Get("fly_port")
+	if port == "" {
+		http.Error(w, "missing fly_port", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.state.TokenExpired = false
+	token := s.state.Token
+	s.mu.Unlock()
+
+	http.Redirect(w, r, fmt.Sprintf(
+		"http://127.0.0.1:%s/auth/callback?token=%s",
+		port,
+		url.QueryEscape("bearer "+token),
+	), http.StatusFound)
+}
+
+func notImplemented(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "not implemented by the fake ATC", http.StatusNotImplemented)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// pipelineRef returns the pipeline reference from the route params, and the
+// instance vars query params.
+func pipelineRef(r *http.Request) atc.PipelineRef {
+	vars, _ := atc.InstanceVarsFromQueryParams(r.URL.Query())
+	return atc.PipelineRef{Name: rata.Param(r, "pipeline_name"), InstanceVars: vars}
+}
+
+func intParam(r *http.Request, name string) int {
+	id, _ := strconv.Atoi(rata.Param(r, name))
+	return id
+}
@@ -0,0 +1,277 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package fakeatc
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/concourse/concourse/atc"
+	"github.com/concourse/concourse/atc/event"
+)
+
+// State is the in-memory state served by the fake ATC. It can be modified at
+// any time through Server.Update, which allows scripting scenarios (e.g. a
+// build progressing, or a token expiring) while the UI is running.
+type State struct {
+	Info    atc.Info
+	Teams   []atc.Team
+	Workers []atc.Worker
+
+	// Token is the bearer token required for all authenticated endpoints. If
+	// empty, authentication is disabled.
+	Token string
+	// TokenExpired makes all authenticated endpoints return 401, as the ATC
+	// does with an expired token.
+	TokenExpired bool
+	// Username and Password are the credentials accepted by the password grant
+	// on the token endpoint, which responds with Token.
+	Username string
+	Password string
+
+	Pipelines []atc.Pipeline
+
+	// Jobs, Resources, Configs and ConfigVersions are keyed by PipelineKey.
+	Jobs           map[string][]atc.Job
+	Resources      map[string][]atc.Resource
+	Configs        map[string]atc.Config
+	ConfigVersions map[string]int
+
+	// Versions is keyed by ResourceKey.
+	Versions map[string][]atc.ResourceVersion
+
+	Builds []atc.Build
+	// Events are the events streamed for each build ID. Once all events have
+	// been sent, the stream is ended.
+	Events map[int][]atc.Event
+	// BuildArtifacts are the artifacts listed for each build ID.
+	BuildArtifacts map[int][]atc.WorkerArtifact
+	// Artifacts are the contents (tar.gz) of each artifact ID.
+	Artifacts map[int][]byte
+
+	// CLI are the fly binaries served by the CLI download endpoint, keyed by
+	// "platform/arch" (e.g. "linux/amd64").
+	CLI map[string][]byte
+
+	// CachesRemoved is the count returned when clearing task or resource
+	// caches.
+	CachesRemoved int64
+
+	// OnCreateBuild, if set, is called when a build is created (one-off or job
+	// builds), allowing the build to be adjusted, and returns the events that
+	// will be streamed for it. If nil, builds immediately succeed.
+	OnCreateBuild func(build *atc.Build) []atc.Event
+
+	// OnStreamEvents, if set, is called once the event stream of a build has
+	// been opened, before any events are sent (and without the state locked),
+	// e.g. to hold the stream open.
+	OnStreamEvents func(buildID int)
+}
+
+// NewState returns a State with a single "main" team, a single worker, and
+// credentials for the "admin" user.
+func NewState() *State {
+	return &State{
+		Info: atc.Info{
+			Version:       "7.7.1",
+			WorkerVersion: "2.3",
+			ClusterName:   "fake",
+		},
+		Teams: []atc.Team{{ID: 1, Name: atc.DefaultTeamName}},
+		Workers: []atc.Worker{{
+			Name:          "fake-worker",
+			Platform:      "linux",
+			State:         "running",
+			Version:       "2.3",
+			StartTime:     time.Now().Unix(),
+			ActiveTasks:   0,
+			ResourceTypes: []atc.WorkerResourceType{{Type: "git"}, {Type: "time"}},
+		}},
+		Token:          "fake-token",
+		Username:       "admin",
+		Password:       "admin",
+		Jobs:           map[string][]atc.Job{},
+		Resources:      map[string][]atc.Resource{},
+		Configs:        map[string]atc.Config{},
+		ConfigVersions: map[string]int{},
+		Versions:       map[string][]atc.ResourceVersion{},
+		Events:         map[int][]atc.Event{},
+		BuildArtifacts: map[int][]atc.WorkerArtifact{},
+		Artifacts:      map[int][]byte{},
+		CLI:            map[string][]byte{},
+	}
+}
+
+// PipelineKey returns the key used for per-pipeline state.
+func PipelineKey(team string, ref atc.PipelineRef) string {
+	return team + "/" + ref.String()
+}
+
+// ResourceKey returns the key used for per-resource state.
+func ResourceKey(team string, ref atc.PipelineRef, resource string) string {
+	return PipelineKey(team, ref) + "/" + resource
+}
+
+// AddPipeline adds a pipeline, along with its jobs and resources, assigning
+// IDs where they aren't set.
+func (s *State) AddPipeline(p atc.Pipeline, jobs []atc.Job, resources []atc.Resource) {
+	if p.TeamName == "" {
+		p.TeamName = atc.DefaultTeamName
+	}
+
+	if p.ID == 0 {
+		p.ID = len(s.Pipelines) + 1
+	}
+
+	s.Pipelines = append(s.Pipelines, p)
+	key := PipelineKey(p.TeamName, p.Ref())
+
+	for i := range jobs {
+		jobs[i].TeamName = p.TeamName
+		jobs[i].PipelineName = p.Name
+		jobs[i].PipelineInstanceVars = p.InstanceVars
+		jobs[i].PipelineID = p.ID
+
+		if jobs[i].ID == 0 {
+			jobs[i].ID = i + 1
+		}
+	}
+
+	for i := range resources {
+		resources[i].TeamName = p.TeamName
+		resources[i].PipelineName = p.Name
+		resources[i].PipelineInstanceVars = p.InstanceVars
+		resources[i].PipelineID = p.ID
+	}
+
+	s.Jobs[key] = jobs
+	s.Resources[key] = resources
+	s.Configs[key] = atc.Config{}
+	s.ConfigVersions[key] = 1
+}
+
+// AddVersions adds versions to a resource, assigning IDs where they aren't
+// set. Versions are listed newest first, like the ATC.
+func (s *State) AddVersions(team string, ref atc.PipelineRef, resource string, versions ...atc.Version) {
+	key := ResourceKey(team, ref, resource)
+
+	for _, v := range versions {
+		s.Versions[key] = append([]atc.ResourceVersion{{
+			ID:      s.nextVersionID(),
+			Version: v,
+			Enabled: true,
+		}}, s.Versions[key]...)
+	}
+}
+
+func (s *State) nextVersionID() (id int) {
+	for _, versions := range s.Versions {
+		for _, v := range versions {
+			if v.ID > id {
+				id = v.ID
+			}
+		}
+	}
+	return id + 1
+}
+
+func (s *State) nextArtifactID() (id int) {
+	for artifactID := range s.Artifacts {
+		if artifactID > id {
+			id = artifactID
+		}
+	}
+
+	for _, artifacts := range s.BuildArtifacts {
+		for _, a := range artifacts {
+			if a.ID > id {
+				id = a.ID
+			}
+		}
+	}
+	return id + 1
+}
+
+func (s *State) nextBuildID() (id int) {
+	for _, b := range s.Builds {
+		if b.ID > id {
+			id = b.ID
+		}
+	}
+	return id + 1
+}
+
+func (s *State) pipeline(team string, ref atc.PipelineRef) *atc.Pipeline {
+	for i := range s.Pipelines {
+		if s.Pipelines[i].TeamName == team && s.Pipelines[i].Ref().String() == ref.String() {
+			return &s.Pipelines[i]
+		}
+	}
+	return nil
+}
+
+func (s *State) job(team string, ref atc.PipelineRef, name string) *atc.Job {
+	jobs := s.Jobs[PipelineKey(team, ref)]
+	for i := range jobs {
+		if jobs[i].Name == name {
+			return &jobs[i]
+		}
+	}
+	return nil
+}
+
+func (s *State) resource(team string, ref atc.PipelineRef, name string) *atc.Resource {
+	resources := s.Resources[PipelineKey(team, ref)]
+	for i := range resources {
+		if resources[i].Name == name {
+			return &resources[i]
+		}
+	}
+	return nil
+}
+
+func (s *State) build(id int) *atc.Build {
+	for i := range s.Builds {
+		if s.Builds[i].ID == id {
+			return &s.Builds[i]
+		}
+	}
+	return nil
+}
+
+// createBuild stores a new build, and the events to stream for it.
+func (s *State) createBuild(build atc.Build) atc.Build {
+	build.ID = s.nextBuildID()
+	build.Name = "1"
+	build.Status = atc.StatusStarted
+	build.StartTime = time.Now().Unix()
+
+	if build.JobName != "" {
+		var count int
+		for _, b := range s.Builds {
+			if b.JobName == build.JobName && b.PipelineName == build.PipelineName && b.TeamName == build.TeamName {
+				count++
+			}
+		}
+		build.Name = strconv.Itoa(count + 1)
+	}
+
+	var events []atc.Event
+	if s.OnCreateBuild != nil {
+		events = s.OnCreateBuild(&build)
+	} else {
+		build.Status = atc.StatusSucceeded
+		build.EndTime = build.StartTime
+		events = []atc.Event{
+			event.Log{Payload: "hello from the fake ATC\n"},
+			event.Status{Status: atc.StatusSucceeded, Time: build.EndTime},
+		}
+	}
+
+	s.Builds = append(s.Builds, build)
+	s.Events[build.ID] = events
+
+	return build
+}
@@ -14,4 +14,5 @@ type App interface {
 	IsFocused(v Viewable) bool
 	Active() Viewable
 	Previous() Viewable
+	Commands() []Command
 }
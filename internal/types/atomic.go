@@ -17,9 +17,11 @@ type Atomic[T any] struct {
 	value atomic.Value
 }
 
-// Load returns the value of the Atomic.
+// Load returns the value of the Atomic, or the zero value of T if no value has
+// been stored yet.
 func (a *Atomic[T]) Load() T {
-	return a.value.Load().(T)
+	v, _ := a.value.Load().(T)
+	return v
 }
 
 // Store sets the value of the Atomic, and returns itself.
@@ -41,9 +43,11 @@ type AtomicComparable[T comparable] struct {
 	value atomic.Value
 }
 
-// Load returns the value of the Atomic.
+// Load returns the value of the Atomic, or the zero value of T if no value has
+// been stored yet.
 func (a *AtomicComparable[T]) Load() T {
-	return a.value.Load().(T)
+	v, _ := a.value.Load().(T)
+	return v
 }
 
 // Store sets the value of the Atomic, and returns itself.
@@ -0,0 +1,392 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package types
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Topic is a topic of events published on the EventBus.
+type Topic string
+
+const (
+	// TopicTargets are changes to the targets in the flyrc
+	// (FlyTargetsChangedMsg).
+	TopicTargets Topic = "targets"
+
+	// TopicState are changes to the state of the active target (FlyEvent).
+	TopicState Topic = "state"
+
+	// TopicLoading are loading states (LoadingMsg and CancelLoadingMsg).
+	TopicLoading Topic = "loading"
+
+	// TopicNotify are notifications and errors (NotifyMsg).
+	TopicNotify Topic = "notify"
+
+	// TopicQuery are query results, including polls.
+	TopicQuery Topic = "query"
+
+	// TopicBuilds are the streamed events of builds.
+	TopicBuilds Topic = "builds"
+
+	// TopicLogin are login progress and results.
+	TopicLogin Topic = "login"
+)
+
+// Policy is how events of a topic are queued for each subscriber, until they
+// are delivered. Publishing never blocks, events are dropped or coalesced
+// instead.
+type Policy struct {
+	// Limit is the max number of undelivered events of the topic, per
+	// subscriber. Once reached, the oldest event is dropped.
+	Limit int
+
+	// Coalesce replaces undelivered events with newer events with the same key
+	// (see Coalescer), for topics where only the latest state matters.
+	Coalesce bool
+
+	// Keep, if set, marks events which are only dropped to make room once no
+	// other events of the topic are left (e.g. errors).
+	Keep func(msg tea.Msg) bool
+}
+
+// DefaultPolicies are the policies of all topics.
+var DefaultPolicies = map[Topic]Policy{
+	TopicTargets: {Limit: 100},
+	TopicState:   {Limit: 100, Coalesce: true},
+	TopicLoading: {Limit: 1, Coalesce: true},
+	TopicNotify:  {Limit: 10, Keep: isNotifyError},
+	TopicQuery:   {Limit: 100, Coalesce: true},
+	TopicBuilds:  {Limit: 10000},
+	TopicLogin:   {Limit: 100},
+}
+
+// defaultPolicy is used for topics without a policy.
+var defaultPolicy = Policy{Limit: 100}
+
+// isNotifyError returns true if msg is an error notification, which shouldn't
+// be dropped in favor of other notifications.
+func isNotifyError(msg tea.Msg) bool {
+	n, ok := msg.(NotifyMsg)
+	return ok && n.Error != nil
+}
+
+// Coalescer can be implemented by events, to set which events replace each
+// other on coalescing topics. Events which don't implement it are coalesced
+// by type.
+type Coalescer interface {
+	CoalesceKey() string
+}
+
+// coalesceKey returns the key used to coalesce msg.
+func coalesceKey(msg tea.Msg) string {
+	if c, ok := msg.(Coalescer); ok {
+		return c.CoalesceKey()
+	}
+
+	return fmt.Sprintf("%T", msg)
+}
+
+type queuedEvent struct {
+	seq   uint64
+	topic Topic
+	key   string
+	msg   tea.Msg
+
+	dropped bool // Dropped or coalesced, but not yet removed from the queue.
+}
+
+// topicQueue is a queue of the undelivered events of a single topic. Dropped
+// events are only flagged, and skipped once they reach the head, so pushing is
+// O(1) (amortized).
+type topicQueue struct {
+	events []*queuedEvent
+	head   int
+	count  int                     // Undelivered events.
+	keys   map[string]*queuedEvent // Undelivered events, by coalesce key.
+}
+
+func (q *topicQueue) push(ev *queuedEvent, policy Policy) {
+	if policy.Coalesce {
+		if previous, ok := q.keys[ev.key]; ok {
+			q.drop(previous)
+		}
+
+		if q.keys == nil {
+			q.keys = map[string]*queuedEvent{}
+		}
+
+		q.keys[ev.key] = ev
+	}
+
+	if policy.Limit > 0 && q.count >= policy.Limit {
+		q.drop(q.oldest(policy))
+	}
+
+	q.events = append(q.events, ev)
+	q.count++
+}
+
+// oldest returns the oldest event which may be dropped to make room, see
+// Policy.Keep.
+func (q *topicQueue) oldest(policy Policy) *queuedEvent {
+	first := q.peek()
+
+	if policy.Keep != nil {
+		for _, ev := range q.events[q.head:] {
+			if !ev.dropped && !policy.Keep(ev.msg) {
+				return ev
+			}
+		}
+	}
+
+	return first
+}
+
+func (q *topicQueue) drop(ev *queuedEvent) {
+	ev.dropped = true
+	q.count--
+
+	if q.keys[ev.key] == ev {
+		delete(q.keys, ev.key)
+	}
+}
+
+// peek returns the oldest undelivered event, if any.
+func (q *topicQueue) peek() *queuedEvent {
+	for q.head < len(q.events) && q.events[q.head].dropped {
+		q.events[q.head] = nil
+		q.head++
+	}
+
+	if q.head == len(q.events) {
+		q.events, q.head = q.events[:0], 0
+		return nil
+	}
+
+	// Reclaim the space of delivered events once they're the majority.
+	if q.head > len(q.events)/2 {
+		q.events = append(q.events[:0], q.events[q.head:]...)
+		q.head = 0
+	}
+
+	return q.events[q.head]
+}
+
+func (q *topicQueue) pop() *queuedEvent {
+	ev := q.peek()
+	if ev == nil {
+		return nil
+	}
+
+	q.events[q.head] = nil
+	q.head++
+	q.drop(ev)
+
+	return ev
+}
+
+// eventQueue is a queue of undelivered events, with the policies of their
+// topics applied. Events are queued per topic, and popped in the order they
+// were published across all topics.
+type eventQueue map[Topic]*topicQueue
+
+func (q eventQueue) push(ev queuedEvent, policy Policy) {
+	tq, ok := q[ev.topic]
+	if !ok {
+		tq = &topicQueue{}
+		q[ev.topic] = tq
+	}
+
+	// Each queue has its own copy, as events are dropped per queue.
+	tq.push(&ev, policy)
+}
+
+// peek returns the queue of the oldest undelivered event, if any.
+func (q eventQueue) peek() (tq *topicQueue, ev *queuedEvent) {
+	for _, candidate := range q {
+		if next := candidate.peek(); next != nil && (ev == nil || next.seq < ev.seq) {
+			tq, ev = candidate, next
+		}
+	}
+
+	return tq, ev
+}
+
+// pop removes and returns the oldest undelivered event, if any.
+func (q eventQueue) pop() (queuedEvent, bool) {
+	tq, _ := q.peek()
+	if tq == nil {
+		return queuedEvent{}, false
+	}
+
+	return *tq.pop(), true
+}
+
+// subscriber is a component subscribed to one or more topics.
+type subscriber struct {
+	view   Viewable
+	topics map[Topic]bool
+	queue  eventQueue
+}
+
+// EventBus delivers events published by the API layer to the UI components
+// subscribed to their topic. Events are queued per subscriber, and delivered
+// in the order they were published, as ViewMsg addressed to the subscriber.
+type EventBus struct {
+	mu       sync.Mutex
+	seq      uint64
+	policies map[Topic]Policy
+	subs     []*subscriber
+
+	// unclaimed are events of topics nobody subscribed to yet (e.g. published
+	// before the UI was created), which are handed to the first subscriber.
+	unclaimed eventQueue
+
+	ready chan struct{}
+}
+
+// NewEventBus returns a new EventBus, using the provided policies per topic.
+func NewEventBus(policies map[Topic]Policy) *EventBus {
+	return &EventBus{
+		policies: policies,
+		ready:    make(chan struct{}, 1),
+
+		unclaimed: eventQueue{},
+	}
+}
+
+func (b *EventBus) policy(topic Topic) Policy {
+	if p, ok := b.policies[topic]; ok {
+		return p
+	}
+
+	return defaultPolicy
+}
+
+// Subscribe subscribes view to the provided topics. Events are delivered as
+// ViewMsg, with the event as Msg.
+func (b *EventBus) Subscribe(view Viewable, topics ...Topic) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var sub *subscriber
+
+	for _, s := range b.subs {
+		if s.view == view {
+			sub = s
+			break
+		}
+	}
+
+	if sub == nil {
+		sub = &subscriber{view: view, topics: map[Topic]bool{}, queue: eventQueue{}}
+		b.subs = append(b.subs, sub)
+	}
+
+	for _, topic := range topics {
+		sub.topics[topic] = true
+	}
+
+	// Nobody was subscribed to the topics of unclaimed events (including sub),
+	// so sub has no events of them yet, and can take them as-is.
+	claimed := false
+
+	for _, topic := range topics {
+		if tq, ok := b.unclaimed[topic]; ok {
+			sub.queue[topic] = tq
+			delete(b.unclaimed, topic)
+			claimed = true
+		}
+	}
+
+	if claimed {
+		b.notify()
+	}
+}
+
+// Publish queues msg for all subscribers of topic. It never blocks.
+func (b *EventBus) Publish(topic Topic, msg tea.Msg) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+
+	ev := queuedEvent{seq: b.seq, topic: topic, msg: msg}
+	policy := b.policy(topic)
+
+	if policy.Coalesce {
+		ev.key = coalesceKey(msg)
+	}
+
+	subscribed := false
+
+	for _, sub := range b.subs {
+		if sub.topics[topic] {
+			sub.queue.push(ev, policy)
+			subscribed = true
+		}
+	}
+
+	if !subscribed {
+		b.unclaimed.push(ev, policy)
+		return
+	}
+
+	b.notify()
+}
+
+// notify wakes up Run. b.mu must be held.
+func (b *EventBus) notify() {
+	select {
+	case b.ready <- struct{}{}:
+	default:
+	}
+}
+
+// next pops the oldest undelivered event of all subscribers, if any.
+func (b *EventBus) next() (ViewMsg, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var (
+		next  *subscriber
+		queue *topicQueue
+		seq   uint64
+	)
+
+	for _, sub := range b.subs {
+		if tq, ev := sub.queue.peek(); ev != nil && (next == nil || ev.seq < seq) {
+			next, queue, seq = sub, tq, ev.seq
+		}
+	}
+
+	if next == nil {
+		return ViewMsg{}, false
+	}
+
+	return ViewMsg{View: next.view, Msg: queue.pop().msg}, true
+}
+
+// Run delivers events to deliver (e.g. tea.Program.Send), until ctx is done.
+// Only Run waits for deliver, publishers never do.
+func (b *EventBus) Run(ctx context.Context, deliver func(tea.Msg)) {
+	for {
+		if msg, ok := b.next(); ok {
+			deliver(msg)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.ready:
+		}
+	}
+}
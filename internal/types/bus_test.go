@@ -0,0 +1,152 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package types
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type keyedMsg struct {
+	key   string
+	value int
+}
+
+func (m keyedMsg) CoalesceKey() string { return m.key }
+
+// drain returns the messages of all undelivered events of the bus, in the
+// order they are delivered.
+func drain(b *EventBus) (msgs []ViewMsg) {
+	for {
+		msg, ok := b.next()
+		if !ok {
+			return msgs
+		}
+
+		msgs = append(msgs, msg)
+	}
+}
+
+func TestEventQueueLimit(t *testing.T) {
+	q := eventQueue{}
+
+	for i := 0; i < 5; i++ {
+		q.push(queuedEvent{seq: uint64(i), topic: TopicNotify, msg: i}, Policy{Limit: 3})
+	}
+
+	var got []tea.Msg
+	for ev, ok := q.pop(); ok; ev, ok = q.pop() {
+		got = append(got, ev.msg)
+	}
+
+	if want := []tea.Msg{2, 3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the oldest events to be dropped, got %v, want %v", got, want)
+	}
+}
+
+func TestEventQueueCoalesce(t *testing.T) {
+	q := eventQueue{}
+	policy := Policy{Limit: 2, Coalesce: true}
+
+	for i, key := range []string{"a", "b", "a", "a", "b"} {
+		msg := keyedMsg{key: key, value: i}
+		q.push(queuedEvent{seq: uint64(i), topic: TopicQuery, key: coalesceKey(msg), msg: msg}, policy)
+	}
+
+	var got []tea.Msg
+	for ev, ok := q.pop(); ok; ev, ok = q.pop() {
+		got = append(got, ev.msg)
+	}
+
+	// Coalesced events don't count towards the limit, and newer events take
+	// the place of the replaced ones.
+	want := []tea.Msg{keyedMsg{key: "a", value: 3}, keyedMsg{key: "b", value: 4}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestEventQueueKeep(t *testing.T) {
+	q := eventQueue{}
+	policy := DefaultPolicies[TopicNotify]
+	failed := NotifyMsg{Error: errors.New("failed")}
+
+	q.push(queuedEvent{seq: 0, topic: TopicNotify, msg: failed}, policy)
+	for i := 1; i <= policy.Limit*2; i++ {
+		q.push(queuedEvent{seq: uint64(i), topic: TopicNotify, msg: NotifyMsg{Text: "info"}}, policy)
+	}
+
+	ev, ok := q.pop()
+	if !ok || !reflect.DeepEqual(ev.msg, failed) {
+		t.Errorf("expected the error to be kept, got %v", ev.msg)
+	}
+
+	count := 1
+	for _, ok = q.pop(); ok; _, ok = q.pop() {
+		count++
+	}
+
+	if count != policy.Limit {
+		t.Errorf("expected %d events, got %d", policy.Limit, count)
+	}
+}
+
+func TestEventBusOrdering(t *testing.T) {
+	b := NewEventBus(DefaultPolicies)
+	b.Subscribe(ViewRoot, TopicNotify, TopicBuilds)
+	b.Subscribe(ViewBuild, TopicBuilds)
+
+	b.Publish(TopicBuilds, 1)
+	b.Publish(TopicNotify, 2)
+	b.Publish(TopicBuilds, 3)
+
+	want := []ViewMsg{
+		{View: ViewRoot, Msg: 1},
+		{View: ViewBuild, Msg: 1},
+		{View: ViewRoot, Msg: 2},
+		{View: ViewRoot, Msg: 3},
+		{View: ViewBuild, Msg: 3},
+	}
+
+	if got := drain(b); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected events in publish order %v, got %v", want, got)
+	}
+}
+
+func TestEventBusUnclaimed(t *testing.T) {
+	b := NewEventBus(DefaultPolicies)
+
+	// Published before anyone subscribed.
+	b.Publish(TopicNotify, 1)
+	b.Publish(TopicBuilds, 2)
+	b.Publish(TopicNotify, 3)
+
+	b.Subscribe(ViewRoot, TopicNotify)
+	b.Subscribe(ViewBuild, TopicNotify, TopicBuilds)
+
+	want := []ViewMsg{
+		{View: ViewRoot, Msg: 1},
+		{View: ViewBuild, Msg: 2},
+		{View: ViewRoot, Msg: 3},
+	}
+
+	if got := drain(b); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected unclaimed events to go to the first subscriber %v, got %v", want, got)
+	}
+
+	b.Publish(TopicNotify, 4)
+
+	want = []ViewMsg{
+		{View: ViewRoot, Msg: 4},
+		{View: ViewBuild, Msg: 4},
+	}
+
+	if got := drain(b); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
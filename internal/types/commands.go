@@ -5,6 +5,7 @@
 package types
 
 import (
+	"fmt"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -43,12 +44,68 @@ func (m FilterMsg) Value() string {
 type FlyEvent int
 
 const (
-	FlyTargetsUpdated FlyEvent = iota + 1
-	FlyActiveTargetUpdated
+	FlyActiveTargetUpdated FlyEvent = iota + 1
+	FlyAuthUpdated
+	FlyStaleUpdated
+	FlyCompatUpdated
 )
 
+// CoalesceKey implements Coalescer, so only events of the same kind replace
+// each other.
+func (e FlyEvent) CoalesceKey() string {
+	return fmt.Sprintf("fly-event-%d", e)
+}
+
+// FlyTargetsChangedMsg is sent when targets in the flyrc are added, removed or
+// changed (e.g. a token refreshed by `fly login`).
+type FlyTargetsChangedMsg struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
 type LoadingMsg struct {
 	Text string
 }
 
+// CoalesceKey implements Coalescer, as only the latest loading state matters.
+func (LoadingMsg) CoalesceKey() string { return "loading" }
+
 type CancelLoadingMsg struct{}
+
+// CoalesceKey implements Coalescer, as only the latest loading state matters.
+func (CancelLoadingMsg) CoalesceKey() string { return "loading" }
+
+// NotifyMsg is a short-lived notification, shown in the status bar. If Error
+// is set, the notification is rendered as an error.
+type NotifyMsg struct {
+	Text  string
+	Error error
+}
+
+// Command is a command that can be invoked through the command bar (e.g.
+// ":execute -c task.yml").
+type Command struct {
+	Name  string
+	Usage string
+	Desc  string
+
+	// Run is invoked with the arguments provided after the command name.
+	Run func(args []string) tea.Cmd
+}
+
+// InvokeCommandMsg is sent when the user submits a command through the
+// command bar.
+type InvokeCommandMsg struct {
+	Name string
+	Args []string
+}
+
+// ConfirmMsg asks the user to confirm an action before Cmd is invoked. Body is
+// optional, and can be used to show additional context (e.g. a preview of the
+// changes).
+type ConfirmMsg struct {
+	Title string
+	Body  string
+	Cmd   tea.Cmd
+}
@@ -5,5 +5,10 @@
 package types
 
 type Flags struct {
-	Target string `short:"t" long:"target" description:"fly target to use"`
+	Target   string `short:"t" long:"target" description:"fly target to use"`
+	CacheDir string `long:"cache-dir" description:"directory to store offline snapshots in (defaults to the user cache directory)"`
+	Config   string `short:"c" long:"config" description:"path to the hangar-ui config file (defaults to hangar-ui/config.yaml in the user config directory)"`
+	FlyPath  string `long:"fly-path" description:"path the sync-fly command installs fly to (defaults to fly in $PATH)"`
+	Record   string `long:"record" description:"record all requests to the targets to a cassette file, with credentials redacted"`
+	Replay   string `long:"replay" description:"replay a cassette file recorded with --record, without connecting to any target"`
 }
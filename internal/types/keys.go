@@ -43,6 +43,14 @@ var (
 		key.WithKeys("ctrl+r"),
 		key.WithHelp("ctrl+r", "refresh"),
 	)
+	KeyConfirm = key.NewBinding(
+		key.WithKeys("y"),
+		key.WithHelp("y", "confirm"),
+	)
+	KeyDeny = key.NewBinding(
+		key.WithKeys("n"),
+		key.WithHelp("n", "deny"),
+	)
 	KeyLogin = key.NewBinding(
 		key.WithKeys("l"),
 		key.WithHelp("l", "login"),
@@ -58,6 +66,20 @@ var (
 		key.WithHelp("ctrl+t", "sort by time"),
 	)
 
+	// Targets view keys.
+	KeyAddTarget = key.NewBinding(
+		key.WithKeys("a"),
+		key.WithHelp("a", "add target"),
+	)
+	KeyEditTarget = key.NewBinding(
+		key.WithKeys("e"),
+		key.WithHelp("e", "edit/rename target"),
+	)
+	KeyDeleteTarget = key.NewBinding(
+		key.WithKeys("d", "delete"),
+		key.WithHelp("d", "delete target"),
+	)
+
 	// Command bar keys.
 	KeyCmdFilter = key.NewBinding(
 		key.WithKeys("/", "ctrl+f"),
@@ -77,4 +99,52 @@ var (
 		key.WithKeys("a"),
 		key.WithHelp("a", "toggle archived"),
 	)
+	KeyEdit = key.NewBinding(
+		key.WithKeys("e"),
+		key.WithHelp("e", "edit config"),
+	)
+	KeyAllTargets = key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "toggle all targets"),
+	)
+
+	// Trigger view keys.
+	KeyTrigger = key.NewBinding(
+		key.WithKeys("t"),
+		key.WithHelp("t", "trigger with selected versions"),
+	)
+	KeySelect = key.NewBinding(
+		key.WithKeys("enter", " "),
+		key.WithHelp("enter", "select version"),
+	)
+
+	// Login view keys.
+	KeyNextField = key.NewBinding(
+		key.WithKeys("tab", "down"),
+		key.WithHelp("tab", "next field"),
+	)
+	KeyPrevField = key.NewBinding(
+		key.WithKeys("shift+tab", "up"),
+		key.WithHelp("shift+tab", "previous field"),
+	)
+	KeyBrowserLogin = key.NewBinding(
+		key.WithKeys("ctrl+o"),
+		key.WithHelp("ctrl+o", "login with browser"),
+	)
+
+	// Requests view keys.
+	KeyClearRequests = key.NewBinding(
+		key.WithKeys("c"),
+		key.WithHelp("c", "clear requests"),
+	)
+
+	// Build view keys.
+	KeyAbort = key.NewBinding(
+		key.WithKeys("ctrl+x"),
+		key.WithHelp("ctrl+x", "abort build"),
+	)
+	KeyFollow = key.NewBinding(
+		key.WithKeys("f"),
+		key.WithHelp("f", "toggle follow"),
+	)
 )
@@ -19,6 +19,7 @@ type ThemeConfig struct {
 
 	SuccessFg lipgloss.AdaptiveColor
 	FailureFg lipgloss.AdaptiveColor
+	WarningFg lipgloss.AdaptiveColor
 
 	ViewBorderActiveFg   lipgloss.AdaptiveColor
 	ViewBorderInactiveFg lipgloss.AdaptiveColor
@@ -60,6 +61,7 @@ func SetTheme(style string) {
 
 			SuccessFg: lipgloss.AdaptiveColor{Dark: "#69ff94", Light: "#69ff94"},
 			FailureFg: lipgloss.AdaptiveColor{Dark: "#ff6e6e", Light: "#ff6e6e"},
+			WarningFg: lipgloss.AdaptiveColor{Dark: "#ffb86c", Light: "#ffb86c"},
 
 			ViewBorderActiveFg:   lipgloss.AdaptiveColor{Dark: "#A550DF", Light: "#A550DF"},
 			ViewBorderInactiveFg: lipgloss.AdaptiveColor{Dark: "#D9DCCF", Light: "#D9DCCF"},
@@ -9,15 +9,23 @@ import tea "github.com/charmbracelet/bubbletea"
 type Viewable string
 
 const (
-	ViewRoot        Viewable = "main"
-	ViewCommandBar  Viewable = "commandbar"
-	ViewStatusBar   Viewable = "statusbar"
-	ViewNavigation  Viewable = "navigation"
-	ViewHelp        Viewable = "help"
-	ViewPipelines   Viewable = "pipelines"
-	ViewTargets     Viewable = "targets"
-	ViewAbout       Viewable = "about"
-	SubViewSomeItem Viewable = "someitem"
+	ViewApp          Viewable = "app"
+	ViewRoot         Viewable = "main"
+	ViewCommandBar   Viewable = "commandbar"
+	ViewStatusBar    Viewable = "statusbar"
+	ViewNavigation   Viewable = "navigation"
+	ViewHelp         Viewable = "help"
+	ViewPipelines    Viewable = "pipelines"
+	ViewTargets      Viewable = "targets"
+	ViewAbout        Viewable = "about"
+	ViewBuild        Viewable = "build"
+	ViewConfirm      Viewable = "confirm"
+	ViewTrigger      Viewable = "trigger"
+	ViewLogin        Viewable = "login"
+	ViewOnboarding   Viewable = "onboarding"
+	ViewTargetEditor Viewable = "target-editor"
+	ViewRequests     Viewable = "requests"
+	SubViewSomeItem  Viewable = "someitem"
 )
 
 // ViewChangeMsg is sent when the primary view is changed (not necessarily focused).
@@ -6,6 +6,8 @@ package ui
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/apex/log"
 	"github.com/charmbracelet/bubbles/key"
@@ -13,6 +15,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	zone "github.com/lrstanley/bubblezone"
 	"github.com/lrstanley/clix"
+	"github.com/lrstanley/hangar-ui/internal/api"
 	"github.com/lrstanley/hangar-ui/internal/types"
 	"github.com/lrstanley/hangar-ui/internal/ui/model"
 	"github.com/lrstanley/hangar-ui/internal/ui/view"
@@ -26,6 +29,7 @@ var _ types.App = &App{}
 type App struct {
 	// Core.
 	cli    *clix.CLI[types.Flags]
+	client api.Manager
 	logger log.Interface
 	keys   *model.KeyMap
 
@@ -42,9 +46,12 @@ type App struct {
 	active   types.Viewable
 	previous types.Viewable
 	views    map[types.Viewable]view.View
+	commands map[string]types.Command
 }
 
-func New(_ context.Context, cli *clix.CLI[types.Flags]) *App {
+// New creates the root application model. client is used for all API queries
+// and actions, and is shared with all views.
+func New(_ context.Context, cli *clix.CLI[types.Flags], client api.Manager) *App {
 	// See: https://github.com/charmbracelet/lipgloss/issues/73
 	lipgloss.SetHasDarkBackground(termenv.HasDarkBackground())
 
@@ -52,6 +59,7 @@ func New(_ context.Context, cli *clix.CLI[types.Flags]) *App {
 
 	a := &App{
 		cli:    cli,
+		client: client,
 		logger: log.WithField("src", "app"),
 
 		focused:  types.ViewRoot,
@@ -61,6 +69,10 @@ func New(_ context.Context, cli *clix.CLI[types.Flags]) *App {
 	}
 
 	a.keys = model.NewKeyMap(a)
+	a.registerCommands()
+
+	// Logins can complete outside of the login view (e.g. `fly login`).
+	client.Subscribe(types.ViewApp, types.TopicLogin)
 
 	a.commandbar = model.NewCommandBar(a)
 	a.navbar = model.NewNavBar(a, []types.Viewable{
@@ -69,12 +81,26 @@ func New(_ context.Context, cli *clix.CLI[types.Flags]) *App {
 		types.ViewTargets,
 		types.ViewHelp,
 	})
-	a.statusbar = model.NewStatusBar(a, a.keys)
+	a.statusbar = model.NewStatusBar(a, a.keys, client)
 
 	a.views[types.ViewRoot] = view.NewRoot(a)
 	a.views[types.ViewHelp] = view.NewHelp(a, a.keys)
-	a.views[types.ViewPipelines] = view.NewPipelines(a)
-	a.views[types.ViewTargets] = view.NewTargets(a)
+	a.views[types.ViewPipelines] = view.NewPipelines(a, client)
+	a.views[types.ViewTargets] = view.NewTargets(a, client)
+	a.views[types.ViewBuild] = view.NewBuild(a, client)
+	a.views[types.ViewConfirm] = view.NewConfirm(a)
+	a.views[types.ViewTrigger] = view.NewTrigger(a, client)
+	a.views[types.ViewLogin] = view.NewLogin(a, client)
+	a.views[types.ViewOnboarding] = view.NewOnboarding(a, client)
+	a.views[types.ViewTargetEditor] = view.NewTargetEditor(a, client)
+	a.views[types.ViewRequests] = view.NewRequests(a, client)
+
+	// Without a usable target, start with onboarding to add one.
+	if client.ActiveName() == "" {
+		a.focused = types.ViewOnboarding
+		a.active = types.ViewOnboarding
+		a.previous = types.ViewOnboarding
+	}
 
 	// Send initial sizes to all views.
 	vh, vw := a.getViewSize()
@@ -111,15 +137,22 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		cmdFocused := a.IsFocused(types.ViewCommandBar)
 
+		// Views with text input (other than the command bar) receive all keys,
+		// except quit.
+		inputFocused := cmdFocused ||
+			a.IsFocused(types.ViewLogin) ||
+			a.IsFocused(types.ViewOnboarding) ||
+			a.IsFocused(types.ViewTargetEditor)
+
 		switch {
-		case key.Matches(msg, types.KeyCmdFilter) && !cmdFocused:
+		case key.Matches(msg, types.KeyCmdFilter) && !inputFocused:
 			_, cmd = a.commandbar.Update(model.MsgCmdFilter)
 			return a, tea.Batch(
 				cmd,
 				types.MsgAsCmd(types.FocusChangeMsg{View: types.ViewCommandBar}),
 			)
 
-		case key.Matches(msg, types.KeyCmdInvoke) && !cmdFocused:
+		case key.Matches(msg, types.KeyCmdInvoke) && !inputFocused:
 			_, cmd = a.commandbar.Update(model.MsgCmdInvoke)
 			return a, tea.Batch(
 				cmd,
@@ -129,7 +162,7 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case key.Matches(msg, types.KeyQuit):
 			return a, tea.Quit
 
-		case key.Matches(msg, types.KeyHelp) && !cmdFocused:
+		case key.Matches(msg, types.KeyHelp) && !inputFocused:
 			if a.IsFocused(types.ViewHelp) {
 				return a, types.MsgAsCmd(types.AppBackMsg{Focused: true})
 			}
@@ -157,10 +190,105 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		return a.propagateMessage(msg)
 
-	case types.ViewMsg: // A message for a specific view, propagated from a child.
-		_, cmd = a.views[msg.View].Update(msg.Msg)
+	case types.InvokeCommandMsg: // A command submitted through the command bar.
+		if a.client.ActiveName() == "" {
+			return a, notifyError(errNoTarget)
+		}
+
+		return a, a.invokeCommand(msg)
+
+	case types.ConfirmMsg: // A request to confirm an action before running it.
+		_, cmd = a.views[types.ViewConfirm].Update(msg)
+		return a, tea.Batch(
+			cmd,
+			types.MsgAsCmd(types.ViewChangeMsg{View: types.ViewConfirm}),
+			types.MsgAsCmd(types.FocusChangeMsg{View: types.ViewConfirm}),
+		)
+
+	case api.BuildStartedMsg:
+		// Always show builds once they start streaming. The build view itself
+		// receives the message through the event bus, along with the events.
+		return a, tea.Batch(
+			types.MsgAsCmd(types.ViewChangeMsg{View: types.ViewBuild}),
+			types.MsgAsCmd(types.FocusChangeMsg{View: types.ViewBuild}),
+		)
+
+	case api.CachesClearedMsg:
+		if msg.Error != nil {
+			return a, notifyError(fmt.Errorf("failed to clear %s: %w", msg.Name, msg.Error))
+		}
+
+		return a, types.MsgAsCmd(types.NotifyMsg{
+			Text: fmt.Sprintf("cleared %s: %d cache(s) removed", msg.Name, msg.Removed),
+		})
+
+	case api.LoginMsg:
+		_, cmd = a.views[types.ViewLogin].Update(msg)
+
+		if msg.Error != nil {
+			return a, tea.Batch(cmd, notifyError(fmt.Errorf("login to %q failed: %w", msg.Target, msg.Error)))
+		}
+
+		return a, tea.Batch(
+			cmd,
+			types.MsgAsCmd(types.NotifyMsg{Text: fmt.Sprintf("logged in to %q", msg.Target)}),
+			msg.Retry,
+		)
+
+	case api.LoginURLMsg:
+		_, cmd = a.views[types.ViewLogin].Update(msg)
 		return a, cmd
 
+	case api.TargetDeletedMsg:
+		if msg.Error != nil {
+			return a, notifyError(fmt.Errorf("failed to delete target %q: %w", msg.Target, msg.Error))
+		}
+
+		return a, types.MsgAsCmd(types.NotifyMsg{Text: fmt.Sprintf("deleted target %q", msg.Target)})
+
+	case api.ConfigReloadedMsg:
+		if msg.Error != nil {
+			return a, notifyError(fmt.Errorf("failed to reload config: %w", msg.Error))
+		}
+
+		return a, types.MsgAsCmd(types.NotifyMsg{Text: fmt.Sprintf("reloaded config from %s", msg.Path)})
+
+	case api.FlySyncedMsg:
+		switch {
+		case msg.Error != nil:
+			return a, notifyError(fmt.Errorf("failed to sync fly: %w", msg.Error))
+		case msg.UpToDate:
+			return a, types.MsgAsCmd(types.NotifyMsg{Text: fmt.Sprintf("fly %s already matches target %q", msg.After, msg.Target)})
+		case msg.Before == "":
+			return a, types.MsgAsCmd(types.NotifyMsg{Text: fmt.Sprintf("installed fly %s to %s", msg.After, msg.Path)})
+		}
+
+		return a, types.MsgAsCmd(types.NotifyMsg{
+			Text: fmt.Sprintf("synced fly from %s to %s (%s)", msg.Before, msg.After, msg.Path),
+		})
+
+	case api.PausePlanMsg:
+		return a, a.confirmPausePlan(msg)
+
+	case api.PauseAppliedMsg:
+		if msg.Error != nil {
+			return a, notifyError(fmt.Errorf("%d change(s) applied, some failed: %w", msg.Applied, msg.Error))
+		}
+
+		text := fmt.Sprintf("paused %d pipeline(s), snapshot saved to %s", msg.Applied, msg.Plan.Path)
+		if msg.Plan.Restore {
+			text = fmt.Sprintf("restored %d pipeline(s)/job(s) from snapshot", msg.Applied)
+		}
+
+		// Refresh through the scheduler, so the result goes to the pipelines
+		// view like any other poll, and any backoff is reset.
+		a.client.Refresh(api.PollPipelines)
+
+		return a, types.MsgAsCmd(types.NotifyMsg{Text: text})
+
+	case types.ViewMsg: // A message for a specific view (e.g. from the event bus).
+		return a.updateComponent(msg.View, msg.Msg)
+
 	case types.AppBackMsg: // A message to go back to the previous view.
 		a.active, a.previous = a.previous, a.active
 
@@ -168,6 +296,8 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			a.active = types.ViewRoot
 		}
 
+		a.client.SetVisible(a.active)
+
 		if msg.Focused {
 			return a, tea.Batch(
 				types.MsgAsCmd(types.ViewChangeMsg{View: a.active}),
@@ -182,12 +312,18 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return a, nil
 		}
 
+		if !availableWithoutTarget(msg.View) && a.client.ActiveName() == "" {
+			return a, notifyError(errNoTarget)
+		}
+
 		a.previous = a.active
 		a.active = msg.View
 
 		if a.previous == types.ViewHelp {
 			a.previous = types.ViewRoot
 		}
+
+		a.client.SetVisible(a.active)
 		return a.propagateMessage(msg)
 
 	case types.FocusChangeMsg: // A message to change the focused view.
@@ -195,6 +331,10 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return a, nil
 		}
 
+		if !availableWithoutTarget(msg.View) && a.client.ActiveName() == "" {
+			return a, nil
+		}
+
 		a.focused = msg.View
 		return a.propagateMessage(msg)
 	}
@@ -202,6 +342,44 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return a.propagateMessage(msg)
 }
 
+// errNoTarget is shown when trying to use views or commands which require a
+// target, before one has been added.
+var errNoTarget = errors.New("no target configured, add one first")
+
+// availableWithoutTarget returns true if the view can be used without an active
+// target (i.e. during onboarding).
+func availableWithoutTarget(v types.Viewable) bool {
+	switch v {
+	case types.ViewOnboarding, types.ViewLogin, types.ViewHelp, types.ViewRoot:
+		return true
+	default:
+		return false
+	}
+}
+
+// updateComponent sends msg to a single component (a view, one of the bars, or
+// the app itself), rather than propagating it to all of them.
+func (a *App) updateComponent(v types.Viewable, msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	switch v {
+	case types.ViewApp:
+		return a.Update(msg)
+	case types.ViewCommandBar:
+		_, cmd = a.commandbar.Update(msg)
+	case types.ViewNavigation:
+		_, cmd = a.navbar.Update(msg)
+	case types.ViewStatusBar:
+		_, cmd = a.statusbar.Update(msg)
+	default:
+		if view, ok := a.views[v]; ok {
+			_, cmd = view.Update(msg)
+		}
+	}
+
+	return a, cmd
+}
+
 func (a *App) propagateMessage(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmds []tea.Cmd
 	var cmd tea.Cmd
@@ -0,0 +1,393 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/concourse/concourse/fly/rc"
+	flags "github.com/jessevdk/go-flags"
+	"github.com/lrstanley/hangar-ui/internal/api"
+	"github.com/lrstanley/hangar-ui/internal/types"
+	"github.com/lrstanley/hangar-ui/internal/ui/view"
+)
+
+func (a *App) registerCommands() {
+	a.commands = map[string]types.Command{}
+
+	for _, cmd := range []types.Command{
+		{
+			Name:  "execute",
+			Usage: "-c <task.yml> [-i name=path]... [-o name=path]... [-j pipeline/job] [-p] [--tag tag]...",
+			Desc:  "execute a one-off task, like `fly execute`",
+			Run:   a.cmdExecute,
+		},
+		{
+			Name:  "clear-task-cache",
+			Usage: "-j <pipeline/job> -s <step> [-c cache-path]",
+			Desc:  "clear the task cache of a job step",
+			Run:   a.cmdClearTaskCache,
+		},
+		{
+			Name:  "clear-resource-cache",
+			Usage: "-r <pipeline/resource> [-v key:value]...",
+			Desc:  "clear the version caches of a resource",
+			Run:   a.cmdClearResourceCache,
+		},
+		{
+			Name:  "trigger-with",
+			Usage: "-j <pipeline/job>",
+			Desc:  "pick specific input versions, and trigger a job with them",
+			Run:   a.cmdTriggerWith,
+		},
+		{
+			Name:  "all-targets",
+			Usage: "[target]...",
+			Desc:  "show the pipelines of all (or the provided) targets at once",
+			Run:   a.cmdAllTargets,
+		},
+		{
+			Name:  "sync-fly",
+			Usage: "[-p path] [-f]",
+			Desc:  "download fly matching the version of the target, like `fly sync`",
+			Run:   a.cmdSyncFly,
+		},
+		{
+			Name: "reload-config",
+			Desc: "reload the config file, and apply its settings to all targets",
+			Run:  a.cmdReloadConfig,
+		},
+		{
+			Name: "requests",
+			Desc: "inspect the HTTP requests made to the ATC",
+			Run:  a.cmdRequests,
+		},
+		{
+			Name:  "pause-all",
+			Usage: "[--team team] [-f snapshot.json]",
+			Desc:  "snapshot the paused state of all pipelines, and pause them",
+			Run:   a.cmdPauseAll,
+		},
+		{
+			Name:  "restore-paused",
+			Usage: "[-f snapshot.json]",
+			Desc:  "unpause everything that was running when a snapshot was taken",
+			Run:   a.cmdRestorePaused,
+		},
+	} {
+		a.commands[cmd.Name] = cmd
+	}
+}
+
+// Commands returns all registered commands, sorted by name.
+func (a *App) Commands() []types.Command {
+	cmds := make([]types.Command, 0, len(a.commands))
+	for _, cmd := range a.commands {
+		cmds = append(cmds, cmd)
+	}
+
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name < cmds[j].Name
+	})
+
+	return cmds
+}
+
+func (a *App) invokeCommand(msg types.InvokeCommandMsg) tea.Cmd {
+	cmd, ok := a.commands[msg.Name]
+	if !ok {
+		return types.MsgAsCmd(types.NotifyMsg{Error: fmt.Errorf("unknown command %q", msg.Name)})
+	}
+
+	a.logger.WithField("command", msg.Name).Debug("invoking command")
+
+	return cmd.Run(msg.Args)
+}
+
+// parseCommandFlags parses args into the provided flags struct, returning any
+// remaining positional arguments.
+func parseCommandFlags(name string, data any, args []string) ([]string, error) {
+	parser := flags.NewNamedParser(name, flags.PassDoubleDash)
+
+	if _, err := parser.AddGroup(name, "", data); err != nil {
+		return nil, err
+	}
+
+	return parser.ParseArgs(args)
+}
+
+// parsePairs parses "key=value" pairs into a map.
+func parsePairs(pairs []string) (map[string]string, error) {
+	out := make(map[string]string, len(pairs))
+
+	for _, pair := range pairs {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" || v == "" {
+			return nil, fmt.Errorf("invalid pair %q, expected NAME=PATH", pair)
+		}
+
+		out[k] = v
+	}
+
+	return out, nil
+}
+
+func notifyError(err error) tea.Cmd {
+	return types.MsgAsCmd(types.NotifyMsg{Error: err})
+}
+
+type executeFlags struct {
+	Config         string   `short:"c" long:"config" required:"true" description:"task config to execute"`
+	Privileged     bool     `short:"p" long:"privileged" description:"run the task with full privileges"`
+	IncludeIgnored bool     `long:"include-ignored" description:"include .gitignored paths"`
+	Inputs         []string `short:"i" long:"input" value-name:"NAME=PATH" description:"an input to provide to the task"`
+	InputsFrom     string   `short:"j" long:"inputs-from" value-name:"PIPELINE/JOB" description:"a job to base the inputs on"`
+	Outputs        []string `short:"o" long:"output" value-name:"NAME=PATH" description:"an output to fetch from the task"`
+	Tags           []string `long:"tag" value-name:"TAG" description:"a tag for a specific environment"`
+}
+
+func (a *App) cmdExecute(args []string) tea.Cmd {
+	var f executeFlags
+
+	if _, err := parseCommandFlags("execute", &f, args); err != nil {
+		return notifyError(err)
+	}
+
+	inputs, err := parsePairs(f.Inputs)
+	if err != nil {
+		return notifyError(err)
+	}
+
+	outputs, err := parsePairs(f.Outputs)
+	if err != nil {
+		return notifyError(err)
+	}
+
+	return tea.Batch(
+		types.MsgAsCmd(types.ViewChangeMsg{View: types.ViewBuild}),
+		types.MsgAsCmd(types.FocusChangeMsg{View: types.ViewBuild}),
+		a.client.Execute(api.ExecuteOptions{
+			ConfigPath:     f.Config,
+			Inputs:         inputs,
+			InputsFrom:     f.InputsFrom,
+			Outputs:        outputs,
+			Privileged:     f.Privileged,
+			IncludeIgnored: f.IncludeIgnored,
+			Tags:           f.Tags,
+		}),
+	)
+}
+
+type clearTaskCacheFlags struct {
+	Job       string `short:"j" long:"job" required:"true" value-name:"PIPELINE/JOB" description:"job to clear the cache for"`
+	Step      string `short:"s" long:"step" required:"true" description:"step name to clear the cache for"`
+	CachePath string `short:"c" long:"cache-path" description:"cache directory to clear (all caches if omitted)"`
+}
+
+func (a *App) cmdClearTaskCache(args []string) tea.Cmd {
+	var f clearTaskCacheFlags
+
+	if _, err := parseCommandFlags("clear-task-cache", &f, args); err != nil {
+		return notifyError(err)
+	}
+
+	pipeline, job, err := api.ParseRef(f.Job)
+	if err != nil {
+		return notifyError(err)
+	}
+
+	title := fmt.Sprintf("clear task cache of step %q in %s?", f.Step, f.Job)
+	if f.CachePath != "" {
+		title = fmt.Sprintf("clear task cache %q of step %q in %s?", f.CachePath, f.Step, f.Job)
+	}
+
+	return types.MsgAsCmd(types.ConfirmMsg{
+		Title: title,
+		Cmd:   a.client.ClearTaskCache(pipeline, job, f.Step, f.CachePath),
+	})
+}
+
+type clearResourceCacheFlags struct {
+	Resource string            `short:"r" long:"resource" required:"true" value-name:"PIPELINE/RESOURCE" description:"resource to clear the caches of"`
+	Version  map[string]string `short:"v" long:"version" value-name:"KEY:VALUE" description:"only clear caches of versions matching this filter"`
+}
+
+func (a *App) cmdClearResourceCache(args []string) tea.Cmd {
+	var f clearResourceCacheFlags
+
+	if _, err := parseCommandFlags("clear-resource-cache", &f, args); err != nil {
+		return notifyError(err)
+	}
+
+	if err := a.client.Supports(a.client.ActiveName(), api.FeatureClearResourceCache); err != nil {
+		return notifyError(err)
+	}
+
+	pipeline, resource, err := api.ParseRef(f.Resource)
+	if err != nil {
+		return notifyError(err)
+	}
+
+	title := fmt.Sprintf("clear all version caches of %s?", f.Resource)
+	if len(f.Version) > 0 {
+		title = fmt.Sprintf("clear version caches of %s matching %v?", f.Resource, f.Version)
+	}
+
+	return types.MsgAsCmd(types.ConfirmMsg{
+		Title: title,
+		Cmd:   a.client.ClearResourceCache(pipeline, resource, f.Version),
+	})
+}
+
+type syncFlyFlags struct {
+	Path  string `short:"p" long:"path" description:"path to install fly to (defaults to --fly-path, or fly in $PATH)"`
+	Force bool   `short:"f" long:"force" description:"download fly, even if it already matches the version of the target"`
+}
+
+func (a *App) cmdSyncFly(args []string) tea.Cmd {
+	var f syncFlyFlags
+
+	if _, err := parseCommandFlags("sync-fly", &f, args); err != nil {
+		return notifyError(err)
+	}
+
+	if f.Path == "" {
+		f.Path = a.cli.Flags.FlyPath
+	}
+
+	path, err := api.ResolveFlyPath(f.Path)
+	if err != nil {
+		return notifyError(err)
+	}
+
+	return types.MsgAsCmd(types.ConfirmMsg{
+		Title: fmt.Sprintf("download fly from target %q, and install it to %s?", a.client.ActiveName(), path),
+		Cmd:   a.client.SyncFly(path, f.Force),
+	})
+}
+
+func (a *App) cmdReloadConfig(_ []string) tea.Cmd {
+	return a.client.ReloadConfig()
+}
+
+func (a *App) cmdRequests(_ []string) tea.Cmd {
+	return tea.Batch(
+		types.MsgAsCmd(types.ViewChangeMsg{View: types.ViewRequests}),
+		types.MsgAsCmd(types.FocusChangeMsg{View: types.ViewRequests}),
+	)
+}
+
+type triggerWithFlags struct {
+	Job string `short:"j" long:"job" required:"true" value-name:"PIPELINE/JOB" description:"job to trigger"`
+}
+
+func (a *App) cmdTriggerWith(args []string) tea.Cmd {
+	var f triggerWithFlags
+
+	if _, err := parseCommandFlags("trigger-with", &f, args); err != nil {
+		return notifyError(err)
+	}
+
+	pipeline, job, err := api.ParseRef(f.Job)
+	if err != nil {
+		return notifyError(err)
+	}
+
+	return tea.Batch(
+		types.MsgAsCmd(types.ViewChangeMsg{View: types.ViewTrigger}),
+		types.MsgAsCmd(types.FocusChangeMsg{View: types.ViewTrigger}),
+		a.client.QueryJobInputVersions(types.ViewTrigger, pipeline, job),
+	)
+}
+
+func (a *App) cmdAllTargets(args []string) tea.Cmd {
+	targets := a.client.Targets()
+
+	for _, name := range args {
+		if _, ok := targets[rc.TargetName(name)]; !ok {
+			return notifyError(rc.UnknownTargetError{TargetName: rc.TargetName(name)})
+		}
+	}
+
+	return tea.Batch(
+		types.MsgAsCmd(types.ViewMsg{View: types.ViewPipelines, Msg: view.AllTargetsMsg{Targets: args}}),
+		types.MsgAsCmd(types.ViewChangeMsg{View: types.ViewPipelines}),
+		types.MsgAsCmd(types.FocusChangeMsg{View: types.ViewPipelines}),
+	)
+}
+
+type pauseAllFlags struct {
+	Team string `long:"team" description:"only pause pipelines of this team"`
+	File string `short:"f" long:"file" description:"where to write the snapshot (defaults to the snapshot directory)"`
+}
+
+func (a *App) cmdPauseAll(args []string) tea.Cmd {
+	var f pauseAllFlags
+
+	if _, err := parseCommandFlags("pause-all", &f, args); err != nil {
+		return notifyError(err)
+	}
+
+	return a.client.PlanPauseAll(f.Team, f.File)
+}
+
+type restorePausedFlags struct {
+	File string `short:"f" long:"file" description:"snapshot to restore (defaults to the latest for the active target)"`
+}
+
+func (a *App) cmdRestorePaused(args []string) tea.Cmd {
+	var f restorePausedFlags
+
+	if _, err := parseCommandFlags("restore-paused", &f, args); err != nil {
+		return notifyError(err)
+	}
+
+	if f.File == "" {
+		var err error
+
+		f.File, err = api.LatestSnapshot(a.client.ActiveName())
+		if err != nil {
+			return notifyError(err)
+		}
+	}
+
+	return a.client.PlanRestorePaused(f.File)
+}
+
+// confirmPausePlan shows a dry-run preview of a pause plan, applying it once
+// confirmed.
+func (a *App) confirmPausePlan(plan api.PausePlanMsg) tea.Cmd {
+	if plan.Error != nil {
+		return notifyError(plan.Error)
+	}
+
+	if len(plan.Changes) == 0 {
+		return types.MsgAsCmd(types.NotifyMsg{Text: "nothing to change, everything is already in the expected state"})
+	}
+
+	var buf strings.Builder
+
+	if plan.Restore {
+		fmt.Fprintf(&buf, "restoring snapshot %s (taken %s)\n\n", plan.Path, plan.Snapshot.CreatedAt.Format(time.RFC1123))
+	} else {
+		fmt.Fprintf(&buf, "snapshot of %d pipeline(s) will be written to %s\n\n", len(plan.Snapshot.Pipelines), plan.Path)
+	}
+
+	for _, change := range plan.Changes {
+		buf.WriteString(change.String() + "\n")
+	}
+
+	title := fmt.Sprintf("dry-run: %d change(s) will be made on %s, apply?", len(plan.Changes), plan.Snapshot.Target)
+
+	return types.MsgAsCmd(types.ConfirmMsg{
+		Title: title,
+		Body:  buf.String(),
+		Cmd:   a.client.ApplyPausePlan(plan),
+	})
+}
@@ -5,6 +5,8 @@
 package model
 
 import (
+	"strings"
+
 	"github.com/apex/log"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textinput"
@@ -170,6 +172,10 @@ func (m *CommandBar) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// TODO: switch to command view.
 				// Also forward up/down/enter when active with the command view,
 				// to select the necessary command.
+				if args := splitArgs(m.input.Value()); len(args) > 0 {
+					cmds = append(cmds, types.MsgAsCmd(types.InvokeCommandMsg{Name: args[0], Args: args[1:]}))
+				}
+
 				m.method = MsgNone
 				_ = m.input.Reset()
 				cmds = append(cmds, types.MsgAsCmd(types.FocusChangeMsg{View: m.app.Active()}))
@@ -207,3 +213,39 @@ func (m *CommandBar) View() string {
 
 	return zone.Mark("navbar", s.Render(m.prefixStyle.Render("["+prefix+"]")+input))
 }
+
+// splitArgs splits a command line into arguments, using whitespace as the
+// separator. Single and double quotes can be used to include whitespace within
+// an argument.
+func splitArgs(input string) (args []string) {
+	var buf strings.Builder
+	var quote rune
+	var inArg bool
+
+	for _, r := range input {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			buf.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, buf.String())
+				buf.Reset()
+				inArg = false
+			}
+		default:
+			buf.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, buf.String())
+	}
+
+	return args
+}
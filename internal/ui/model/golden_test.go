@@ -0,0 +1,27 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package model_test
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/lrstanley/hangar-ui/internal/fakeatc"
+	"github.com/lrstanley/hangar-ui/internal/ui/uitest"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// newHarness returns a settled harness, driving the UI against a fake ATC
+// serving state.
+func newHarness(t *testing.T, state *fakeatc.State) (*uitest.Harness, *fakeatc.Server) {
+	t.Helper()
+
+	h, srv := uitest.NewFake(t, state)
+	h.Update = *update
+	h.Settle()
+
+	return h, srv
+}
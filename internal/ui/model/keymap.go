@@ -40,10 +40,54 @@ func NewKeyMap(app types.App) *KeyMap {
 				types.KeyShowArchived,
 				types.KeySortName,
 				types.KeySortTime,
+				types.KeyEdit,
+				types.KeyAllTargets,
 			},
 			types.ViewTargets: {
 				types.KeyRefresh,
 				types.KeyLogin,
+				types.KeyAddTarget,
+				types.KeyEditTarget,
+				types.KeyDeleteTarget,
+			},
+			types.ViewConfirm: {
+				types.KeyConfirm,
+				types.KeyDeny,
+				types.KeyCancel,
+			},
+			types.ViewTrigger: {
+				types.KeyRefresh,
+				types.KeySelect,
+				types.KeyTrigger,
+			},
+			types.ViewLogin: {
+				types.KeyCancel,
+				types.KeyNextField,
+				types.KeyPrevField,
+				types.KeyEnter,
+				types.KeyBrowserLogin,
+			},
+			types.ViewTargetEditor: {
+				types.KeyCancel,
+				types.KeyNextField,
+				types.KeyPrevField,
+				types.KeyEnter,
+			},
+			types.ViewOnboarding: {
+				types.KeyNextField,
+				types.KeyPrevField,
+				types.KeyEnter,
+			},
+			types.ViewRequests: {
+				types.KeyCancel,
+				types.KeyEnter,
+				types.KeyRefresh,
+				types.KeyClearRequests,
+			},
+			types.ViewBuild: {
+				types.KeyCancel,
+				types.KeyAbort,
+				types.KeyFollow,
 			},
 		},
 	}
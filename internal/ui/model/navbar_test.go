@@ -0,0 +1,33 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package model_test
+
+import (
+	"testing"
+
+	"github.com/lrstanley/hangar-ui/internal/fakeatc"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+func TestNavBar(t *testing.T) {
+	h, _ := newHarness(t, fakeatc.NewState())
+	h.AssertGolden("navbar_root")
+
+	h.Click(string(types.ViewPipelines))
+	h.Settle()
+	h.AssertGolden("navbar_pipelines")
+
+	h.Click(string(types.ViewRoot))
+	h.Settle()
+	h.AssertGolden("navbar_root")
+}
+
+func TestNavBarNarrow(t *testing.T) {
+	h, _ := newHarness(t, fakeatc.NewState())
+
+	h.Resize(60, 10)
+	h.Settle()
+	h.AssertGolden("navbar_narrow")
+}
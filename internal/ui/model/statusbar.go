@@ -5,10 +5,14 @@
 package model
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/apex/log"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/dustin/go-humanize"
 	"github.com/knipferrc/teacup/icons"
 	zone "github.com/lrstanley/bubblezone"
 	"github.com/lrstanley/hangar-ui/internal/api"
@@ -21,30 +25,46 @@ const (
 	helpEllipsis  = "…"
 )
 
+// notifyTimeout is how long notifications are shown in the status bar.
+const notifyTimeout = 5 * time.Second
+
 var icon = icons.IconSet["console"].GetGlyph()
 
+type clearNotifyMsg struct {
+	id int
+}
+
 type StatusBar struct {
 	*Base
 
-	keys *KeyMap
+	keys   *KeyMap
+	client api.Manager
 
-	Target string
-	URL    string
-	Logo   string
+	Target     string
+	URL        string
+	Logo       string
+	NeedsLogin bool
+	Stale      time.Time
+	Warnings   []string
 
 	loadingText string
 	spinner     spinner.Model
 
+	notifyText  string
+	notifyError bool
+	notifyID    int
+
 	baseStyle   lipgloss.Style
 	targetStyle lipgloss.Style
 	urlStyle    lipgloss.Style
 	logoStyle   lipgloss.Style
 	descStyle   lipgloss.Style
+	errorStyle  lipgloss.Style
 
 	separator string
 }
 
-func NewStatusBar(app types.App, keys *KeyMap) *StatusBar {
+func NewStatusBar(app types.App, keys *KeyMap, client api.Manager) *StatusBar {
 	m := &StatusBar{
 		Base: &Base{
 			app:    app,
@@ -53,13 +73,16 @@ func NewStatusBar(app types.App, keys *KeyMap) *StatusBar {
 			logger: log.WithField("src", "statusbar"),
 		},
 		keys:   keys,
-		Target: api.Manager.ActiveName(),
-		URL:    api.Manager.Active().URL(),
+		client: client,
+		Target: client.ActiveName(),
+		URL:    activeURL(client),
 		Logo:   "hangar-ui",
 
 		spinner: spinner.New(),
 	}
 
+	client.Subscribe(m.is, types.TopicState, types.TopicLoading, types.TopicNotify)
+
 	m.baseStyle = lipgloss.NewStyle().
 		Foreground(types.Theme.StatusBarFg).
 		Background(types.Theme.StatusBarBg)
@@ -79,6 +102,9 @@ func NewStatusBar(app types.App, keys *KeyMap) *StatusBar {
 	m.descStyle = m.baseStyle.Copy().
 		Foreground(types.Theme.StatusBarKeyDescFg)
 
+	m.errorStyle = m.baseStyle.Copy().
+		Foreground(types.Theme.FailureFg)
+
 	m.separator = m.baseStyle.Copy().
 		Foreground(types.Theme.StatusBarTargetBg).
 		Render(helpSeparator)
@@ -116,9 +142,19 @@ func (m *StatusBar) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 	case types.FlyEvent:
-		if msg == types.FlyActiveTargetUpdated {
-			m.Target = api.Manager.ActiveName()
-			m.URL = api.Manager.Active().URL()
+		switch msg {
+		case types.FlyActiveTargetUpdated:
+			m.Target = m.client.ActiveName()
+			m.URL = activeURL(m.client)
+			m.NeedsLogin = m.client.NeedsLogin(m.Target)
+			m.Stale = m.client.StaleSince(m.Target)
+			m.Warnings = m.client.Compat(m.Target)
+		case types.FlyAuthUpdated:
+			m.NeedsLogin = m.client.NeedsLogin(m.Target)
+		case types.FlyStaleUpdated:
+			m.Stale = m.client.StaleSince(m.Target)
+		case types.FlyCompatUpdated:
+			m.Warnings = m.client.Compat(m.Target)
 		}
 	case types.LoadingMsg:
 		m.loadingText = msg.Text
@@ -126,6 +162,21 @@ func (m *StatusBar) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case types.CancelLoadingMsg:
 		m.loadingText = ""
 		return m, nil
+	case types.NotifyMsg:
+		m.notifyID++
+		m.notifyText = msg.Text
+		m.notifyError = msg.Error != nil
+
+		if msg.Error != nil {
+			m.notifyText = msg.Error.Error()
+		}
+
+		return m, types.DelayMsg(notifyTimeout, clearNotifyMsg{id: m.notifyID})
+	case clearNotifyMsg:
+		if msg.id == m.notifyID {
+			m.notifyText = ""
+		}
+		return m, nil
 	case spinner.TickMsg:
 		var cmd tea.Cmd
 		m.spinner, cmd = m.spinner.Update(msg)
@@ -135,9 +186,42 @@ func (m *StatusBar) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, m.spinner.Tick
 }
 
+// activeURL returns the URL of the active target, if any.
+func activeURL(client api.Manager) string {
+	if t := client.Active(); t != nil {
+		return t.URL()
+	}
+
+	return ""
+}
+
 func (m *StatusBar) View() string {
 	target := m.targetStyle.Render(m.Target)
+
+	switch {
+	case m.Target == "":
+		target = m.targetStyle.Render("no target")
+	case m.NeedsLogin:
+		target = m.targetStyle.Copy().
+			Background(types.Theme.FailureFg).
+			Render(m.Target + " (needs login)")
+	case !m.Stale.IsZero():
+		target = m.targetStyle.Copy().
+			Background(types.Theme.FailureFg).
+			Render(m.Target + " (offline, data from " + humanize.Time(m.Stale) + ")")
+	case len(m.Warnings) == 1:
+		target = m.targetStyle.Copy().
+			Background(types.Theme.WarningFg).
+			Render(m.Target + " (" + m.Warnings[0] + ")")
+	case len(m.Warnings) > 1:
+		target = m.targetStyle.Copy().
+			Background(types.Theme.WarningFg).
+			Render(fmt.Sprintf("%s (%d version warnings)", m.Target, len(m.Warnings)))
+	}
 	url := m.urlStyle.Render(m.URL)
+	if m.URL == "" {
+		url = ""
+	}
 	logo := m.logoStyle.Render(m.Logo)
 	loading := ""
 
@@ -145,6 +229,14 @@ func (m *StatusBar) View() string {
 		loading = m.descStyle.Render(" ") + m.spinner.View() + m.descStyle.Render(m.loadingText)
 	}
 
+	if m.notifyText != "" {
+		if m.notifyError {
+			loading += m.errorStyle.Render(" " + types.XMark + " " + m.notifyText)
+		} else {
+			loading += m.descStyle.Render(" " + types.Checkmark + " " + m.notifyText)
+		}
+	}
+
 	help := ""
 	bindings := m.keys.ShortHelp()
 	helpWidth := m.Width - x.WMulti(target, url, logo, loading) - 2
@@ -0,0 +1,44 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package model_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lrstanley/hangar-ui/internal/fakeatc"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+func TestStatusBar(t *testing.T) {
+	h, _ := newHarness(t, fakeatc.NewState())
+	h.AssertGolden("statusbar")
+
+	h.Send(types.NotifyMsg{Text: "pipeline paused"})
+	h.AssertGolden("statusbar_notify")
+
+	h.Send(types.NotifyMsg{Error: errors.New("failed to pause pipeline")})
+	h.AssertGolden("statusbar_notify_error")
+}
+
+func TestStatusBarNeedsLogin(t *testing.T) {
+	state := fakeatc.NewState()
+	state.TokenExpired = true
+
+	h, _ := newHarness(t, state)
+
+	// Any authenticated query flags the target as needing login.
+	h.Send(types.ViewChangeMsg{View: types.ViewPipelines})
+	h.Settle()
+	h.AssertGolden("statusbar_needs_login")
+}
+
+func TestStatusBarNarrow(t *testing.T) {
+	h, _ := newHarness(t, fakeatc.NewState())
+
+	h.Resize(60, 10)
+	h.Settle()
+	h.AssertGolden("statusbar_narrow")
+}
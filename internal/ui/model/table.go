@@ -66,6 +66,11 @@ func (v *Table) UpdateRows(rows []table.Row) {
 	v.model = v.model.WithRows(rows)
 }
 
+// SetColumns replaces the columns of the table.
+func (v *Table) SetColumns(columns []table.Column) {
+	v.model = v.model.WithColumns(columns)
+}
+
 func (v *Table) SelectedRow() table.Row {
 	return v.model.HighlightedRow()
 }
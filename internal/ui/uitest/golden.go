@@ -0,0 +1,82 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package uitest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/aryann/difflib"
+)
+
+// ansiRe matches CSI and OSC escape sequences.
+var ansiRe = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)`)
+
+// StripANSI removes all ANSI escape sequences from s.
+func StripANSI(s string) string {
+	return ansiRe.ReplaceAllString(s, "")
+}
+
+// AssertGolden compares the current frame against the golden files for name.
+// The ANSI-stripped frame is stored in "<name>.golden", which makes layout
+// changes easy to review, and the raw frame in "<name>.ansi.golden", which
+// also catches style changes.
+func (h *Harness) AssertGolden(name string) {
+	h.tb.Helper()
+
+	raw := h.Frame()
+
+	h.assertFile(filepath.Join(h.Dir, name+".golden"), StripANSI(raw))
+	h.assertFile(filepath.Join(h.Dir, name+".ansi.golden"), raw)
+}
+
+func (h *Harness) assertFile(path, got string) {
+	h.tb.Helper()
+
+	if h.Update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			h.tb.Fatalf("failed to create golden dir: %v", err)
+		}
+
+		if err := os.WriteFile(path, []byte(got), 0o600); err != nil {
+			h.tb.Fatalf("failed to update golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		h.tb.Fatalf("golden file %s doesn't exist, run with -update to create it", path)
+		return
+	} else if err != nil {
+		h.tb.Fatalf("failed to read golden file: %v", err)
+		return
+	}
+
+	if string(want) != got {
+		h.tb.Errorf("frame doesn't match %s (run with -update to accept):\n%s", path, diff(string(want), got))
+	}
+}
+
+// diff returns the changed lines between want and got, with escape sequences
+// made visible.
+func diff(want, got string) string {
+	var buf strings.Builder
+
+	for i, r := range difflib.Diff(strings.Split(want, "\n"), strings.Split(got, "\n")) {
+		switch r.Delta {
+		case difflib.LeftOnly:
+			fmt.Fprintf(&buf, "%4d - %q\n", i+1, r.Payload)
+		case difflib.RightOnly:
+			fmt.Fprintf(&buf, "%4d + %q\n", i+1, r.Payload)
+		}
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,391 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+// Package uitest drives the UI headlessly, without a terminal, allowing the
+// rendered frames to be compared against golden files.
+package uitest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/lrstanley/clix"
+	"github.com/lrstanley/hangar-ui/internal/api"
+	"github.com/lrstanley/hangar-ui/internal/fakeatc"
+	"github.com/lrstanley/hangar-ui/internal/types"
+	"github.com/lrstanley/hangar-ui/internal/ui"
+	"github.com/muesli/termenv"
+)
+
+const (
+	// DefaultWidth and DefaultHeight are the initial size of the harness.
+	DefaultWidth  = 120
+	DefaultHeight = 30
+
+	// cmdWait is how long commands are waited on before they continue in the
+	// background. Commands returning within it are handled in order.
+	cmdWait = 50 * time.Millisecond
+
+	// settleQuiet is how long Settle waits for more asynchronous messages,
+	// once the client is idle, before considering the UI idle.
+	settleQuiet = 100 * time.Millisecond
+
+	// settleTimeout is how long Settle waits for the UI to become idle.
+	settleTimeout = 10 * time.Second
+
+	// zoneTimeout is how long Click waits for a zone to be registered.
+	zoneTimeout = time.Second
+)
+
+const (
+	// FakeTarget is the name of the target used by NewFake.
+	FakeTarget = "fake"
+
+	// FakeURL replaces the URL of the fake ATC in frames, as it's randomly
+	// assigned.
+	FakeURL = "http://fake-atc"
+)
+
+// TB is the subset of testing.TB used by the harness.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+	Fatalf(format string, args ...any)
+	Cleanup(fn func())
+}
+
+// Harness drives a ui.App without a terminal. Messages are processed
+// synchronously, in the calling goroutine.
+type Harness struct {
+	tb TB
+
+	// App is the application being driven.
+	App *ui.App
+
+	// Dir is the directory golden files are stored in. Defaults to "testdata".
+	Dir string
+
+	// Update makes AssertGolden write the current frames to the golden files,
+	// rather than comparing them. Tests usually set it from an "-update" flag.
+	Update bool
+
+	// Normalize, if set, is applied to frames before they are compared with
+	// golden files (e.g. to replace relative timestamps, or the randomly
+	// assigned URL of a fake ATC).
+	Normalize func(frame string) string
+
+	client  api.Manager
+	inbox   chan tea.Msg
+	results chan tea.Msg
+	done    chan struct{}
+}
+
+// New returns a harness driving a new ui.App, using client for all API calls,
+// and sends the initial window size. Messages from the client (loading
+// states, target updates, streamed builds, etc) are processed by Settle.
+func New(tb TB, client api.Manager) *Harness {
+	tb.Helper()
+
+	// Render consistently, regardless of the terminal running the tests.
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	types.SetTheme("default")
+
+	h := &Harness{
+		tb:      tb,
+		Dir:     "testdata",
+		client:  client,
+		inbox:   make(chan tea.Msg, 100),
+		results: make(chan tea.Msg, 100),
+		done:    make(chan struct{}),
+	}
+
+	h.App = ui.New(context.Background(), &clix.CLI[types.Flags]{Flags: &types.Flags{}}, client)
+	lipgloss.SetHasDarkBackground(true)
+
+	go client.HandleMsg(func(msg tea.Msg) {
+		select {
+		case h.inbox <- msg:
+		case <-h.done:
+		}
+	})
+
+	tb.Cleanup(func() {
+		// Stop accepting messages first, so the client doesn't block on
+		// delivering messages nobody will process.
+		close(h.done)
+		client.Close()
+	})
+
+	h.Resize(DefaultWidth, DefaultHeight)
+	h.run(h.App.Init())
+
+	return h
+}
+
+// NewClient returns the default api.Manager, using the provided fly target.
+// The flyrc is loaded from FLY_HOME (see fakeatc.Server.UseFlyrc), and offline
+// snapshots are also stored there, so they don't leak between tests.
+func NewClient(target string) api.Manager {
+	flags := &types.Flags{Target: target}
+
+	if home := os.Getenv("FLY_HOME"); home != "" {
+		flags.CacheDir = filepath.Join(home, "snapshots")
+	}
+
+	return api.NewAPIClient(context.Background(), &clix.CLI[types.Flags]{Flags: flags})
+}
+
+// NewFake starts a fake ATC serving state (see fakeatc.New), and returns a
+// harness driving a client with a single "fake" target pointing at it. The
+// randomly assigned URL of the fake ATC is normalized in frames (see
+// NormalizeURL). The fake ATC is closed once the test is done.
+func NewFake(tb TB, state *fakeatc.State) (*Harness, *fakeatc.Server) {
+	tb.Helper()
+
+	srv := fakeatc.New(state)
+	tb.Cleanup(srv.Close)
+
+	restore, err := srv.UseFlyrc(FakeTarget)
+	if err != nil {
+		tb.Fatalf("failed to write flyrc: %v", err)
+		return nil, nil
+	}
+	tb.Cleanup(restore)
+
+	h := New(tb, NewClient(FakeTarget))
+	h.Normalize = NormalizeURL
+
+	return h, srv
+}
+
+// loopbackURLRe matches URLs of servers listening on the loopback interface
+// (like the fake ATC), which may be truncated.
+var loopbackURLRe = regexp.MustCompile(`http://127\.0\.0\.1(:[0-9]*)?…?`)
+
+// NormalizeURL replaces the URLs of fake ATCs in frame with FakeURL. The width
+// of the URL is kept, so layouts aren't changed.
+func NormalizeURL(frame string) string {
+	return loopbackURLRe.ReplaceAllStringFunc(frame, func(url string) string {
+		width := utf8.RuneCountInString(url)
+
+		if strings.HasSuffix(url, "…") {
+			return string([]rune(FakeURL + strings.Repeat(" ", width))[:width-1]) + "…"
+		}
+
+		if width < len(FakeURL) {
+			return FakeURL[:width]
+		}
+
+		return FakeURL + strings.Repeat(" ", width-len(FakeURL))
+	})
+}
+
+// Resize sends a window size message.
+func (h *Harness) Resize(width, height int) {
+	h.Send(tea.WindowSizeMsg{Width: width, Height: height})
+}
+
+// Send sends messages to the app, running all resulting commands.
+func (h *Harness) Send(msgs ...tea.Msg) {
+	for _, msg := range msgs {
+		h.update(msg)
+	}
+}
+
+// Key sends key presses, using the same names as key bindings (e.g. "enter",
+// "ctrl+x", "up", or "q"). Anything that isn't a known key name is sent as
+// runes.
+func (h *Harness) Key(keys ...string) {
+	for _, k := range keys {
+		h.Send(keyMsg(k))
+	}
+}
+
+// Type sends text as individual rune key presses.
+func (h *Harness) Type(text string) {
+	for _, r := range text {
+		h.Send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+}
+
+// Mouse sends a mouse event at the provided coordinates.
+func (h *Harness) Mouse(typ tea.MouseEventType, x, y int) {
+	h.Send(tea.MouseMsg{Type: typ, X: x, Y: y})
+}
+
+// Click renders the current frame, and clicks the top left cell of the zone
+// with the provided ID.
+func (h *Harness) Click(id string) {
+	h.tb.Helper()
+
+	deadline := time.Now().Add(zoneTimeout)
+
+	for {
+		// Zones are registered asynchronously after each render.
+		_ = h.App.View()
+		time.Sleep(10 * time.Millisecond)
+
+		if z := zone.Get(id); z != nil && !z.IsZero() {
+			h.Mouse(tea.MouseLeft, z.StartX, z.StartY)
+			h.Mouse(tea.MouseRelease, z.StartX, z.StartY)
+			return
+		}
+
+		if time.Now().After(deadline) {
+			h.tb.Fatalf("zone %q not found", id)
+			return
+		}
+	}
+}
+
+// Settle processes asynchronous messages from the client, and the results of
+// commands running in the background, until the client has no queries in
+// flight, and no messages have been received for a short period.
+func (h *Harness) Settle() {
+	h.tb.Helper()
+
+	deadline := time.After(settleTimeout)
+
+	for {
+		select {
+		case msg := <-h.inbox:
+			h.update(msg)
+		case msg := <-h.results:
+			h.handle(msg)
+		case <-time.After(settleQuiet):
+			if !h.client.Busy() {
+				return
+			}
+		case <-deadline:
+			h.tb.Fatalf("ui didn't settle within %s", settleTimeout)
+			return
+		}
+	}
+}
+
+// Frame returns the current rendered frame, including ANSI escape sequences.
+func (h *Harness) Frame() string {
+	frame := h.App.View()
+
+	if h.Normalize != nil {
+		frame = h.Normalize(frame)
+	}
+
+	return frame
+}
+
+func (h *Harness) update(msg tea.Msg) {
+	_, cmd := h.App.Update(msg)
+	h.run(cmd)
+}
+
+// run runs cmd, and sends the resulting message back to the app. Commands
+// which don't return right away (queries, timers, etc) continue in the
+// background, and their results are handled by Settle.
+func (h *Harness) run(cmd tea.Cmd) {
+	if cmd == nil {
+		return
+	}
+
+	result := make(chan tea.Msg, 1)
+	go func() {
+		result <- cmd()
+	}()
+
+	select {
+	case msg := <-result:
+		h.handle(msg)
+	case <-time.After(cmdWait):
+		go func() {
+			msg := <-result
+
+			select {
+			case h.results <- msg:
+			case <-h.done:
+			}
+		}()
+	}
+}
+
+// handle sends the result of a command back to the app. Batches and sequences
+// are expanded, and run in order.
+func (h *Harness) handle(msg tea.Msg) {
+	if msg == nil || reflect.TypeOf(msg) == quitType || isAnimation(msg) {
+		return
+	}
+
+	switch msg := msg.(type) {
+	case tea.BatchMsg:
+		for _, c := range msg {
+			h.run(c)
+		}
+		return
+	}
+
+	// tea.Sequence returns an unexported slice of commands.
+	if v := reflect.ValueOf(msg); v.Kind() == reflect.Slice && v.Type().Elem() == reflect.TypeOf(tea.Cmd(nil)) {
+		for i := 0; i < v.Len(); i++ {
+			h.run(v.Index(i).Interface().(tea.Cmd))
+		}
+		return
+	}
+
+	h.update(msg)
+}
+
+// quitType is the (unexported) type of the message returned by tea.Quit.
+var quitType = reflect.TypeOf(tea.Quit())
+
+// isAnimation returns true if msg only advances an animation (spinners and
+// blinking cursors). These are dropped, so frames are deterministic.
+func isAnimation(msg tea.Msg) bool {
+	if _, ok := msg.(spinner.TickMsg); ok {
+		return true
+	}
+
+	// The blink message of text inputs is unexported.
+	typ := reflect.TypeOf(msg)
+	return typ.PkgPath() == "github.com/charmbracelet/bubbles/textinput" && typ.Name() == "blinkMsg"
+}
+
+var keyNames = func() map[string]tea.KeyType {
+	names := map[string]tea.KeyType{}
+
+	for k := tea.KeyType(-100); k < 128; k++ {
+		if name := k.String(); name != "" && name != "runes" {
+			names[name] = k
+		}
+	}
+
+	// Some key types share names, prefer the canonical ones.
+	names["esc"] = tea.KeyEsc
+	names["enter"] = tea.KeyEnter
+	names["tab"] = tea.KeyTab
+
+	return names
+}()
+
+func keyMsg(k string) tea.KeyMsg {
+	if typ, ok := keyNames[k]; ok {
+		return tea.KeyMsg{Type: typ}
+	}
+
+	if alt := strings.TrimPrefix(k, "alt+"); alt != k {
+		msg := keyMsg(alt)
+		msg.Alt = true
+		return msg
+	}
+
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+}
@@ -7,11 +7,13 @@ package view
 import (
 	"github.com/apex/log"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/lrstanley/hangar-ui/internal/api"
 	"github.com/lrstanley/hangar-ui/internal/types"
 )
 
 type Base struct {
 	app    types.App
+	client api.Manager
 	is     types.Viewable
 	logger log.Interface
 
@@ -0,0 +1,191 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package view
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/concourse/concourse/atc"
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/lrstanley/hangar-ui/internal/api"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+// Build shows the streamed log output of a build.
+type Build struct {
+	*Base
+	model viewport.Model
+
+	build  atc.Build
+	status atc.BuildStatus
+	follow bool
+	buf    strings.Builder
+
+	titleStyle lipgloss.Style
+}
+
+func NewBuild(app types.App, client api.Manager) *Build {
+	v := &Build{
+		Base: &Base{
+			app:    app,
+			client: client,
+			is:     types.ViewBuild,
+			logger: log.WithField("src", "build"),
+		},
+		model:  viewport.New(0, 0),
+		follow: true,
+	}
+
+	client.Subscribe(v.is, types.TopicBuilds)
+
+	v.titleStyle = lipgloss.NewStyle().
+		Background(types.Theme.TitleBg).
+		Foreground(types.Theme.TitleFg).
+		Padding(0, 1)
+
+	return v
+}
+
+func (v *Build) reset(build atc.Build) {
+	v.build = build
+	v.status = atc.BuildStatus(build.Status)
+	v.follow = true
+	v.buf.Reset()
+	v.model.SetContent("")
+}
+
+func (v *Build) running() bool {
+	return v.build.ID != 0 && (v.status == atc.StatusStarted || v.status == atc.StatusPending)
+}
+
+func (v *Build) append(text string) {
+	v.buf.WriteString(text)
+	v.model.SetContent(v.buf.String())
+
+	if v.follow {
+		v.model.GotoBottom()
+	}
+}
+
+func (v *Build) Init() tea.Cmd { return nil }
+
+func (v *Build) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		v.height = msg.Height
+		v.width = msg.Width
+		v.model.Height = msg.Height - v.model.Style.GetVerticalFrameSize() - 3 // 2 for border, 1 for title.
+		v.model.Width = msg.Width - v.model.Style.GetHorizontalFrameSize() - 4 // 2 for border, 2 for padding.
+	case tea.MouseMsg:
+		if !zone.Get(string(v.is)).InBounds(msg) {
+			return v, nil
+		}
+
+		switch msg.Type {
+		case tea.MouseLeft, tea.MouseRight:
+			return v, types.MsgAsCmd(types.FocusChangeMsg{View: v.is})
+		case tea.MouseWheelUp:
+			v.follow = false
+		}
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, types.KeyCancel):
+			return v, types.MsgAsCmd(types.AppBackMsg{Focused: true})
+		case key.Matches(msg, types.KeyAbort):
+			if !v.running() {
+				return v, nil
+			}
+			return v, v.client.AbortBuild(v.build.ID)
+		case key.Matches(msg, types.KeyFollow):
+			v.follow = !v.follow
+			if v.follow {
+				v.model.GotoBottom()
+			}
+			return v, nil
+		case key.Matches(msg, types.KeyUp):
+			v.follow = false
+		}
+	case api.BuildStartedMsg:
+		v.reset(msg.Build)
+
+		if msg.Error != nil {
+			v.status = atc.StatusErrored
+			v.append(msg.Error.Error() + "\n")
+			return v, nil
+		}
+
+		v.append(fmt.Sprintf("executing build %d\n", msg.Build.ID))
+		return v, nil
+	case api.BuildEventMsg:
+		if msg.BuildID != v.build.ID {
+			return v, nil
+		}
+
+		v.status = atc.StatusStarted
+		v.append(msg.Text)
+		return v, nil
+	case api.BuildFinishedMsg:
+		if msg.BuildID != v.build.ID {
+			return v, nil
+		}
+
+		// The status is unknown if streaming stopped before the build finished.
+		if msg.Status != "" {
+			v.status = msg.Status
+		}
+
+		if msg.Error != nil {
+			v.append(msg.Error.Error() + "\n")
+		}
+		return v, nil
+	case api.BuildAbortMsg:
+		if msg.BuildID == v.build.ID && msg.Error != nil {
+			v.append("failed to abort: " + msg.Error.Error() + "\n")
+		}
+		return v, nil
+	}
+
+	var cmd tea.Cmd
+	v.model, cmd = v.model.Update(msg)
+	return v, cmd
+}
+
+func (v *Build) View() string {
+	title := "no build"
+	if v.build.ID != 0 {
+		title = fmt.Sprintf("build #%d", v.build.ID)
+	}
+
+	if v.status != "" {
+		title += " • " + string(v.status)
+	}
+
+	if v.follow {
+		title += " • following"
+	}
+
+	s := lipgloss.NewStyle().
+		Width(v.width-2). // 2 for border
+		Height(v.height-2).
+		MaxHeight(v.height).
+		MaxWidth(v.width).
+		Padding(0, 1).
+		Background(types.Theme.Bg).
+		Border(lipgloss.RoundedBorder()).
+		BorderBackground(types.Theme.ViewBorderBg).
+		BorderForeground(types.Theme.ViewBorderInactiveFg)
+
+	if v.Focused() {
+		s = s.BorderForeground(types.Theme.ViewBorderActiveFg)
+	}
+
+	return zone.Mark(string(v.is), s.Render(v.titleStyle.Render(title)+"\n"+v.model.View()))
+}
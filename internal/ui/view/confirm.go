@@ -0,0 +1,113 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package view
+
+import (
+	"github.com/apex/log"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+// Confirm asks the user to confirm an action (see types.ConfirmMsg) before it
+// is invoked.
+type Confirm struct {
+	*Base
+	model viewport.Model
+
+	pending types.ConfirmMsg
+
+	titleStyle lipgloss.Style
+	hintStyle  lipgloss.Style
+}
+
+func NewConfirm(app types.App) *Confirm {
+	v := &Confirm{
+		Base: &Base{
+			app:    app,
+			is:     types.ViewConfirm,
+			logger: log.WithField("src", "confirm"),
+		},
+		model: viewport.New(0, 0),
+	}
+
+	v.titleStyle = lipgloss.NewStyle().
+		Background(types.Theme.TitleBg).
+		Foreground(types.Theme.TitleFg).
+		Padding(0, 1)
+
+	v.hintStyle = lipgloss.NewStyle().
+		Background(types.Theme.Bg).
+		Foreground(types.Theme.InputPlaceholderFg)
+
+	return v
+}
+
+func (v *Confirm) Init() tea.Cmd { return nil }
+
+func (v *Confirm) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		v.height = msg.Height
+		v.width = msg.Width
+		v.model.Height = msg.Height - v.model.Style.GetVerticalFrameSize() - 5 // 2 for border, 3 for title/hint.
+		v.model.Width = msg.Width - v.model.Style.GetHorizontalFrameSize() - 4 // 2 for border, 2 for padding.
+	case tea.MouseMsg:
+		if !zone.Get(string(v.is)).InBounds(msg) {
+			return v, nil
+		}
+
+		switch msg.Type {
+		case tea.MouseLeft, tea.MouseRight:
+			return v, types.MsgAsCmd(types.FocusChangeMsg{View: v.is})
+		}
+	case types.ConfirmMsg:
+		v.pending = msg
+		v.model.SetContent(msg.Body)
+		v.model.GotoTop()
+		return v, nil
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, types.KeyConfirm):
+			cmd := v.pending.Cmd
+			v.pending = types.ConfirmMsg{}
+
+			return v, tea.Batch(types.MsgAsCmd(types.AppBackMsg{Focused: true}), cmd)
+		case key.Matches(msg, types.KeyDeny, types.KeyCancel):
+			v.pending = types.ConfirmMsg{}
+			return v, types.MsgAsCmd(types.AppBackMsg{Focused: true})
+		}
+	}
+
+	var cmd tea.Cmd
+	v.model, cmd = v.model.Update(msg)
+	return v, cmd
+}
+
+func (v *Confirm) View() string {
+	s := lipgloss.NewStyle().
+		Width(v.width-2). // 2 for border
+		Height(v.height-2).
+		MaxHeight(v.height).
+		MaxWidth(v.width).
+		Padding(0, 1).
+		Background(types.Theme.Bg).
+		Border(lipgloss.RoundedBorder()).
+		BorderBackground(types.Theme.ViewBorderBg).
+		BorderForeground(types.Theme.ViewBorderInactiveFg)
+
+	if v.Focused() {
+		s = s.BorderForeground(types.Theme.ViewBorderActiveFg)
+	}
+
+	out := v.titleStyle.Render(v.pending.Title) + "\n" +
+		v.hintStyle.Render("press <y> to confirm, <n> or <esc> to cancel") + "\n\n" +
+		v.model.View()
+
+	return zone.Mark(string(v.is), s.Render(out))
+}
@@ -0,0 +1,66 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package view
+
+import (
+	"strings"
+
+	"github.com/aryann/difflib"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// renderDiff returns a colored, unified-style line diff of old and new, only
+// including diffContext lines of context around each change.
+func renderDiff(old, new string) string {
+	records := difflib.Diff(strings.Split(old, "\n"), strings.Split(new, "\n"))
+
+	addStyle := lipgloss.NewStyle().Foreground(types.Theme.SuccessFg)
+	delStyle := lipgloss.NewStyle().Foreground(types.Theme.FailureFg)
+	sepStyle := lipgloss.NewStyle().Foreground(types.Theme.InputPlaceholderFg)
+
+	// Mark which lines are close enough to a change to be shown.
+	show := make([]bool, len(records))
+	for i, r := range records {
+		if r.Delta == difflib.Common {
+			continue
+		}
+
+		for j := i - diffContext; j <= i+diffContext; j++ {
+			if j >= 0 && j < len(records) {
+				show[j] = true
+			}
+		}
+	}
+
+	var buf strings.Builder
+	var skipped bool
+
+	for i, r := range records {
+		if !show[i] {
+			skipped = true
+			continue
+		}
+
+		if skipped && buf.Len() > 0 {
+			buf.WriteString(sepStyle.Render("...") + "\n")
+		}
+		skipped = false
+
+		switch r.Delta {
+		case difflib.LeftOnly:
+			buf.WriteString(delStyle.Render("- "+r.Payload) + "\n")
+		case difflib.RightOnly:
+			buf.WriteString(addStyle.Render("+ "+r.Payload) + "\n")
+		default:
+			buf.WriteString("  " + r.Payload + "\n")
+		}
+	}
+
+	return buf.String()
+}
@@ -86,6 +86,21 @@ func (v *Help) generateHelp() {
 			)
 		}
 	}
+
+	if cmds := v.app.Commands(); len(cmds) > 0 {
+		buf.WriteString("\n" + v.titleStyle.Render("commands") + "\n")
+
+		for _, cmd := range cmds {
+			buf.WriteString(
+				v.keyStyle.Copy().Width(12).Render(
+					v.keyStyle.Render(":")+v.keyInnerStyle.Render(cmd.Name),
+				) +
+					v.descStyle.Render(cmd.Desc) + "\n" +
+					v.keyStyle.Copy().PaddingLeft(12).Render(cmd.Usage) + "\n",
+			)
+		}
+	}
+
 	v.model.SetContent(buf.String())
 }
 
@@ -0,0 +1,293 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package view
+
+import (
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/concourse/concourse/fly/rc"
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/lrstanley/hangar-ui/internal/api"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+const (
+	loginFieldUsername = iota
+	loginFieldPassword
+)
+
+// loginTargetMsg resets the login view, to login to the provided target. If
+// next is set, it's shown once logged in (or cancelled), rather than going
+// back.
+type loginTargetMsg struct {
+	target string
+	next   types.Viewable
+}
+
+// Login allows logging into a target, either with a username and password
+// (local users), or through the browser (OAuth/OIDC connectors). While a
+// browser login is in progress, the token can also be pasted manually (e.g.
+// when the browser is on another machine).
+type Login struct {
+	*Base
+
+	target  string
+	next    types.Viewable
+	inputs  []textinput.Model
+	token   textinput.Model
+	focus   int
+	pending bool
+	url     string
+	err     error
+
+	titleStyle lipgloss.Style
+	hintStyle  lipgloss.Style
+	labelStyle lipgloss.Style
+	errorStyle lipgloss.Style
+}
+
+func NewLogin(app types.App, client api.Manager) *Login {
+	v := &Login{
+		Base: &Base{
+			app:    app,
+			client: client,
+			is:     types.ViewLogin,
+			logger: log.WithField("src", "login"),
+		},
+		inputs: make([]textinput.Model, 2),
+	}
+
+	for i := range v.inputs {
+		v.inputs[i] = newLoginInput()
+	}
+
+	v.inputs[loginFieldUsername].Placeholder = "username"
+	v.inputs[loginFieldPassword].Placeholder = "password"
+	v.inputs[loginFieldPassword].EchoMode = textinput.EchoPassword
+
+	v.token = newLoginInput()
+	v.token.Placeholder = "bearer <token>"
+	v.token.EchoMode = textinput.EchoPassword
+
+	v.titleStyle = lipgloss.NewStyle().
+		Background(types.Theme.TitleBg).
+		Foreground(types.Theme.TitleFg).
+		Padding(0, 1)
+
+	v.hintStyle = lipgloss.NewStyle().
+		Background(types.Theme.Bg).
+		Foreground(types.Theme.InputPlaceholderFg)
+
+	v.labelStyle = lipgloss.NewStyle().
+		Background(types.Theme.Bg).
+		Foreground(types.Theme.Fg).
+		Width(10)
+
+	v.errorStyle = lipgloss.NewStyle().
+		Background(types.Theme.Bg).
+		Foreground(types.Theme.FailureFg)
+
+	return v
+}
+
+func newLoginInput() textinput.Model {
+	input := textinput.New()
+	input.Prompt = ""
+	input.PlaceholderStyle = input.PlaceholderStyle.Background(types.Theme.Bg).Foreground(types.Theme.InputPlaceholderFg)
+	input.TextStyle = input.TextStyle.Background(types.Theme.Bg).Foreground(types.Theme.InputFg)
+	input.CursorStyle = input.CursorStyle.Background(types.Theme.Bg).Foreground(types.Theme.InputCursorFg)
+	return input
+}
+
+// reset clears the form, to login to the provided target.
+func (v *Login) reset(target string) {
+	v.target = target
+	v.pending = false
+	v.url = ""
+	v.err = nil
+
+	for i := range v.inputs {
+		v.inputs[i].Reset()
+	}
+
+	v.token.Reset()
+	v.token.Blur()
+
+	v.setFocus(loginFieldUsername)
+}
+
+func (v *Login) setFocus(field int) {
+	v.focus = (field + len(v.inputs)) % len(v.inputs)
+
+	for i := range v.inputs {
+		if i == v.focus {
+			v.inputs[i].Focus()
+			continue
+		}
+		v.inputs[i].Blur()
+	}
+}
+
+// done leaves the login view, continuing to the next view if set, otherwise
+// going back.
+func (v *Login) done() tea.Cmd {
+	if v.next != "" {
+		return tea.Batch(
+			types.MsgAsCmd(types.ViewChangeMsg{View: v.next}),
+			types.MsgAsCmd(types.FocusChangeMsg{View: v.next}),
+		)
+	}
+
+	return types.MsgAsCmd(types.AppBackMsg{Focused: true})
+}
+
+func (v *Login) Init() tea.Cmd { return nil }
+
+func (v *Login) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		v.height = msg.Height
+		v.width = msg.Width
+
+		for i := range v.inputs {
+			v.inputs[i].Width = msg.Width - v.labelStyle.GetWidth() - 6 // 2 for border, 2 for padding, 2 for cursor.
+		}
+
+		v.token.Width = msg.Width - v.labelStyle.GetWidth() - 6
+	case tea.MouseMsg:
+		if !zone.Get(string(v.is)).InBounds(msg) {
+			return v, nil
+		}
+
+		switch msg.Type {
+		case tea.MouseLeft, tea.MouseRight:
+			return v, types.MsgAsCmd(types.FocusChangeMsg{View: v.is})
+		}
+	case loginTargetMsg:
+		v.reset(msg.target)
+		v.next = msg.next
+		return v, textinput.Blink
+	case api.LoginURLMsg:
+		if msg.Target != v.target || !v.pending {
+			return v, nil
+		}
+
+		v.url = msg.URL
+		v.token.Reset()
+		v.inputs[v.focus].Blur()
+		return v, v.token.Focus()
+	case api.LoginMsg:
+		if msg.Target != v.target || !v.pending {
+			return v, nil
+		}
+
+		v.pending = false
+		v.url = ""
+		v.err = msg.Error
+		v.token.Blur()
+		v.setFocus(v.focus)
+
+		if msg.Error != nil || !v.Focused() {
+			return v, nil
+		}
+
+		return v, v.done()
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, types.KeyCancel):
+			if v.pending {
+				v.client.CancelLogin()
+			}
+
+			return v, v.done()
+		case v.pending && v.url != "":
+			// Waiting for a browser login, the token can be pasted instead.
+			if key.Matches(msg, types.KeyEnter) && strings.TrimSpace(v.token.Value()) != "" {
+				return v, v.client.LoginWithToken(v.target, v.token.Value())
+			}
+
+			var cmd tea.Cmd
+			v.token, cmd = v.token.Update(msg)
+			return v, cmd
+		case v.pending:
+			return v, nil
+		case key.Matches(msg, types.KeyBrowserLogin):
+			v.pending = true
+			v.err = nil
+			return v, v.client.LoginWithBrowser(v.target)
+		case key.Matches(msg, types.KeyNextField):
+			v.setFocus(v.focus + 1)
+			return v, nil
+		case key.Matches(msg, types.KeyPrevField):
+			v.setFocus(v.focus - 1)
+			return v, nil
+		case key.Matches(msg, types.KeyEnter):
+			if v.focus != loginFieldPassword {
+				v.setFocus(v.focus + 1)
+				return v, nil
+			}
+
+			v.pending = true
+			v.err = nil
+			return v, v.client.LoginWithPassword(
+				v.target,
+				v.inputs[loginFieldUsername].Value(),
+				v.inputs[loginFieldPassword].Value(),
+			)
+		}
+	}
+
+	var cmd tea.Cmd
+	v.inputs[v.focus], cmd = v.inputs[v.focus].Update(msg)
+	return v, cmd
+}
+
+func (v *Login) View() string {
+	s := lipgloss.NewStyle().
+		Width(v.width-2). // 2 for border
+		Height(v.height-2).
+		MaxHeight(v.height).
+		MaxWidth(v.width).
+		Padding(0, 1).
+		Background(types.Theme.Bg).
+		Border(lipgloss.RoundedBorder()).
+		BorderBackground(types.Theme.ViewBorderBg).
+		BorderForeground(types.Theme.ViewBorderInactiveFg)
+
+	if v.Focused() {
+		s = s.BorderForeground(types.Theme.ViewBorderActiveFg)
+	}
+
+	title := "login to " + v.target
+	if t, ok := v.client.Targets()[rc.TargetName(v.target)]; ok {
+		title += " (" + t.API + ")"
+	}
+
+	var out strings.Builder
+
+	out.WriteString(v.titleStyle.Render(title) + "\n")
+	out.WriteString(v.hintStyle.Render("press <enter> to login, <ctrl+o> to login with a browser instead, <esc> to cancel") + "\n\n")
+	out.WriteString(v.labelStyle.Render("username") + v.inputs[loginFieldUsername].View() + "\n")
+	out.WriteString(v.labelStyle.Render("password") + v.inputs[loginFieldPassword].View() + "\n\n")
+
+	switch {
+	case v.url != "":
+		out.WriteString(v.hintStyle.Render("waiting for login, if your browser didn't open, navigate to:") + "\n\n")
+		out.WriteString(v.labelStyle.Render("") + v.url + "\n\n")
+		out.WriteString(v.hintStyle.Render("or paste the token shown after logging in, and press <enter>:") + "\n\n")
+		out.WriteString(v.labelStyle.Render("token") + v.token.View() + "\n")
+	case v.pending:
+		out.WriteString(v.hintStyle.Render("logging in...") + "\n")
+	case v.err != nil:
+		out.WriteString(v.errorStyle.Render(types.XMark+" "+v.err.Error()) + "\n")
+	}
+
+	return zone.Mark(string(v.is), s.Render(out.String()))
+}
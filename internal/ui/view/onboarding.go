@@ -0,0 +1,168 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package view
+
+import (
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/lrstanley/hangar-ui/internal/api"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+// Onboarding is shown on first run, when there are no usable targets in the
+// flyrc, and allows adding one (followed by logging into it).
+type Onboarding struct {
+	*Base
+
+	form    *targetForm
+	pending bool
+	added   bool // Set once a target was added through the form.
+	err     error
+
+	titleStyle lipgloss.Style
+	hintStyle  lipgloss.Style
+	errorStyle lipgloss.Style
+}
+
+func NewOnboarding(app types.App, client api.Manager) *Onboarding {
+	v := &Onboarding{
+		Base: &Base{
+			app:    app,
+			client: client,
+			is:     types.ViewOnboarding,
+			logger: log.WithField("src", "onboarding"),
+		},
+		form: newTargetForm(),
+	}
+
+	v.form.reset(api.TargetConfig{Team: "main"})
+
+	client.Subscribe(v.is, types.TopicState)
+
+	v.titleStyle = lipgloss.NewStyle().
+		Background(types.Theme.TitleBg).
+		Foreground(types.Theme.TitleFg).
+		Padding(0, 1)
+
+	v.hintStyle = lipgloss.NewStyle().
+		Background(types.Theme.Bg).
+		Foreground(types.Theme.InputPlaceholderFg)
+
+	v.errorStyle = lipgloss.NewStyle().
+		Background(types.Theme.Bg).
+		Foreground(types.Theme.FailureFg)
+
+	return v
+}
+
+func (v *Onboarding) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (v *Onboarding) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		v.height = msg.Height
+		v.width = msg.Width
+		v.form.setWidth(msg.Width - 4) // 2 for border, 2 for padding.
+		return v, nil
+	case tea.MouseMsg:
+		if !zone.Get(string(v.is)).InBounds(msg) {
+			return v, nil
+		}
+
+		switch msg.Type {
+		case tea.MouseLeft, tea.MouseRight:
+			return v, types.MsgAsCmd(types.FocusChangeMsg{View: v.is})
+		}
+		return v, nil
+	case api.TargetSavedMsg:
+		if !v.pending {
+			return v, nil
+		}
+
+		v.pending = false
+		v.err = msg.Error
+
+		if msg.Error != nil {
+			return v, nil
+		}
+
+		v.added = true
+
+		// Login to the new target, and continue to the pipelines once done.
+		return v, tea.Batch(
+			types.MsgAsCmd(types.ViewMsg{
+				View: types.ViewLogin,
+				Msg:  loginTargetMsg{target: msg.Target, next: types.ViewPipelines},
+			}),
+			types.MsgAsCmd(types.ViewChangeMsg{View: types.ViewLogin}),
+			types.MsgAsCmd(types.FocusChangeMsg{View: types.ViewLogin}),
+		)
+	case types.FlyEvent:
+		// A target may also be added outside of the UI (e.g. with `fly login`),
+		// in which case there is nothing left to do here.
+		if msg == types.FlyActiveTargetUpdated && v.Active() && !v.pending && !v.added && v.client.ActiveName() != "" {
+			return v, tea.Batch(
+				types.MsgAsCmd(types.ViewChangeMsg{View: types.ViewPipelines}),
+				types.MsgAsCmd(types.FocusChangeMsg{View: types.ViewPipelines}),
+			)
+		}
+		return v, nil
+	case tea.KeyMsg:
+		if v.pending {
+			return v, nil
+		}
+	}
+
+	submit, cmd := v.form.update(msg)
+	if submit {
+		v.pending = true
+		v.err = nil
+		return v, v.client.AddTarget(v.form.config())
+	}
+
+	return v, cmd
+}
+
+func (v *Onboarding) View() string {
+	s := lipgloss.NewStyle().
+		Width(v.width-2). // 2 for border
+		Height(v.height-2).
+		MaxHeight(v.height).
+		MaxWidth(v.width).
+		Padding(0, 1).
+		Background(types.Theme.Bg).
+		Border(lipgloss.RoundedBorder()).
+		BorderBackground(types.Theme.ViewBorderBg).
+		BorderForeground(types.Theme.ViewBorderInactiveFg)
+
+	if v.Focused() {
+		s = s.BorderForeground(types.Theme.ViewBorderActiveFg)
+	}
+
+	var out strings.Builder
+
+	out.WriteString(v.titleStyle.Render("welcome to hangar-ui") + "\n\n")
+	out.WriteString(v.hintStyle.Render("no fly targets were found in "+api.FlyrcPath()+". add one below to get started,") + "\n")
+	out.WriteString(v.hintStyle.Render("or run `fly -t <name> login` in another terminal, which is picked up automatically.") + "\n\n")
+	out.WriteString(v.form.View() + "\n")
+
+	switch {
+	case v.pending:
+		out.WriteString(v.hintStyle.Render("adding target...") + "\n")
+	case v.err != nil:
+		out.WriteString(v.errorStyle.Render(types.XMark+" "+v.err.Error()) + "\n")
+	default:
+		out.WriteString(v.hintStyle.Render("press <tab> to move between fields, <enter> on the last field to add the target and login") + "\n")
+	}
+
+	return zone.Mark(string(v.is), s.Render(out.String()))
+}
@@ -5,12 +5,20 @@
 package view
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
 	"time"
 
 	"github.com/apex/log"
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/concourse/concourse/atc"
 	"github.com/dustin/go-humanize"
 	"github.com/evertras/bubble-table/table"
 	"github.com/lrstanley/hangar-ui/internal/api"
@@ -28,48 +36,150 @@ const (
 	colPipelineTeam           = "team"
 	colPipelineLastUpdated    = "last_updated"
 	colPipelineLastUpdatedRaw = "last_updated_raw"
+	colPipelineRef            = "ref"
+	colPipelineTarget         = "target"
+	colPipelineTargetName     = "target_name"
 )
 
+// AllTargetsMsg switches the pipelines view to aggregate mode, showing the
+// pipelines of the provided targets (or all targets, if empty) at once.
+type AllTargetsMsg struct {
+	Targets []string
+}
+
+// pipelineEditedMsg is sent once the user's editor exits, after editing a
+// pipeline config.
+type pipelineEditedMsg struct {
+	config api.PipelineConfigMsg
+	path   string
+	err    error
+}
+
 type Pipelines struct {
 	*Base
 	model model.Table
 
 	showArchived  bool
 	pipelineCache api.PipelineListMsg
+
+	// queried is set once pipelines have been queried, after which the
+	// snapshot loaded on startup is no longer used.
+	queried bool
+
+	// aggregate is set when the pipelines of multiple targets are shown at
+	// once. targets is the selected subset of targets (or all, if empty).
+	aggregate bool
+	targets   []string
+	allCache  api.AllPipelinesMsg
 }
 
-func NewPipelines(app types.App) *Pipelines {
+func NewPipelines(app types.App, client api.Manager) *Pipelines {
 	v := &Pipelines{
 		Base: &Base{
 			app:    app,
+			client: client,
 			is:     types.ViewPipelines,
 			logger: log.WithField("src", "pipelines"),
 		},
-		model: model.NewTable(app, types.ViewPipelines, []table.Column{
-			table.NewColumn(colPipelineID, "ID", 4),
-			table.NewFlexColumn(colPipelineName, "Name", 5).WithFiltered(true),
-			table.NewFlexColumn(colPipelineInstanceVars, "Instance Vars", 4).WithFiltered(true),
-			table.NewColumn(colPipelinePaused, "Pause", 5),
-			table.NewColumn(colPipelinePublic, "Public", 6),
-			table.NewColumn(colPipelineArchived, "Archive", 7),
-			table.NewFlexColumn(colPipelineTeam, "Team", 4).WithFiltered(true),
-			table.NewFlexColumn(colPipelineLastUpdated, "Last Updated", 2),
-		}, colPipelineName),
+		model: model.NewTable(app, types.ViewPipelines, pipelineColumns(false), colPipelineName),
 	}
 
+	client.Subscribe(v.is, types.TopicQuery)
+	client.Poll(v.is, api.PollPipelines, 10*time.Second, client.QueryPipelines)
+
 	return v
 }
 
+// pipelineColumns returns the columns of the pipelines table, including the
+// target column in aggregate mode.
+func pipelineColumns(aggregate bool) []table.Column {
+	var columns []table.Column
+
+	if aggregate {
+		columns = append(columns, table.NewFlexColumn(colPipelineTarget, "Target", 3).WithFiltered(true))
+	}
+
+	return append(columns,
+		table.NewColumn(colPipelineID, "ID", 4),
+		table.NewFlexColumn(colPipelineName, "Name", 5).WithFiltered(true),
+		table.NewFlexColumn(colPipelineInstanceVars, "Instance Vars", 4).WithFiltered(true),
+		table.NewColumn(colPipelinePaused, "Pause", 5),
+		table.NewColumn(colPipelinePublic, "Public", 6),
+		table.NewColumn(colPipelineArchived, "Archive", 7),
+		table.NewFlexColumn(colPipelineTeam, "Team", 4).WithFiltered(true),
+		table.NewFlexColumn(colPipelineLastUpdated, "Last Updated", 2),
+	)
+}
+
+// setAggregate enables or disables aggregate mode, for the provided targets (or
+// all targets, if empty).
+func (v *Pipelines) setAggregate(aggregate bool, targets []string) {
+	v.aggregate = aggregate
+	v.targets = targets
+	v.allCache = nil
+	v.model.SetColumns(pipelineColumns(aggregate))
+
+	if aggregate {
+		v.client.Poll(v.is, api.PollPipelines, 10*time.Second, v.client.QueryAllPipelines(targets))
+	} else {
+		v.client.Poll(v.is, api.PollPipelines, 10*time.Second, v.client.QueryPipelines)
+	}
+
+	v.UpdateRows()
+}
+
 func (v *Pipelines) UpdateRows() {
+	if v.aggregate {
+		v.updateAggregateRows()
+		return
+	}
+
+	v.model.UpdateRows(v.pipelineRows(v.client.ActiveName(), v.pipelineCache.Pipelines, nil))
+}
+
+// updateAggregateRows updates the rows with the pipelines of all selected
+// targets. Targets which failed without an offline snapshot are shown as a
+// single error row.
+func (v *Pipelines) updateAggregateRows() {
+	var rows []table.Row
+
+	failure := lipgloss.NewStyle().Foreground(types.Theme.FailureFg)
+
+	for _, result := range v.allCache {
+		if result.Error != nil && result.Stale.IsZero() {
+			rows = append(rows, table.NewRow(table.RowData{
+				colPipelineTarget:     table.NewStyledCell(result.Target, failure),
+				colPipelineTargetName: result.Target,
+				colPipelineName:       table.NewStyledCell(result.Error.Error(), failure),
+			}))
+			continue
+		}
+
+		var target any = result.Target
+		if !result.Stale.IsZero() {
+			target = table.NewStyledCell(fmt.Sprintf("%s (offline, %s)", result.Target, humanize.Time(result.Stale)), failure)
+		}
+
+		rows = append(rows, v.pipelineRows(result.Target, result.Pipelines, target)...)
+	}
+
+	v.model.UpdateRows(rows)
+}
+
+// pipelineRows returns the rows for the pipelines of the provided target.
+// targetCell is shown in the target column (in aggregate mode).
+func (v *Pipelines) pipelineRows(target string, pipelines []atc.Pipeline, targetCell any) []table.Row {
 	var rows []table.Row
 	var row table.RowData
 
-	for _, data := range v.pipelineCache.Pipelines {
+	for _, data := range pipelines {
 		if !v.showArchived && data.Archived {
 			continue
 		}
 
 		row = table.RowData{
+			colPipelineTarget:         targetCell,
+			colPipelineTargetName:     target,
 			colPipelineID:             table.NewStyledCell(data.ID, lipgloss.NewStyle().Align(lipgloss.Right)),
 			colPipelineName:           data.Name,
 			colPipelineInstanceVars:   data.InstanceVars.String(),
@@ -79,18 +189,19 @@ func (v *Pipelines) UpdateRows() {
 			colPipelineTeam:           data.TeamName,
 			colPipelineLastUpdated:    humanize.Time(time.Unix(data.LastUpdated, 0)),
 			colPipelineLastUpdatedRaw: data.LastUpdated,
+			colPipelineRef:            data.Ref(),
 		}
 
 		rows = append(rows, table.NewRow(row))
 	}
 
-	v.model.UpdateRows(rows)
+	return rows
 }
 
 func (v *Pipelines) Init() tea.Cmd {
 	return tea.Batch(
 		v.model.Init(),
-		api.Manager.QueryPipelines,
+		v.client.LoadPipelinesSnapshot,
 	)
 }
 
@@ -102,11 +213,24 @@ func (v *Pipelines) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch {
 		case key.Matches(msg, types.KeyEnter):
-			api.Manager.SetActive(v.model.SelectedRow().Data[colKeyTargetName].(string))
+			// In aggregate mode, switch to the target of the selected row.
+			target, ok := v.model.SelectedRow().Data[colPipelineTargetName].(string)
+			if !ok || target == v.client.ActiveName() {
+				return v, nil
+			}
+
+			if err := v.client.SetActive(target); err != nil {
+				return v, types.MsgAsCmd(types.NotifyMsg{Error: err})
+			}
+
 			v.UpdateRows()
 			return v, nil
+		case key.Matches(msg, types.KeyAllTargets):
+			v.setAggregate(!v.aggregate, nil)
+			return v, nil
 		case key.Matches(msg, types.KeyRefresh):
-			return v, api.Manager.QueryPipelines
+			v.client.Refresh(api.PollPipelines)
+			return v, nil
 		case key.Matches(msg, types.KeySortName):
 			v.model.Sort(colPipelineName)
 			return v, nil
@@ -117,19 +241,73 @@ func (v *Pipelines) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			v.showArchived = !v.showArchived
 			v.UpdateRows()
 			return v, nil
+		case key.Matches(msg, types.KeyEdit):
+			row := v.model.SelectedRow().Data
+
+			ref, ok := row[colPipelineRef].(atc.PipelineRef)
+			if !ok {
+				return v, nil
+			}
+
+			target, ok := row[colPipelineTargetName].(string)
+			if !ok {
+				return v, nil
+			}
+
+			team, ok := row[colPipelineTeam].(string)
+			if !ok {
+				return v, nil
+			}
+
+			return v, v.client.QueryPipelineConfig(v.is, target, team, ref)
 		}
-	case types.ViewChangeMsg:
-		if msg.View == v.is {
-			return v, api.Manager.QueryPipelines
+	case api.PipelineConfigMsg:
+		if msg.Error != nil {
+			return v, types.MsgAsCmd(types.NotifyMsg{Error: msg.Error})
 		}
+
+		return v, v.editConfig(msg)
+	case pipelineEditedMsg:
+		return v, v.confirmConfig(msg)
+	case api.PipelineConfigAppliedMsg:
+		if msg.Error != nil {
+			return v, types.MsgAsCmd(types.NotifyMsg{Error: msg.Error})
+		}
+
+		text := "applied config of " + msg.Pipeline.String()
+		if len(msg.Warnings) > 0 {
+			text += " (warnings: " + strings.Join(msg.Warnings, "; ") + ")"
+		}
+
+		v.client.Refresh(api.PollPipelines)
+		return v, types.MsgAsCmd(types.NotifyMsg{Text: text})
 	case api.PipelineListMsg:
+		switch {
+		case msg.Error == nil && !msg.Stale.IsZero():
+			// Snapshot loaded on startup, only used until the first query.
+			if v.queried {
+				return v, nil
+			}
+		case errors.As(msg.Error, &api.NeedsLoginError{}) && msg.Stale.IsZero():
+			// Keep showing the last known pipelines while the target needs login.
+			v.queried = true
+			return v, nil
+		default:
+			v.queried = true
+		}
+
 		v.pipelineCache = msg
 		v.UpdateRows()
-
-		if v.Focused() {
-			return v, types.DelayCmd(10*time.Second, api.Manager.QueryPipelines)
+		return v, nil
+	case api.AllPipelinesMsg:
+		if v.aggregate {
+			v.allCache = msg
+			v.UpdateRows()
 		}
 		return v, nil
+	case AllTargetsMsg:
+		v.setAggregate(true, msg.Targets)
+		return v, nil
 	}
 
 	var cmd tea.Cmd
@@ -137,6 +315,83 @@ func (v *Pipelines) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return v, cmd
 }
 
+// editConfig writes the provided config to a temporary file, and opens it in
+// the user's editor.
+func (v *Pipelines) editConfig(config api.PipelineConfigMsg) tea.Cmd {
+	f, err := os.CreateTemp("", "hangar-ui-"+config.Pipeline.Name+"-*.yml")
+	if err != nil {
+		return types.MsgAsCmd(types.NotifyMsg{Error: err})
+	}
+
+	_, err = f.Write(config.Config)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return types.MsgAsCmd(types.NotifyMsg{Error: err})
+	}
+
+	return tea.ExecProcess(editorCommand(f.Name()), func(err error) tea.Msg {
+		return pipelineEditedMsg{config: config, path: f.Name(), err: err}
+	})
+}
+
+// confirmConfig reads the edited config, and asks the user to confirm the
+// changes before applying them.
+func (v *Pipelines) confirmConfig(msg pipelineEditedMsg) tea.Cmd {
+	defer os.Remove(msg.path)
+
+	if msg.err != nil {
+		return types.MsgAsCmd(types.NotifyMsg{Error: fmt.Errorf("editor exited with error: %w", msg.err)})
+	}
+
+	edited, err := os.ReadFile(msg.path)
+	if err != nil {
+		return types.MsgAsCmd(types.NotifyMsg{Error: err})
+	}
+
+	if bytes.Equal(bytes.TrimSpace(edited), bytes.TrimSpace(msg.config.Config)) {
+		return types.MsgAsCmd(types.NotifyMsg{Text: "no changes made to " + msg.config.Pipeline.String()})
+	}
+
+	return types.MsgAsCmd(types.ConfirmMsg{
+		Title: fmt.Sprintf(
+			"apply the following changes to %s/%s on %s?",
+			msg.config.Team, msg.config.Pipeline.String(), msg.config.Target,
+		),
+		Body: renderDiff(string(msg.config.Config), string(edited)),
+		Cmd: v.client.ApplyPipelineConfig(
+			msg.config.Target,
+			msg.config.Team,
+			msg.config.Pipeline,
+			msg.config.Version,
+			edited,
+		),
+	})
+}
+
+// editorCommand returns the command used to edit the provided file, using
+// $VISUAL or $EDITOR if set.
+func editorCommand(path string) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+
+	args := strings.Fields(editor)
+	if len(args) == 0 {
+		args = []string{"vi"}
+
+		if runtime.GOOS == "windows" {
+			args = []string{"notepad"}
+		}
+	}
+
+	return exec.Command(args[0], append(args[1:], path)...)
+}
+
 func (v *Pipelines) View() string {
 	return v.model.View()
 }
@@ -0,0 +1,63 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package view_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/concourse/concourse/atc"
+	"github.com/lrstanley/hangar-ui/internal/fakeatc"
+	"github.com/lrstanley/hangar-ui/internal/types"
+	"github.com/lrstanley/hangar-ui/internal/ui/uitest"
+)
+
+// showPipelines switches to the pipelines view, and waits for the pipelines
+// to be loaded.
+func showPipelines(h *uitest.Harness) {
+	h.Send(
+		types.ViewChangeMsg{View: types.ViewPipelines},
+		types.FocusChangeMsg{View: types.ViewPipelines},
+	)
+	h.Settle()
+}
+
+func TestPipelines(t *testing.T) {
+	state := fakeatc.NewState()
+	state.AddPipeline(atc.Pipeline{Name: "app", LastUpdated: 1}, nil, nil)
+	state.AddPipeline(atc.Pipeline{Name: "infra", Paused: true, Public: true, LastUpdated: 2}, nil, nil)
+	state.AddPipeline(atc.Pipeline{
+		Name:         "deploy",
+		InstanceVars: atc.InstanceVars{"env": "prod"},
+		LastUpdated:  3,
+	}, nil, nil)
+
+	h, _ := newHarness(t, state)
+
+	showPipelines(h)
+	h.AssertGolden("pipelines")
+}
+
+func TestPipelinesEmpty(t *testing.T) {
+	h, _ := newHarness(t, fakeatc.NewState())
+
+	showPipelines(h)
+	h.AssertGolden("pipelines_empty")
+}
+
+func TestPipelinesPaginated(t *testing.T) {
+	state := fakeatc.NewState()
+	for i := 1; i <= 40; i++ {
+		state.AddPipeline(atc.Pipeline{Name: fmt.Sprintf("pipeline-%02d", i), LastUpdated: 1}, nil, nil)
+	}
+
+	h, _ := newHarness(t, state)
+
+	showPipelines(h)
+	h.AssertGolden("pipelines_paginated")
+
+	h.Resize(80, 20)
+	h.AssertGolden("pipelines_paginated_small")
+}
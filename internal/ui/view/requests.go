@@ -0,0 +1,329 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package view
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/apex/log"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/dustin/go-humanize"
+	"github.com/evertras/bubble-table/table"
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/lrstanley/hangar-ui/internal/api"
+	"github.com/lrstanley/hangar-ui/internal/types"
+	"github.com/lrstanley/hangar-ui/internal/ui/model"
+)
+
+const (
+	colRequestID      = "id"
+	colRequestTime    = "time"
+	colRequestTarget  = "target"
+	colRequestMethod  = "method"
+	colRequestPath    = "path"
+	colRequestStatus  = "status"
+	colRequestLatency = "latency"
+	colRequestSize    = "size"
+	colRequestError   = "error"
+)
+
+// Requests is a debug view, which lists all HTTP requests made to the ATC, and
+// allows inspecting the raw request and response of each.
+type Requests struct {
+	*Base
+	model  model.Table
+	detail viewport.Model
+
+	records []api.RequestRecord
+	open    int // ID of the request being inspected, 0 if none.
+
+	titleStyle   lipgloss.Style
+	hintStyle    lipgloss.Style
+	sectionStyle lipgloss.Style
+}
+
+func NewRequests(app types.App, client api.Manager) *Requests {
+	v := &Requests{
+		Base: &Base{
+			app:    app,
+			client: client,
+			is:     types.ViewRequests,
+			logger: log.WithField("src", "requests"),
+		},
+		model: model.NewTable(app, types.ViewRequests, []table.Column{
+			table.NewColumn(colRequestID, "#", 6),
+			table.NewColumn(colRequestTime, "Time", 10),
+			table.NewFlexColumn(colRequestTarget, "Target", 1).WithFiltered(true),
+			table.NewColumn(colRequestMethod, "Method", 8).WithFiltered(true),
+			table.NewFlexColumn(colRequestPath, "Path", 4).WithFiltered(true),
+			table.NewColumn(colRequestStatus, "Status", 8).WithFiltered(true),
+			table.NewColumn(colRequestLatency, "Latency", 9),
+			table.NewColumn(colRequestSize, "Size", 10),
+			table.NewFlexColumn(colRequestError, "Error", 2).WithFiltered(true),
+		}, colRequestID),
+		detail: viewport.New(0, 0),
+	}
+
+	// Newest requests first.
+	v.model.Sort(colRequestID)
+
+	v.titleStyle = lipgloss.NewStyle().
+		Background(types.Theme.TitleBg).
+		Foreground(types.Theme.TitleFg).
+		Padding(0, 1)
+
+	v.hintStyle = lipgloss.NewStyle().
+		Background(types.Theme.Bg).
+		Foreground(types.Theme.InputPlaceholderFg)
+
+	v.sectionStyle = lipgloss.NewStyle().
+		Background(types.Theme.Bg).
+		Foreground(types.Theme.TitleBg).
+		Bold(true)
+
+	client.Subscribe(v.is, types.TopicQuery)
+	client.Poll(v.is, api.PollRequests, time.Second, client.QueryRequests)
+
+	return v
+}
+
+func (v *Requests) UpdateRows() {
+	rows := make([]table.Row, 0, len(v.records))
+
+	failure := lipgloss.NewStyle().Foreground(types.Theme.FailureFg)
+	success := lipgloss.NewStyle().Foreground(types.Theme.SuccessFg)
+
+	for _, rec := range v.records {
+		row := table.RowData{
+			colRequestID:     rec.ID,
+			colRequestTime:   rec.Time.Format("15:04:05"),
+			colRequestTarget: rec.Target,
+			colRequestMethod: rec.Method,
+			colRequestPath:   rec.Path,
+			colRequestSize:   humanize.Bytes(uint64(rec.ResponseSize)),
+		}
+
+		switch {
+		case rec.Error != nil && rec.Status == 0:
+			row[colRequestStatus] = table.NewStyledCell("failed", failure)
+		case rec.Status == 0:
+			row[colRequestStatus] = "..."
+		case rec.Status >= http.StatusBadRequest:
+			row[colRequestStatus] = table.NewStyledCell(fmt.Sprint(rec.Status), failure)
+		default:
+			row[colRequestStatus] = table.NewStyledCell(fmt.Sprint(rec.Status), success)
+		}
+
+		if rec.Status != 0 || rec.Error != nil {
+			row[colRequestLatency] = rec.Latency.Round(time.Millisecond).String()
+		}
+
+		if rec.Status != 0 && !rec.Done {
+			row[colRequestSize] = humanize.Bytes(uint64(rec.ResponseSize)) + "+"
+		}
+
+		if rec.Error != nil {
+			row[colRequestError] = table.NewStyledCell(rec.Error.Error(), failure)
+		}
+
+		rows = append(rows, table.NewRow(row))
+	}
+
+	v.model.UpdateRows(rows)
+}
+
+// updateDetail renders the request being inspected (if any).
+func (v *Requests) updateDetail() {
+	i := sort.Search(len(v.records), func(i int) bool { return v.records[i].ID >= v.open })
+	if i == len(v.records) || v.records[i].ID != v.open {
+		v.detail.SetContent(v.hintStyle.Render("request is no longer recorded"))
+		return
+	}
+
+	rec := v.records[i]
+
+	var out strings.Builder
+
+	out.WriteString(rec.Method + " " + rec.URL + "\n")
+	out.WriteString(fmt.Sprintf("target: %s, sent: %s", rec.Target, rec.Time.Format(time.RFC3339)))
+
+	if rec.Status != 0 {
+		out.WriteString(fmt.Sprintf(
+			", status: %d %s, latency: %s",
+			rec.Status, http.StatusText(rec.Status), rec.Latency.Round(time.Millisecond),
+		))
+	}
+
+	out.WriteString("\n")
+
+	if rec.Error != nil {
+		out.WriteString("error: " + rec.Error.Error() + "\n")
+	}
+
+	out.WriteString("\n" + v.sectionStyle.Render("> request") + "\n")
+	out.WriteString(formatHeader(rec.RequestHeader))
+	out.WriteString("\n" + formatBody(rec.RequestBody, rec.RequestSize) + "\n")
+
+	out.WriteString("\n" + v.sectionStyle.Render("< response") + "\n")
+
+	if rec.Status == 0 {
+		out.WriteString(v.hintStyle.Render("no response") + "\n")
+	} else {
+		out.WriteString(formatHeader(rec.ResponseHeader))
+		out.WriteString("\n" + formatBody(rec.ResponseBody, rec.ResponseSize) + "\n")
+
+		if !rec.Done {
+			out.WriteString(v.hintStyle.Render("(still being received)") + "\n")
+		}
+	}
+
+	v.detail.SetContent(out.String())
+}
+
+// formatHeader formats a header the same way as on the wire, sorted by name.
+func formatHeader(header http.Header) string {
+	var buf bytes.Buffer
+	_ = header.Write(&buf)
+
+	lines := strings.Split(strings.TrimSpace(strings.ReplaceAll(buf.String(), "\r\n", "\n")), "\n")
+	sort.Strings(lines)
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// formatBody formats a recorded body, indenting JSON, and noting when it was
+// truncated.
+func formatBody(body []byte, size int64) string {
+	if size == 0 && len(body) == 0 {
+		return "(empty body)"
+	}
+
+	if !utf8.Valid(body) {
+		return fmt.Sprintf("(%s of binary data)", humanize.Bytes(uint64(size)))
+	}
+
+	out := string(body)
+
+	var indented bytes.Buffer
+	if json.Indent(&indented, body, "", "  ") == nil {
+		out = indented.String()
+	}
+
+	if size > int64(len(body)) {
+		out += fmt.Sprintf("\n(truncated, showing %s of %s)", humanize.Bytes(uint64(len(body))), humanize.Bytes(uint64(size)))
+	}
+
+	return out
+}
+
+func (v *Requests) Init() tea.Cmd {
+	return v.model.Init()
+}
+
+func (v *Requests) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		v.height = msg.Height
+		v.width = msg.Width
+		v.detail.Height = msg.Height - v.detail.Style.GetVerticalFrameSize() - 4 // 2 for border, 2 for title/hint.
+		v.detail.Width = msg.Width - v.detail.Style.GetHorizontalFrameSize() - 4 // 2 for border, 2 for padding.
+	case tea.MouseMsg:
+		if v.open != 0 {
+			if !zone.Get(string(v.is)).InBounds(msg) {
+				return v, nil
+			}
+
+			switch msg.Type {
+			case tea.MouseLeft, tea.MouseRight:
+				return v, types.MsgAsCmd(types.FocusChangeMsg{View: v.is})
+			}
+
+			var cmd tea.Cmd
+			v.detail, cmd = v.detail.Update(msg)
+			return v, cmd
+		}
+	case tea.KeyMsg:
+		if v.open != 0 {
+			if key.Matches(msg, types.KeyCancel) {
+				v.open = 0
+				return v, nil
+			}
+
+			var cmd tea.Cmd
+			v.detail, cmd = v.detail.Update(msg)
+			return v, cmd
+		}
+
+		switch {
+		case key.Matches(msg, types.KeyEnter):
+			id, ok := v.model.SelectedRow().Data[colRequestID].(int)
+			if !ok {
+				return v, nil
+			}
+
+			v.open = id
+			v.updateDetail()
+			v.detail.GotoTop()
+			return v, nil
+		case key.Matches(msg, types.KeyClearRequests):
+			v.client.ClearRequests()
+			v.records = nil
+			v.UpdateRows()
+			return v, nil
+		case key.Matches(msg, types.KeyRefresh):
+			v.client.Refresh(api.PollRequests)
+			return v, nil
+		}
+	case api.RequestLogMsg:
+		v.records = msg
+		v.UpdateRows()
+
+		if v.open != 0 {
+			v.updateDetail()
+		}
+		return v, nil
+	}
+
+	var cmd tea.Cmd
+	v.model, cmd = v.model.Update(msg)
+	return v, cmd
+}
+
+func (v *Requests) View() string {
+	if v.open == 0 {
+		return v.model.View()
+	}
+
+	s := lipgloss.NewStyle().
+		Width(v.width-2). // 2 for border
+		Height(v.height-2).
+		MaxHeight(v.height).
+		MaxWidth(v.width).
+		Padding(0, 1).
+		Background(types.Theme.Bg).
+		Border(lipgloss.RoundedBorder()).
+		BorderBackground(types.Theme.ViewBorderBg).
+		BorderForeground(types.Theme.ViewBorderInactiveFg)
+
+	if v.Focused() {
+		s = s.BorderForeground(types.Theme.ViewBorderActiveFg)
+	}
+
+	out := v.titleStyle.Render(fmt.Sprintf("request #%d", v.open)) + "\n" +
+		v.hintStyle.Render("press <esc> to go back to the list of requests") + "\n" +
+		v.detail.View()
+
+	return zone.Mark(string(v.is), s.Render(out))
+}
@@ -0,0 +1,202 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package view
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/concourse/concourse/fly/rc"
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/lrstanley/hangar-ui/internal/api"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+// editTargetMsg resets the target editor, to edit the provided target, or to
+// add a new one if target is empty.
+type editTargetMsg struct {
+	target string
+}
+
+// TargetEditor allows adding a target to the flyrc, or editing (and renaming)
+// an existing one.
+type TargetEditor struct {
+	*Base
+
+	target  string // Empty when adding a new target.
+	form    *targetForm
+	pending bool
+	err     error
+
+	titleStyle lipgloss.Style
+	hintStyle  lipgloss.Style
+	errorStyle lipgloss.Style
+}
+
+func NewTargetEditor(app types.App, client api.Manager) *TargetEditor {
+	v := &TargetEditor{
+		Base: &Base{
+			app:    app,
+			client: client,
+			is:     types.ViewTargetEditor,
+			logger: log.WithField("src", "target-editor"),
+		},
+		form: newTargetForm(),
+	}
+
+	v.titleStyle = lipgloss.NewStyle().
+		Background(types.Theme.TitleBg).
+		Foreground(types.Theme.TitleFg).
+		Padding(0, 1)
+
+	v.hintStyle = lipgloss.NewStyle().
+		Background(types.Theme.Bg).
+		Foreground(types.Theme.InputPlaceholderFg)
+
+	v.errorStyle = lipgloss.NewStyle().
+		Background(types.Theme.Bg).
+		Foreground(types.Theme.FailureFg)
+
+	return v
+}
+
+// reset resets the form, to edit the provided target (or add a new one).
+func (v *TargetEditor) reset(target string) {
+	v.target = target
+	v.pending = false
+	v.err = nil
+
+	cfg := api.TargetConfig{Team: "main"}
+
+	if props, ok := v.client.Targets()[rc.TargetName(target)]; ok {
+		cfg = api.TargetConfig{
+			Name:     target,
+			API:      props.API,
+			Team:     props.TeamName,
+			Insecure: props.Insecure,
+		}
+	}
+
+	v.form.reset(cfg)
+	v.form.setEditing(target != "")
+}
+
+func (v *TargetEditor) Init() tea.Cmd { return nil }
+
+func (v *TargetEditor) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		v.height = msg.Height
+		v.width = msg.Width
+		v.form.setWidth(msg.Width - 4) // 2 for border, 2 for padding.
+		return v, nil
+	case tea.MouseMsg:
+		if !zone.Get(string(v.is)).InBounds(msg) {
+			return v, nil
+		}
+
+		switch msg.Type {
+		case tea.MouseLeft, tea.MouseRight:
+			return v, types.MsgAsCmd(types.FocusChangeMsg{View: v.is})
+		}
+		return v, nil
+	case editTargetMsg:
+		v.reset(msg.target)
+		return v, textinput.Blink
+	case api.TargetSavedMsg:
+		if !v.pending {
+			return v, nil
+		}
+
+		v.pending = false
+		v.err = msg.Error
+
+		if msg.Error != nil {
+			return v, nil
+		}
+
+		if v.target != "" {
+			return v, tea.Batch(
+				types.MsgAsCmd(types.NotifyMsg{Text: fmt.Sprintf("saved target %q", msg.Target)}),
+				types.MsgAsCmd(types.AppBackMsg{Focused: true}),
+			)
+		}
+
+		// New targets have no credentials yet, so login right away.
+		return v, tea.Batch(
+			types.MsgAsCmd(types.NotifyMsg{Text: fmt.Sprintf("added target %q", msg.Target)}),
+			types.MsgAsCmd(types.ViewMsg{
+				View: types.ViewLogin,
+				Msg:  loginTargetMsg{target: msg.Target, next: types.ViewTargets},
+			}),
+			types.MsgAsCmd(types.ViewChangeMsg{View: types.ViewLogin}),
+			types.MsgAsCmd(types.FocusChangeMsg{View: types.ViewLogin}),
+		)
+	case tea.KeyMsg:
+		if key.Matches(msg, types.KeyCancel) {
+			return v, types.MsgAsCmd(types.AppBackMsg{Focused: true})
+		}
+
+		if v.pending {
+			return v, nil
+		}
+	}
+
+	submit, cmd := v.form.update(msg)
+	if !submit {
+		return v, cmd
+	}
+
+	v.pending = true
+	v.err = nil
+
+	if v.target == "" {
+		return v, v.client.AddTarget(v.form.config())
+	}
+
+	return v, v.client.EditTarget(v.target, v.form.config())
+}
+
+func (v *TargetEditor) View() string {
+	s := lipgloss.NewStyle().
+		Width(v.width-2). // 2 for border
+		Height(v.height-2).
+		MaxHeight(v.height).
+		MaxWidth(v.width).
+		Padding(0, 1).
+		Background(types.Theme.Bg).
+		Border(lipgloss.RoundedBorder()).
+		BorderBackground(types.Theme.ViewBorderBg).
+		BorderForeground(types.Theme.ViewBorderInactiveFg)
+
+	if v.Focused() {
+		s = s.BorderForeground(types.Theme.ViewBorderActiveFg)
+	}
+
+	title := "add target"
+	if v.target != "" {
+		title = "edit target " + v.target
+	}
+
+	var out strings.Builder
+
+	out.WriteString(v.titleStyle.Render(title) + "\n")
+	out.WriteString(v.hintStyle.Render("press <tab> to move between fields, <enter> on the last field to save, <esc> to cancel") + "\n\n")
+	out.WriteString(v.form.View() + "\n")
+
+	switch {
+	case v.pending:
+		out.WriteString(v.hintStyle.Render("saving...") + "\n")
+	case v.err != nil:
+		out.WriteString(v.errorStyle.Render(types.XMark+" "+v.err.Error()) + "\n")
+	}
+
+	return zone.Mark(string(v.is), s.Render(out.String()))
+}
@@ -0,0 +1,181 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package view
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/lrstanley/hangar-ui/internal/api"
+	"github.com/lrstanley/hangar-ui/internal/types"
+)
+
+const (
+	targetFieldName = iota
+	targetFieldAPI
+	targetFieldTeam
+	targetFieldCACert
+	targetFieldInsecure
+)
+
+// targetForm is a form for the config of a target, shared by views which add
+// or edit targets.
+type targetForm struct {
+	inputs   []textinput.Model // All fields, except insecure.
+	insecure bool
+	focus    int
+
+	labelStyle lipgloss.Style
+	hintStyle  lipgloss.Style
+	inputStyle lipgloss.Style
+}
+
+func newTargetForm() *targetForm {
+	f := &targetForm{
+		inputs: make([]textinput.Model, targetFieldInsecure),
+	}
+
+	for i := range f.inputs {
+		f.inputs[i] = textinput.New()
+		f.inputs[i].Prompt = ""
+		f.inputs[i].PlaceholderStyle = f.inputs[i].PlaceholderStyle.Background(types.Theme.Bg).Foreground(types.Theme.InputPlaceholderFg)
+		f.inputs[i].TextStyle = f.inputs[i].TextStyle.Background(types.Theme.Bg).Foreground(types.Theme.InputFg)
+		f.inputs[i].CursorStyle = f.inputs[i].CursorStyle.Background(types.Theme.Bg).Foreground(types.Theme.InputCursorFg)
+	}
+
+	f.inputs[targetFieldName].Placeholder = "e.g. ci"
+	f.inputs[targetFieldAPI].Placeholder = "e.g. https://ci.example.com"
+	f.inputs[targetFieldTeam].Placeholder = "main"
+	f.inputs[targetFieldCACert].Placeholder = "path to a PEM encoded CA cert (optional)"
+
+	f.labelStyle = lipgloss.NewStyle().
+		Background(types.Theme.Bg).
+		Foreground(types.Theme.Fg).
+		Width(10)
+
+	f.hintStyle = lipgloss.NewStyle().
+		Background(types.Theme.Bg).
+		Foreground(types.Theme.InputPlaceholderFg)
+
+	f.inputStyle = lipgloss.NewStyle().
+		Background(types.Theme.Bg).
+		Foreground(types.Theme.InputFg)
+
+	return f
+}
+
+// reset resets the form to the provided config.
+func (f *targetForm) reset(cfg api.TargetConfig) {
+	f.inputs[targetFieldName].SetValue(cfg.Name)
+	f.inputs[targetFieldAPI].SetValue(cfg.API)
+	f.inputs[targetFieldTeam].SetValue(cfg.Team)
+	f.inputs[targetFieldCACert].SetValue(cfg.CACert)
+	f.insecure = cfg.Insecure
+
+	for i := range f.inputs {
+		f.inputs[i].CursorEnd()
+	}
+
+	f.setFocus(targetFieldName)
+}
+
+// setEditing sets whether the form edits an existing target, rather than
+// adding a new one.
+func (f *targetForm) setEditing(editing bool) {
+	if editing {
+		f.inputs[targetFieldCACert].Placeholder = "path to a new PEM encoded CA cert, empty to keep the current one, or \"-\" to remove it"
+		return
+	}
+
+	f.inputs[targetFieldCACert].Placeholder = "path to a PEM encoded CA cert (optional)"
+}
+
+// config returns the config as entered into the form.
+func (f *targetForm) config() api.TargetConfig {
+	return api.TargetConfig{
+		Name:     f.inputs[targetFieldName].Value(),
+		API:      f.inputs[targetFieldAPI].Value(),
+		Team:     f.inputs[targetFieldTeam].Value(),
+		CACert:   f.inputs[targetFieldCACert].Value(),
+		Insecure: f.insecure,
+	}
+}
+
+func (f *targetForm) setFocus(field int) {
+	f.focus = (field + targetFieldInsecure + 1) % (targetFieldInsecure + 1)
+
+	for i := range f.inputs {
+		if i == f.focus {
+			f.inputs[i].Focus()
+			continue
+		}
+		f.inputs[i].Blur()
+	}
+}
+
+func (f *targetForm) setWidth(width int) {
+	for i := range f.inputs {
+		f.inputs[i].Width = width - f.labelStyle.GetWidth() - 2 // 2 for cursor.
+	}
+}
+
+// update updates the form with the provided message. submit is true if the
+// user submitted the form.
+func (f *targetForm) update(msg tea.Msg) (submit bool, cmd tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(msg, types.KeyNextField):
+			f.setFocus(f.focus + 1)
+			return false, nil
+		case key.Matches(msg, types.KeyPrevField):
+			f.setFocus(f.focus - 1)
+			return false, nil
+		case key.Matches(msg, types.KeyEnter):
+			if f.focus != targetFieldInsecure {
+				f.setFocus(f.focus + 1)
+				return false, nil
+			}
+
+			return true, nil
+		case f.focus == targetFieldInsecure:
+			if msg.String() == " " {
+				f.insecure = !f.insecure
+			}
+			return false, nil
+		}
+	}
+
+	if f.focus == targetFieldInsecure {
+		return false, nil
+	}
+
+	f.inputs[f.focus], cmd = f.inputs[f.focus].Update(msg)
+	return false, cmd
+}
+
+func (f *targetForm) View() string {
+	var out strings.Builder
+
+	for i, label := range []string{"name", "api url", "team", "ca cert"} {
+		out.WriteString(f.labelStyle.Render(label) + f.inputs[i].View() + "\n")
+	}
+
+	insecure := "[ ]"
+	if f.insecure {
+		insecure = "[" + types.Checkmark + "]"
+	}
+
+	style := f.hintStyle
+	if f.focus == targetFieldInsecure {
+		style = f.inputStyle
+	}
+
+	out.WriteString(f.labelStyle.Render("insecure") + style.Render(insecure+" skip TLS verification (press <space> to toggle)") + "\n")
+
+	return out.String()
+}
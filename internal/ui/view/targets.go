@@ -5,13 +5,17 @@
 package view
 
 import (
-	"os/exec"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/apex/log"
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/concourse/concourse/fly/rc"
+	"github.com/dustin/go-humanize"
 	"github.com/evertras/bubble-table/table"
 	"github.com/lrstanley/hangar-ui/internal/api"
 	"github.com/lrstanley/hangar-ui/internal/types"
@@ -25,6 +29,9 @@ const (
 	colKeyTargetTeam     = "team"
 	colKeyTargetInsecure = "insecure"
 	colKeyClusterVersion = "cluster_version"
+	colKeyTargetLatency  = "latency"
+	colKeyTargetWarnings = "warnings"
+	colKeyTarget         = "target"
 )
 
 type Targets struct {
@@ -34,10 +41,11 @@ type Targets struct {
 	targetInfoCache api.TargetInfoMsg
 }
 
-func NewTargets(app types.App) *Targets {
+func NewTargets(app types.App, client api.Manager) *Targets {
 	v := &Targets{
 		Base: &Base{
 			app:    app,
+			client: client,
 			is:     types.ViewTargets,
 			logger: log.WithField("src", "targets"),
 		},
@@ -48,9 +56,14 @@ func NewTargets(app types.App) *Targets {
 			table.NewFlexColumn(colKeyTargetTeam, "Team", 2).WithFiltered(true),
 			table.NewFlexColumn(colKeyTargetInsecure, "Insecure", 1),
 			table.NewFlexColumn(colKeyClusterVersion, "Version", 1),
+			table.NewFlexColumn(colKeyTargetLatency, "Latency", 1),
+			table.NewFlexColumn(colKeyTargetWarnings, "Warnings", 3),
 		}, colKeyTargetName),
 	}
 
+	client.Subscribe(v.is, types.TopicTargets, types.TopicState, types.TopicQuery)
+	client.Poll(v.is, api.PollTargetInfo, 10*time.Second, client.QueryTargetInfo)
+
 	return v
 }
 
@@ -60,28 +73,63 @@ func (v *Targets) UpdateRows() {
 
 	for _, data := range v.targetInfoCache {
 		row = table.RowData{
-			colKeyTargetName:     string(data.TargetName),
-			colKeyTargetURL:      data.Target.URL(),
-			colKeyTargetTeam:     data.Target.Team().Name(),
+			colKeyTarget:         data.TargetName,
+			colKeyTargetName:     data.TargetName,
 			colKeyTargetInsecure: v.model.Checkmark(false),
 		}
 
-		if data.TargetName == api.Manager.ActiveName() {
+		if data.TargetName == v.client.ActiveName() {
 			row[colKeyTargetName] = string(data.TargetName) + " (active)"
 		}
 
-		if data.Target.TLSConfig() == nil || data.Target.TLSConfig().InsecureSkipVerify {
-			row[colKeyTargetInsecure] = v.model.Checkmark(true)
+		if data.Target != nil {
+			row[colKeyTargetURL] = data.Target.URL()
+			row[colKeyTargetTeam] = data.Target.Team().Name()
+
+			if data.Target.TLSConfig() == nil || data.Target.TLSConfig().InsecureSkipVerify {
+				row[colKeyTargetInsecure] = v.model.Checkmark(true)
+			}
+		} else if props, ok := v.client.Targets()[rc.TargetName(data.TargetName)]; ok {
+			// The target couldn't be loaded, fall back to what's in the flyrc.
+			row[colKeyTargetURL] = props.API
+			row[colKeyTargetTeam] = props.TeamName
+			row[colKeyTargetInsecure] = v.model.Checkmark(props.Insecure)
 		}
 
-		if data.Error == nil {
+		if data.Status == api.TargetOK || data.Status == api.TargetNeedsLogin {
+			row[colKeyTargetLatency] = data.Latency.Round(time.Millisecond).String()
+		}
+
+		failure := lipgloss.NewStyle().Foreground(types.Theme.FailureFg)
+		warning := lipgloss.NewStyle().Foreground(types.Theme.WarningFg)
+
+		switch {
+		case data.Status == api.TargetPending:
+			row[colKeyClusterName] = data.Status.String()
+		case v.client.NeedsLogin(data.TargetName):
+			row[colKeyClusterName] = table.NewStyledCell("needs login, press "+types.KeyLogin.Help().Key, failure)
+			row[colKeyClusterVersion] = data.Info.Version
+		case data.Status == api.TargetOK:
 			row[colKeyClusterName] = data.Info.ClusterName
 			row[colKeyClusterVersion] = data.Info.Version
-		} else {
-			row[colKeyClusterName] = table.NewStyledCell(data.Error.Error(), lipgloss.NewStyle().Foreground(types.Theme.FailureFg))
+		case data.Error != nil && !data.Stale.IsZero():
+			row[colKeyClusterName] = table.NewStyledCell(fmt.Sprintf(
+				"%s (%s, last seen %s)", data.Info.ClusterName, data.Status, humanize.Time(data.Stale),
+			), failure)
+			row[colKeyClusterVersion] = data.Info.Version
+		case data.Error != nil:
+			row[colKeyClusterName] = table.NewStyledCell(data.Error.Error(), failure)
 		}
 
-		if data.TargetName == api.Manager.ActiveName() {
+		if len(data.Warnings) > 0 {
+			row[colKeyTargetWarnings] = table.NewStyledCell(strings.Join(data.Warnings, "; "), warning)
+
+			if version, ok := row[colKeyClusterVersion].(string); ok {
+				row[colKeyClusterVersion] = table.NewStyledCell(version, warning)
+			}
+		}
+
+		if data.TargetName == v.client.ActiveName() {
 			rows = append(rows, table.NewRow(row).WithStyle(lipgloss.NewStyle().Bold(true)))
 		} else {
 			rows = append(rows, table.NewRow(row))
@@ -92,10 +140,7 @@ func (v *Targets) UpdateRows() {
 }
 
 func (v *Targets) Init() tea.Cmd {
-	return tea.Batch(
-		v.model.Init(),
-		api.Manager.QueryTargetInfo,
-	)
+	return v.model.Init()
 }
 
 func (v *Targets) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -106,29 +151,95 @@ func (v *Targets) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch {
 		case key.Matches(msg, types.KeyEnter):
-			api.Manager.SetActive(v.model.SelectedRow().Data[colKeyTargetName].(string))
+			target, ok := v.model.SelectedRow().Data[colKeyTarget].(string)
+			if !ok {
+				return v, nil
+			}
+
+			if err := v.client.SetActive(target); err != nil {
+				return v, types.MsgAsCmd(types.NotifyMsg{Error: err})
+			}
+
 			v.UpdateRows()
 			return v, nil
 		case key.Matches(msg, types.KeyRefresh):
-			return v, api.Manager.QueryTargetInfo
+			v.client.Refresh(api.PollTargetInfo)
+			return v, nil
 		case key.Matches(msg, types.KeyLogin):
-			// TODO: split this out (and support windows).
-			c := exec.Command("fly", "--target", api.Manager.ActiveName(), "login")
-			return v, tea.ExecProcess(c, func(err error) tea.Msg {
-				return nil
+			target, ok := v.model.SelectedRow().Data[colKeyTarget].(string)
+			if !ok {
+				target = v.client.ActiveName()
+			}
+
+			return v, tea.Batch(
+				types.MsgAsCmd(types.ViewMsg{View: types.ViewLogin, Msg: loginTargetMsg{target: target}}),
+				types.MsgAsCmd(types.ViewChangeMsg{View: types.ViewLogin}),
+				types.MsgAsCmd(types.FocusChangeMsg{View: types.ViewLogin}),
+			)
+		case key.Matches(msg, types.KeyAddTarget):
+			return v, v.openEditor("")
+		case key.Matches(msg, types.KeyEditTarget):
+			target, ok := v.model.SelectedRow().Data[colKeyTarget].(string)
+			if !ok {
+				return v, nil
+			}
+
+			return v, v.openEditor(target)
+		case key.Matches(msg, types.KeyDeleteTarget):
+			target, ok := v.model.SelectedRow().Data[colKeyTarget].(string)
+			if !ok {
+				return v, nil
+			}
+
+			return v, types.MsgAsCmd(types.ConfirmMsg{
+				Title: fmt.Sprintf("delete target %q from the flyrc?", target),
+				Cmd:   v.client.DeleteTarget(target),
 			})
 		}
-	case types.ViewChangeMsg:
-		if msg.View == v.is {
-			return v, api.Manager.QueryTargetInfo
+	case types.FlyEvent:
+		if msg == types.FlyAuthUpdated {
+			v.UpdateRows()
 		}
-	case api.TargetInfoMsg:
-		v.targetInfoCache = msg
+	case types.FlyTargetsChangedMsg:
+		// Drop removed (or renamed) targets right away, rather than once all
+		// targets have been queried again.
+		for _, name := range msg.Removed {
+			for i := range v.targetInfoCache {
+				if v.targetInfoCache[i].TargetName == name {
+					v.targetInfoCache = append(v.targetInfoCache[:i], v.targetInfoCache[i+1:]...)
+					break
+				}
+			}
+		}
+
 		v.UpdateRows()
+		v.client.Refresh(api.PollTargetInfo)
+		return v, nil
+	case api.TargetInfoUpdateMsg:
+		// Partial results, while the remaining targets are being queried.
+		// Targets which are about to be queried are only added if they aren't
+		// shown yet, rather than replacing their last result.
+		for i := range v.targetInfoCache {
+			if v.targetInfoCache[i].TargetName == msg.TargetName {
+				if msg.Status == api.TargetPending {
+					return v, nil
+				}
 
-		if v.Focused() {
-			return v, types.DelayCmd(10*time.Second, api.Manager.QueryTargetInfo)
+				v.targetInfoCache[i] = api.TargetInfo(msg)
+				v.UpdateRows()
+				return v, nil
+			}
 		}
+
+		v.targetInfoCache = append(v.targetInfoCache, api.TargetInfo(msg))
+		sort.Slice(v.targetInfoCache, func(i, j int) bool {
+			return v.targetInfoCache[i].TargetName < v.targetInfoCache[j].TargetName
+		})
+		v.UpdateRows()
+		return v, nil
+	case api.TargetInfoMsg:
+		v.targetInfoCache = msg
+		v.UpdateRows()
 		return v, nil
 	}
 
@@ -137,6 +248,16 @@ func (v *Targets) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return v, cmd
 }
 
+// openEditor opens the target editor, to edit the provided target, or to add a
+// new one if target is empty.
+func (v *Targets) openEditor(target string) tea.Cmd {
+	return tea.Batch(
+		types.MsgAsCmd(types.ViewMsg{View: types.ViewTargetEditor, Msg: editTargetMsg{target: target}}),
+		types.MsgAsCmd(types.ViewChangeMsg{View: types.ViewTargetEditor}),
+		types.MsgAsCmd(types.FocusChangeMsg{View: types.ViewTargetEditor}),
+	)
+}
+
 func (v *Targets) View() string {
 	return v.model.View()
 }
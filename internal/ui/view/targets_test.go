@@ -0,0 +1,55 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package view_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/lrstanley/hangar-ui/internal/fakeatc"
+	"github.com/lrstanley/hangar-ui/internal/types"
+	"github.com/lrstanley/hangar-ui/internal/ui/uitest"
+)
+
+// latencyRe matches latencies in the targets table (at the start of a cell, or
+// right after an escape sequence), which vary between runs, along with the
+// padding of the cell.
+var latencyRe = regexp.MustCompile(`(│|m)([0-9]+(?:\.[0-9]+)?(?:ms|µs|ns|s))((?:\x1b\[[0-9;]*m)*)( *)`)
+
+// showTargets switches to the targets view, and waits for all targets to be
+// queried. Latencies are normalized.
+func showTargets(h *uitest.Harness) {
+	h.Normalize = func(frame string) string {
+		return latencyRe.ReplaceAllStringFunc(uitest.NormalizeURL(frame), func(match string) string {
+			m := latencyRe.FindStringSubmatch(match)
+			return m[1] + "0s" + m[3] + m[4] + strings.Repeat(" ", utf8.RuneCountInString(m[2])-2)
+		})
+	}
+
+	h.Send(
+		types.ViewChangeMsg{View: types.ViewTargets},
+		types.FocusChangeMsg{View: types.ViewTargets},
+	)
+	h.Settle()
+}
+
+func TestTargets(t *testing.T) {
+	h, _ := newHarness(t, fakeatc.NewState())
+
+	showTargets(h)
+	h.AssertGolden("targets")
+}
+
+func TestTargetsNeedsLogin(t *testing.T) {
+	state := fakeatc.NewState()
+	state.TokenExpired = true
+
+	h, _ := newHarness(t, state)
+
+	showTargets(h)
+	h.AssertGolden("targets_needs_login")
+}
@@ -0,0 +1,197 @@
+// Copyright (c) Liam Stanley <me@liamstanley.io>. All rights reserved. Use
+// of this source code is governed by the MIT license that can be found in
+// the LICENSE file.
+
+package view
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/evertras/bubble-table/table"
+	"github.com/lrstanley/hangar-ui/internal/api"
+	"github.com/lrstanley/hangar-ui/internal/types"
+	"github.com/lrstanley/hangar-ui/internal/ui/model"
+)
+
+const (
+	colTriggerInput     = "input"
+	colTriggerResource  = "resource"
+	colTriggerVersion   = "version"
+	colTriggerPinned    = "pinned"
+	colTriggerSelected  = "selected"
+	colTriggerVersionID = "version_id"
+	colTriggerIndex     = "index"
+)
+
+// Trigger allows picking specific versions for each of a job's inputs, and
+// triggering a build with them.
+type Trigger struct {
+	*Base
+	model model.Table
+
+	inputCache api.JobInputVersionsMsg
+
+	// selected maps resource names to selected version IDs.
+	selected map[string]int
+}
+
+func NewTrigger(app types.App, client api.Manager) *Trigger {
+	v := &Trigger{
+		Base: &Base{
+			app:    app,
+			client: client,
+			is:     types.ViewTrigger,
+			logger: log.WithField("src", "trigger"),
+		},
+		model: model.NewTable(app, types.ViewTrigger, []table.Column{
+			table.NewFlexColumn(colTriggerInput, "Input", 2).WithFiltered(true),
+			table.NewFlexColumn(colTriggerResource, "Resource", 2).WithFiltered(true),
+			table.NewFlexColumn(colTriggerVersion, "Version", 6).WithFiltered(true),
+			table.NewColumn(colTriggerPinned, "Pinned", 6),
+			table.NewColumn(colTriggerSelected, "Selected", 8),
+		}, colTriggerIndex),
+		selected: map[string]int{},
+	}
+
+	return v
+}
+
+func (v *Trigger) UpdateRows() {
+	var rows []table.Row
+	var row table.RowData
+
+	for _, input := range v.inputCache.Inputs {
+		pinned := api.FormatVersion(input.Resource.PinnedVersion)
+
+		for _, version := range input.Versions {
+			formatted := api.FormatVersion(version.Version)
+
+			row = table.RowData{
+				colTriggerInput:     input.Input.Name,
+				colTriggerResource:  input.Input.Resource,
+				colTriggerVersion:   formatted,
+				colTriggerPinned:    v.model.Checkmark(pinned != "" && pinned == formatted),
+				colTriggerSelected:  v.model.Checkmark(v.selected[input.Input.Resource] == version.ID),
+				colTriggerVersionID: version.ID,
+				colTriggerIndex:     len(rows),
+			}
+
+			if !version.Enabled {
+				row[colTriggerVersion] = table.NewStyledCell(formatted+" (disabled)", lipgloss.NewStyle().Foreground(types.Theme.FailureFg))
+			}
+
+			rows = append(rows, table.NewRow(row))
+		}
+	}
+
+	v.model.UpdateRows(rows)
+}
+
+// toggleSelected toggles the selection of the highlighted version.
+func (v *Trigger) toggleSelected() {
+	data := v.model.SelectedRow().Data
+
+	resource, ok := data[colTriggerResource].(string)
+	if !ok {
+		return
+	}
+
+	id, ok := data[colTriggerVersionID].(int)
+	if !ok {
+		return
+	}
+
+	if v.selected[resource] == id {
+		delete(v.selected, resource)
+	} else {
+		v.selected[resource] = id
+	}
+
+	v.UpdateRows()
+}
+
+// confirmTrigger asks the user to confirm triggering the job with the selected
+// versions.
+func (v *Trigger) confirmTrigger() tea.Cmd {
+	if len(v.selected) == 0 {
+		return types.MsgAsCmd(types.NotifyMsg{Error: fmt.Errorf("no versions selected")})
+	}
+
+	var body []string
+	for _, input := range v.inputCache.Inputs {
+		id, ok := v.selected[input.Input.Resource]
+		if !ok {
+			continue
+		}
+
+		for _, version := range input.Versions {
+			if version.ID == id {
+				body = append(body, fmt.Sprintf("%s (%s): %s", input.Input.Name, input.Input.Resource, api.FormatVersion(version.Version)))
+			}
+		}
+	}
+	sort.Strings(body)
+
+	versions := make(map[string]int, len(v.selected))
+	for resource, id := range v.selected {
+		versions[resource] = id
+	}
+
+	return types.MsgAsCmd(types.ConfirmMsg{
+		Title: fmt.Sprintf("trigger %s/%s with the following versions?", v.inputCache.Pipeline.String(), v.inputCache.Job),
+		Body: strings.Join(body, "\n") +
+			"\n\nresources will be temporarily pinned, and restored once the build has started.",
+		Cmd: v.client.TriggerWithVersions(v.inputCache.Pipeline, v.inputCache.Job, versions),
+	})
+}
+
+func (v *Trigger) Init() tea.Cmd {
+	return v.model.Init()
+}
+
+func (v *Trigger) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		v.height = msg.Height
+		v.width = msg.Width
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, types.KeySelect):
+			v.toggleSelected()
+			return v, nil
+		case key.Matches(msg, types.KeyTrigger):
+			return v, v.confirmTrigger()
+		case key.Matches(msg, types.KeyRefresh):
+			if v.inputCache.Job == "" {
+				return v, nil
+			}
+			return v, v.client.QueryJobInputVersions(v.is, v.inputCache.Pipeline, v.inputCache.Job)
+		}
+	case api.JobInputVersionsMsg:
+		if msg.Error != nil {
+			return v, types.MsgAsCmd(types.NotifyMsg{Error: msg.Error})
+		}
+
+		if msg.Pipeline.String() != v.inputCache.Pipeline.String() || msg.Job != v.inputCache.Job {
+			v.selected = map[string]int{}
+		}
+
+		v.inputCache = msg
+		v.UpdateRows()
+		return v, nil
+	}
+
+	var cmd tea.Cmd
+	v.model, cmd = v.model.Update(msg)
+	return v, cmd
+}
+
+func (v *Trigger) View() string {
+	return v.model.View()
+}